@@ -0,0 +1,277 @@
+package main
+
+import (
+	"ReMapper/geometry"
+	"ReMapper/recfile"
+	"ReMapper/renderer"
+	"fmt"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// defaultFrameTicks is the duration of a frame if the record has no frame_ticks field.
+const defaultFrameTicks = 10
+
+// frameTicksOf returns the default duration of the frames of a record.
+func (e *Engine) frameTicksOf(record recfile.Record) int {
+	if ticks := (recfile.Field{Value: record.FindFirstFieldValue(e.config.FrameTicksField)}).AsInt(); ticks > 0 {
+		return ticks
+	}
+	return defaultFrameTicks
+}
+
+// animationOf reads the frames of a record. They are listed as "40 41 42" or with durations
+// in ticks as "40:8 41:8 42:16"; otherwise frame_count animates the icon and the cells after it.
+// Records without frames return nil.
+func (e *Engine) animationOf(record recfile.Record, icon int32) renderer.Animation {
+	ticks := e.frameTicksOf(record)
+	var animation renderer.Animation
+	if frames, hasFrames := findField(record, e.config.FramesField); hasFrames {
+		for _, token := range strings.Fields(frames) {
+			indexText, ticksText, hasTicks := strings.Cut(token, ":")
+			index, err := strconv.ParseInt(indexText, 10, 32)
+			if err != nil {
+				continue
+			}
+			frame := renderer.AnimationFrame{Icon: int32(index), Ticks: ticks}
+			if frameTicks, err := strconv.Atoi(ticksText); hasTicks && err == nil && frameTicks > 0 {
+				frame.Ticks = frameTicks
+			}
+			animation = append(animation, frame)
+		}
+		return animation
+	}
+	frameCount := (recfile.Field{Value: record.FindFirstFieldValue(e.config.FrameCountField)}).AsInt()
+	for i := 0; i < frameCount; i++ {
+		animation = append(animation, renderer.AnimationFrame{Icon: icon + int32(i), Ticks: ticks})
+	}
+	return animation
+}
+
+// formatFrames writes frames for the frames field, durations are only written if they differ from defaultTicks.
+func formatFrames(animation renderer.Animation, defaultTicks int) string {
+	tokens := make([]string, len(animation))
+	for index, frame := range animation {
+		tokens[index] = recfile.Int32Str(frame.Icon)
+		if frame.Ticks != defaultTicks {
+			tokens[index] += ":" + strconv.Itoa(frame.Ticks)
+		}
+	}
+	return strings.Join(tokens, " ")
+}
+
+// setFrames stores the frames of an entry as one undoable command. The icon becomes the first frame,
+// so readers that don't know about animations still show the entry.
+func (e *Engine) setFrames(key string, animation renderer.Animation, description string) {
+	e.editRecords(description, func(records []recfile.Record, mapping map[string]int32) ([]recfile.Record, string) {
+		recordIndex := e.recordIndexOfKey(records, key)
+		if recordIndex < 0 {
+			return records, key
+		}
+		record := slices.DeleteFunc(records[recordIndex], func(field recfile.Field) bool {
+			return field.Name == e.config.FrameCountField || (len(animation) == 0 && field.Name == e.config.FramesField)
+		})
+		if len(animation) > 0 {
+			record = setField(record, e.config.FramesField, formatFrames(animation, e.frameTicksOf(record)))
+			mapping[key] = animation[0].Icon
+		}
+		records[recordIndex] = record
+		return records, key
+	})
+}
+
+// timelinePanel edits the frames of the selected entry. While it is open,
+// clicks into the atlas add frames instead of assigning the icon.
+type timelinePanel struct {
+	isOpen        bool
+	selectedFrame int
+}
+
+type timelineLayout struct {
+	bounds     geometry.Rect
+	titlePos   geometry.PointF
+	previewPos geometry.Point
+	frameRects []geometry.Rect
+	buttons    []toolbarButton
+}
+
+func (e *Engine) toggleTimeline() {
+	if e.font.isActive {
+		return // glyphs are not animated
+	}
+	e.timeline.isOpen = !e.timeline.isOpen
+	if e.timeline.isOpen {
+		e.autotile.isOpen = false
+	}
+	e.timeline.selectedFrame = len(e.selectedAnimation()) - 1
+}
+
+func (e *Engine) selectedAnimation() renderer.Animation {
+	if _, isMapped := e.iconMapping[e.selectedKey]; !isMapped {
+		return nil
+	}
+	return e.animationOf(e.keyRecords[e.selectedKey], e.iconMapping[e.selectedKey])
+}
+
+// insertFrames adds frames for the cells after the selected frame and selects the last of them.
+func (e *Engine) insertFrames(cells []geometry.Point) bool {
+	if _, isMapped := e.iconMapping[e.selectedKey]; !isMapped || len(cells) == 0 {
+		return false
+	}
+	animation := e.selectedAnimation()
+	ticks := e.frameTicksOf(e.keyRecords[e.selectedKey])
+	insertAt := clamp(e.timeline.selectedFrame+1, 0, len(animation))
+	cellCountX := e.tileAtlas.GetCellCount().X
+	newFrames := make(renderer.Animation, len(cells))
+	for index, cell := range cells {
+		newFrames[index] = renderer.AnimationFrame{Icon: int32(XYToIndex(cell.X, cell.Y, cellCountX)), Ticks: ticks}
+	}
+	animation = slices.Insert(slices.Clone(animation), insertAt, newFrames...)
+	e.setFrames(e.selectedKey, animation, fmt.Sprintf("add %d frames to %s", len(cells), e.selectedKey))
+	e.timeline.selectedFrame = insertAt + len(cells) - 1
+	return true
+}
+
+func (e *Engine) removeSelectedFrame() {
+	animation := slices.Clone(e.selectedAnimation())
+	frame := e.timeline.selectedFrame
+	if frame < 0 || frame >= len(animation) {
+		return
+	}
+	animation = slices.Delete(animation, frame, frame+1)
+	e.setFrames(e.selectedKey, animation, fmt.Sprintf("remove frame %d of %s", frame+1, e.selectedKey))
+	e.timeline.selectedFrame = min(frame, len(animation)-1)
+}
+
+func (e *Engine) changeFrameTicks(frame, delta int) {
+	animation := slices.Clone(e.selectedAnimation())
+	if frame < 0 || frame >= len(animation) {
+		return
+	}
+	animation[frame].Ticks = max(1, animation[frame].Ticks+delta)
+	e.setFrames(e.selectedKey, animation, fmt.Sprintf("frame %d of %s: %d ticks", frame+1, e.selectedKey, animation[frame].Ticks))
+}
+
+// layoutTimeline places the panel along the bottom of the atlas pane.
+func (e *Engine) layoutTimeline() timelineLayout {
+	var layout timelineLayout
+	_, textHeight := e.renderer.MeasureString("Ag")
+	tileSize := e.tileAtlas.GetTileSize().MulF(e.tileScale)
+	frameWidth := max(tileSize.X, int(textHeight*3)) + int(e.padding)
+	height := int(textHeight+e.padding)*2 + tileSize.Y + int(e.padding*2)
+	viewport := e.atlasPane.viewport()
+	layout.bounds = geometry.NewRect(viewport.Min.X+int(e.padding), viewport.Max.Y-height-int(e.padding), viewport.Max.X-int(e.padding), viewport.Max.Y-int(e.padding))
+
+	layout.titlePos = geometry.PointF{X: float64(layout.bounds.Min.X) + e.padding, Y: float64(layout.bounds.Min.Y) + e.padding + textHeight - 2}
+	buttonX := layout.bounds.Max.X - int(e.padding)
+	buttons := []toolbarButton{
+		{label: "Close", action: e.toggleTimeline},
+		{label: "Clear", action: func() {
+			e.setFrames(e.selectedKey, nil, fmt.Sprintf("remove animation of %s", e.selectedKey))
+			e.timeline.selectedFrame = -1
+		}},
+		{label: "Remove frame", action: e.removeSelectedFrame},
+	}
+	for _, button := range buttons {
+		labelWidth, _ := e.renderer.MeasureString(button.label)
+		buttonX -= int(labelWidth + e.padding*2)
+		button.bounds = geometry.NewRect(buttonX, layout.bounds.Min.Y+int(e.padding/2), buttonX+int(labelWidth+e.padding*2), layout.bounds.Min.Y+int(textHeight+e.padding*1.5))
+		layout.buttons = append(layout.buttons, button)
+		buttonX -= int(e.padding / 2)
+	}
+
+	rowY := layout.bounds.Min.Y + int(textHeight+e.padding*2)
+	layout.previewPos = geometry.Point{X: layout.bounds.Min.X + int(e.padding), Y: rowY}
+	frameX := layout.previewPos.X + tileSize.X + int(e.padding*3)
+	for range e.selectedAnimation() {
+		layout.frameRects = append(layout.frameRects, geometry.NewRect(frameX, rowY, frameX+frameWidth, rowY+tileSize.Y+int(textHeight+e.padding)))
+		frameX += frameWidth + int(e.padding/2)
+	}
+	return layout
+}
+
+// timelineHeight is the part of the atlas pane that the timeline covers.
+func (e *Engine) timelineHeight() int {
+	if !e.timeline.isOpen {
+		return 0
+	}
+	return e.layoutTimeline().bounds.Size().Y + int(e.padding)
+}
+
+// handleTimelineMouse selects frames, changes their duration with the mouse wheel and runs the buttons.
+func (e *Engine) handleTimelineMouse() bool {
+	if !e.timeline.isOpen {
+		return false
+	}
+	layout := e.layoutTimeline()
+	if !layout.bounds.Contains(e.mousePosInPixels) {
+		return false
+	}
+	if _, wheelY := ebiten.Wheel(); wheelY != 0 {
+		for frame, frameRect := range layout.frameRects {
+			if frameRect.Contains(e.mousePosInPixels) {
+				delta := 1
+				if wheelY < 0 {
+					delta = -1
+				}
+				e.changeFrameTicks(frame, delta)
+			}
+		}
+		return true
+	}
+	if !inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		return ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft)
+	}
+	for _, button := range layout.buttons {
+		if button.bounds.Contains(e.mousePosInPixels) {
+			button.action()
+			return true
+		}
+	}
+	for frame, frameRect := range layout.frameRects {
+		if frameRect.Contains(e.mousePosInPixels) {
+			e.timeline.selectedFrame = frame
+		}
+	}
+	return true
+}
+
+func (e *Engine) drawTimeline() {
+	layout := e.layoutTimeline()
+	e.renderer.DrawFilledRect(layout.bounds.Min, layout.bounds.Size(), withAlpha(e.theme.Panel, 240))
+	e.renderer.DrawRectOutline(layout.bounds.Min, layout.bounds.Size(), 1, e.theme.Border)
+
+	animation := e.selectedAnimation()
+	title := "Select an entry to edit its animation"
+	if _, isMapped := e.iconMapping[e.selectedKey]; isMapped {
+		title = fmt.Sprintf("Animation of %s: %d frames, %d ticks - click atlas cells to add frames, wheel changes the duration", e.selectedKey, len(animation), animation.Duration())
+	}
+	e.renderer.DrawTTFOnScreen(layout.titlePos.X, layout.titlePos.Y, title, e.theme.Text)
+	for _, button := range layout.buttons {
+		e.renderer.DrawFilledRect(button.bounds.Min, button.bounds.Size(), e.theme.Button)
+		e.renderer.DrawRectOutline(button.bounds.Min, button.bounds.Size(), 1, e.theme.Border)
+		e.renderer.DrawTTFOnScreen(float64(button.bounds.Min.X)+e.padding, float64(button.bounds.Max.Y)-e.padding/2-2, button.label, e.theme.Text)
+	}
+	if len(animation) == 0 {
+		return
+	}
+
+	// the running animation, then every frame with its duration
+	layers := e.layersOf(e.selectedKey)
+	e.drawLayers(float64(layout.previewPos.X), float64(layout.previewPos.Y), layers, e.tileScale)
+	for frame, frameRect := range layout.frameRects {
+		if frame == e.timeline.selectedFrame {
+			e.renderer.DrawFilledRect(frameRect.Min, frameRect.Size(), e.theme.Selection)
+		}
+		if frame == animation.FrameIndexAt(e.ticks) {
+			e.renderer.DrawRectOutline(frameRect.Min, frameRect.Size(), 1, e.theme.Cursor)
+		}
+		frameLayers := layers
+		frameLayers.icon, frameLayers.frames = animation[frame].Icon, nil
+		e.drawLayers(float64(frameRect.Min.X)+e.padding/2, float64(frameRect.Min.Y), frameLayers, e.tileScale)
+		e.renderer.DrawTTFOnScreen(float64(frameRect.Min.X)+e.padding/2, float64(frameRect.Max.Y)-e.padding/2, fmt.Sprintf("%dt", animation[frame].Ticks), e.theme.MutedText)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"ReMapper/geometry"
+	"fmt"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"image/color"
+)
+
+const (
+	minAtlasScale = 1
+	maxAtlasScale = 16
+	previewSize   = 128
+)
+
+// zoomAtlas changes the integer scale of the atlas view and keeps the atlas pixel
+// under the anchor position in place.
+func (e *Engine) zoomAtlas(newScale float64, anchor geometry.Point) {
+	newScale = max(minAtlasScale, min(newScale, maxAtlasScale))
+	if newScale == e.atlasScale {
+		return
+	}
+	oldScale := e.atlasScale
+	anchorInAtlas := geometry.PointF{
+		X: float64(anchor.X-e.atlasBounds.Min.X) / oldScale,
+		Y: float64(anchor.Y-e.atlasBounds.Min.Y) / oldScale,
+	}
+	e.atlasScale = newScale
+	e.updateElementBounds() // the content size changed
+
+	viewport := e.atlasPane.viewport()
+	e.atlasPane.setScroll(geometry.PointF{
+		X: float64(viewport.Min.X) + e.padding - float64(anchor.X) + anchorInAtlas.X*newScale,
+		Y: float64(viewport.Min.Y) + e.padding - float64(anchor.Y) + anchorInAtlas.Y*newScale,
+	})
+	e.updateElementBounds()
+	e.OnMouseMoved(e.mousePosInPixels)
+}
+
+// handleAtlasZoomAndPan zooms with Ctrl+wheel and pans while the middle mouse button is held down.
+func (e *Engine) handleAtlasZoomAndPan() bool {
+	if e.isPanningAtlas {
+		if !ebiten.IsMouseButtonPressed(ebiten.MouseButtonMiddle) {
+			e.isPanningAtlas = false
+			e.setCursorShape(ebiten.CursorShapeDefault)
+			return true
+		}
+		delta := e.mousePosInPixels.Sub(e.panStartMouse)
+		e.atlasPane.setScroll(geometry.PointF{X: e.panStartScroll.X - float64(delta.X), Y: e.panStartScroll.Y - float64(delta.Y)})
+		e.updateElementBounds()
+		return true
+	}
+	if !e.atlasPane.viewport().Contains(e.mousePosInPixels) {
+		return false
+	}
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonMiddle) {
+		e.isPanningAtlas = true
+		e.panStartMouse = e.mousePosInPixels
+		e.panStartScroll = e.atlasPane.scroll
+		e.setCursorShape(ebiten.CursorShapeMove)
+		return true
+	}
+	if isControlPressed() {
+		if _, dy := ebiten.Wheel(); dy != 0 {
+			if dy > 0 {
+				e.zoomAtlas(e.atlasScale+1, e.mousePosInPixels)
+			} else {
+				e.zoomAtlas(e.atlasScale-1, e.mousePosInPixels)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// handleZoomKeys zooms around the center of the atlas pane with Ctrl +, Ctrl - and Ctrl 0.
+func (e *Engine) handleZoomKeys() bool {
+	center := e.atlasPane.viewport().Min.Add(e.atlasPane.viewport().Size().Div(2))
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyEqual) || inpututil.IsKeyJustPressed(ebiten.KeyNumpadAdd):
+		e.zoomAtlas(e.atlasScale+1, center)
+	case inpututil.IsKeyJustPressed(ebiten.KeyMinus) || inpututil.IsKeyJustPressed(ebiten.KeyNumpadSubtract):
+		e.zoomAtlas(e.atlasScale-1, center)
+	case inpututil.IsKeyJustPressed(ebiten.Key0) || inpututil.IsKeyJustPressed(ebiten.KeyNumpad0):
+		e.zoomAtlas(defaultAtlasScale, center)
+	default:
+		return false
+	}
+	return true
+}
+
+func (e *Engine) setCursorShape(shape ebiten.CursorShapeType) {
+	if shape != e.cursorShape {
+		e.cursorShape = shape
+		ebiten.SetCursorShape(shape)
+	}
+}
+
+// drawTilePreview shows the tile under the mouse cursor magnified in the corner of the atlas pane.
+func (e *Engine) drawTilePreview() {
+	if !e.drawAtlasCursor || e.isPanningAtlas {
+		return
+	}
+	gridPos := e.atlasGridFromScreenPos(e.mousePosInPixels)
+	cellCount := e.tileAtlas.GetCellCount()
+	if gridPos.X >= cellCount.X || gridPos.Y >= cellCount.Y {
+		return
+	}
+	atlasIndex := int32(XYToIndex(gridPos.X, gridPos.Y, cellCount.X))
+
+	tileSize := e.tileAtlas.GetTileSize()
+	scale := float64(previewSize) / float64(max(tileSize.X, tileSize.Y))
+	scaledSize := geometry.Point{X: int(float64(tileSize.X) * scale), Y: int(float64(tileSize.Y) * scale)}
+	label := fmt.Sprintf("#%d (%d, %d)", atlasIndex, gridPos.X, gridPos.Y)
+	if len(e.selectedKeys) > 1 {
+		fillOrder := "rows"
+		if e.fillColumnMajor {
+			fillOrder = "columns"
+		}
+		label += fmt.Sprintf("  fill: %s", fillOrder)
+	}
+	labelWidth, labelHeight := e.renderer.MeasureString(label)
+
+	boxSize := geometry.Point{
+		X: max(scaledSize.X, int(labelWidth)) + int(e.padding*2),
+		Y: scaledSize.Y + int(labelHeight+e.padding*3),
+	}
+	viewport := e.atlasPane.viewport()
+	boxPos := geometry.Point{X: viewport.Max.X - boxSize.X - int(e.padding), Y: viewport.Min.Y + int(e.padding)}
+	if geometry.NewRect(boxPos.X, boxPos.Y, boxPos.X+boxSize.X, boxPos.Y+boxSize.Y).Contains(e.mousePosInPixels) {
+		// don't cover the cell that is previewed
+		boxPos.Y = viewport.Max.Y - boxSize.Y - int(e.padding)
+	}
+
+	e.renderer.DrawFilledRect(boxPos, boxSize, withAlpha(e.theme.Panel, 240))
+	e.renderer.DrawRectOutline(boxPos, boxSize, 1, e.theme.Border)
+	tilePos := geometry.PointF{X: float64(boxPos.X) + e.padding, Y: float64(boxPos.Y) + e.padding}
+	// show the cell in the active layer of the selected entry
+	layers := glyphLayers{icon: atlasIndex, fgColor: color.White}
+	if _, isMapped := e.iconMapping[e.selectedKey]; isMapped {
+		layers = e.layersOf(e.selectedKey)
+		if e.activeLayer == layerBackground {
+			layers.bgIcon, layers.hasBgIcon = atlasIndex, true
+		} else {
+			layers.icon = atlasIndex
+		}
+	}
+	e.drawLayers(tilePos.X, tilePos.Y, layers, scale)
+	e.renderer.DrawTTFOnScreen(tilePos.X, tilePos.Y+float64(scaledSize.Y)+e.padding+labelHeight, label, e.theme.Text)
+}
@@ -0,0 +1,258 @@
+// Package autotile chooses the icon of a cell by the configuration of its neighbors.
+//
+// A Table maps neighbor masks to atlas indices. Wang16 tables only look at the four
+// cardinal neighbors, Blob47 tables also look at the corners, but a corner only counts
+// if both edges next to it are connected. That reduces the 256 masks to 47.
+//
+// The tables are stored in the records of a mapping:
+//
+//	internal_name: wall
+//	icon: 120
+//	autotile: blob47
+//	autotile_tiles: 0:120 4:121 16:122 20:123
+//	autotile_connect: door_closed door_open
+//
+// RuleSet reads them with FromRecords and resolves icons with Resolve.
+package autotile
+
+import (
+	"ReMapper/geometry"
+	"ReMapper/recfile"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Mask has one bit per neighbor, clockwise from north.
+type Mask uint8
+
+const (
+	North Mask = 1 << iota
+	NorthEast
+	East
+	SouthEast
+	South
+	SouthWest
+	West
+	NorthWest
+)
+
+const cardinals = North | East | South | West
+
+// offsets of the neighbors in the order of the bits
+var neighborOffsets = [8]geometry.Point{
+	{X: 0, Y: -1}, {X: 1, Y: -1}, {X: 1, Y: 0}, {X: 1, Y: 1},
+	{X: 0, Y: 1}, {X: -1, Y: 1}, {X: -1, Y: 0}, {X: -1, Y: -1},
+}
+
+// Offset returns the position of the neighbor of a single bit relative to the cell.
+func (m Mask) Offset() geometry.Point {
+	for bit := range neighborOffsets {
+		if m == 1<<bit {
+			return neighborOffsets[bit]
+		}
+	}
+	return geometry.Point{}
+}
+
+// Has returns true if all bits of other are set.
+func (m Mask) Has(other Mask) bool {
+	return m&other == other
+}
+
+// MaskAt returns the mask of the neighbors of pos that connect.
+func MaskAt(pos geometry.Point, connects func(geometry.Point) bool) Mask {
+	var neighbors geometry.Neighbors
+	var mask Mask
+	for _, neighbor := range neighbors.All(pos, connects) {
+		delta := neighbor.Sub(pos)
+		for bit, offset := range neighborOffsets {
+			if delta == offset {
+				mask |= 1 << bit
+			}
+		}
+	}
+	return mask
+}
+
+// Kind is the number of variants of a table.
+type Kind int
+
+const (
+	Wang16 Kind = iota
+	Blob47
+)
+
+func (k Kind) String() string {
+	if k == Blob47 {
+		return "blob47"
+	}
+	return "wang16"
+}
+
+// ParseKind reads "wang16" or "blob47", "16" and "47" work as well.
+func ParseKind(text string) (Kind, error) {
+	switch strings.ToLower(strings.TrimSpace(text)) {
+	case "wang16", "wang", "16":
+		return Wang16, nil
+	case "blob47", "blob", "47":
+		return Blob47, nil
+	}
+	return Wang16, fmt.Errorf("unknown autotile kind '%s', expected wang16 or blob47", text)
+}
+
+// Reduce removes the bits that the kind does not look at.
+func (k Kind) Reduce(mask Mask) Mask {
+	if k == Wang16 {
+		return mask & cardinals
+	}
+	reduced := mask & cardinals
+	corners := [4][3]Mask{
+		{NorthEast, North, East},
+		{SouthEast, South, East},
+		{SouthWest, South, West},
+		{NorthWest, North, West},
+	}
+	for _, corner := range corners {
+		if mask.Has(corner[0]) && mask.Has(corner[1]|corner[2]) {
+			reduced |= corner[0]
+		}
+	}
+	return reduced
+}
+
+// Masks returns the 16 or 47 different masks of the kind in ascending order.
+func (k Kind) Masks() []Mask {
+	var masks []Mask
+	for mask := 0; mask < 256; mask++ {
+		if k.Reduce(Mask(mask)) == Mask(mask) {
+			masks = append(masks, Mask(mask))
+		}
+	}
+	return masks
+}
+
+// Table holds the icons of one entry by mask.
+type Table struct {
+	Kind  Kind
+	Icons map[Mask]int32
+}
+
+// ParseTable reads the tiles of a table, written as "mask:icon" pairs separated by spaces.
+func ParseTable(kind Kind, tiles string) (Table, error) {
+	table := Table{Kind: kind, Icons: make(map[Mask]int32)}
+	for _, token := range strings.Fields(tiles) {
+		maskText, iconText, hasIcon := strings.Cut(token, ":")
+		mask, maskErr := strconv.ParseUint(maskText, 10, 8)
+		icon, iconErr := strconv.ParseInt(iconText, 10, 32)
+		if !hasIcon || maskErr != nil || iconErr != nil {
+			return table, fmt.Errorf("invalid autotile entry '%s', expected mask:icon", token)
+		}
+		if kind.Reduce(Mask(mask)) != Mask(mask) {
+			return table, fmt.Errorf("mask %d is not used by %s tables", mask, kind)
+		}
+		table.Icons[Mask(mask)] = int32(icon)
+	}
+	return table, nil
+}
+
+// String writes the tiles of the table in the format of ParseTable, sorted by mask.
+func (t Table) String() string {
+	masks := make([]int, 0, len(t.Icons))
+	for mask := range t.Icons {
+		masks = append(masks, int(mask))
+	}
+	sort.Ints(masks)
+	tokens := make([]string, len(masks))
+	for index, mask := range masks {
+		tokens[index] = fmt.Sprintf("%d:%d", mask, t.Icons[Mask(mask)])
+	}
+	return strings.Join(tokens, " ")
+}
+
+// Icon returns the icon for the neighbors in mask. The bits that the kind does not look at are ignored.
+func (t Table) Icon(mask Mask) (int32, bool) {
+	icon, isAssigned := t.Icons[t.Kind.Reduce(mask)]
+	return icon, isAssigned
+}
+
+// Rule is the table of an entry and the other entries that count as the same when computing masks.
+type Rule struct {
+	Table   Table
+	Connect []string
+}
+
+// Fields names the record fields of the rules.
+type Fields struct {
+	Key     string
+	Kind    string
+	Tiles   string
+	Connect string
+}
+
+// DefaultFields are the field names that ReMapper writes.
+var DefaultFields = Fields{Key: "internal_name", Kind: "autotile", Tiles: "autotile_tiles", Connect: "autotile_connect"}
+
+// RuleSet holds the rules of all auto-tiled entries by key.
+type RuleSet map[string]Rule
+
+// RecordError is the problem of the table of one record.
+type RecordError struct {
+	Key string
+	Err error
+}
+
+func (e *RecordError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Key, e.Err)
+}
+
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
+
+// FromRecords reads the rules of all records with a kind field. Records with invalid tables
+// are reported as one *RecordError each, joined with errors.Join; the other rules are still returned.
+func FromRecords(records []recfile.Record, fields Fields) (RuleSet, error) {
+	rules := make(RuleSet)
+	var problems []error
+	for _, record := range records {
+		kindText := record.FindFirstFieldValue(fields.Kind)
+		key := record.FindFirstFieldValue(fields.Key)
+		if kindText == "" || key == "" {
+			continue
+		}
+		kind, err := ParseKind(kindText)
+		if err == nil {
+			var table Table
+			table, err = ParseTable(kind, record.FindFirstFieldValue(fields.Tiles))
+			if err == nil {
+				rules[key] = Rule{Table: table, Connect: strings.Fields(record.FindFirstFieldValue(fields.Connect))}
+			}
+		}
+		if err != nil {
+			problems = append(problems, &RecordError{Key: key, Err: err})
+		}
+	}
+	return rules, errors.Join(problems...)
+}
+
+// Connects returns true if a neighbor with the key other joins a cell with the key of the rule.
+func (r Rule) Connects(key, other string) bool {
+	return other == key || slices.Contains(r.Connect, other)
+}
+
+// Resolve returns the icon of the cell at pos, keyAt returns the keys of the map.
+// It returns false if the key has no rule or the table has no icon for the neighbors.
+func (s RuleSet) Resolve(key string, pos geometry.Point, keyAt func(geometry.Point) string) (int32, bool) {
+	rule, hasRule := s[key]
+	if !hasRule {
+		return 0, false
+	}
+	mask := MaskAt(pos, func(neighbor geometry.Point) bool {
+		return rule.Connects(key, keyAt(neighbor))
+	})
+	return rule.Table.Icon(mask)
+}
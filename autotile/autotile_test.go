@@ -0,0 +1,163 @@
+package autotile
+
+import (
+	"ReMapper/geometry"
+	"ReMapper/recfile"
+	"errors"
+	"testing"
+)
+
+func TestMasksCount(t *testing.T) {
+	tests := []struct {
+		kind  Kind
+		count int
+	}{
+		{Wang16, 16},
+		{Blob47, 47},
+	}
+	for _, test := range tests {
+		masks := test.kind.Masks()
+		if len(masks) != test.count {
+			t.Errorf("%s has %d masks, want %d", test.kind, len(masks), test.count)
+		}
+		for index := 1; index < len(masks); index++ {
+			if masks[index-1] >= masks[index] {
+				t.Errorf("%s masks are not ascending at %d", test.kind, index)
+			}
+		}
+	}
+}
+
+func TestReduce(t *testing.T) {
+	tests := []struct {
+		name string
+		kind Kind
+		mask Mask
+		want Mask
+	}{
+		{"wang drops corners", Wang16, North | NorthEast | East, North | East},
+		{"blob keeps a corner between two edges", Blob47, North | NorthEast | East, North | NorthEast | East},
+		{"blob drops a corner with one edge", Blob47, North | NorthEast, North},
+		{"blob drops a lone corner", Blob47, SouthWest, 0},
+		{"blob keeps everything", Blob47, 0xff, 0xff},
+	}
+	for _, test := range tests {
+		if got := test.kind.Reduce(test.mask); got != test.want {
+			t.Errorf("%s: Reduce(%08b) = %08b, want %08b", test.name, test.mask, got, test.want)
+		}
+	}
+}
+
+func TestMaskAt(t *testing.T) {
+	// a plus sign without its center cell at 1,1, and a corner at 2,2
+	walls := map[geometry.Point]bool{
+		{X: 1, Y: 0}: true,
+		{X: 0, Y: 1}: true,
+		{X: 2, Y: 1}: true,
+		{X: 1, Y: 2}: true,
+		{X: 2, Y: 2}: true,
+	}
+	mask := MaskAt(geometry.Point{X: 1, Y: 1}, func(pos geometry.Point) bool { return walls[pos] })
+	if want := North | East | South | West | SouthEast; mask != want {
+		t.Errorf("MaskAt = %08b, want %08b", mask, want)
+	}
+}
+
+func TestParseTableRoundTrip(t *testing.T) {
+	tiles := "0:120 4:121 16:122 20:123 28:124"
+	table, err := ParseTable(Blob47, tiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := table.String(); got != tiles {
+		t.Errorf("String() = %q, want %q", got, tiles)
+	}
+	again, err := ParseTable(Blob47, table.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(again.Icons) != len(table.Icons) {
+		t.Errorf("round trip has %d icons, want %d", len(again.Icons), len(table.Icons))
+	}
+}
+
+func TestParseTableErrors(t *testing.T) {
+	tests := []struct {
+		kind  Kind
+		tiles string
+	}{
+		{Wang16, "0:1 4"},
+		{Wang16, "x:1"},
+		{Wang16, "0:y"},
+		{Wang16, "256:1"},
+		{Wang16, "2:1"},  // north east is no wang16 mask
+		{Blob47, "3:1"},  // north east without east
+		{Blob47, "-1:1"}, // not a mask
+	}
+	for _, test := range tests {
+		if _, err := ParseTable(test.kind, test.tiles); err == nil {
+			t.Errorf("ParseTable(%s, %q) returned no error", test.kind, test.tiles)
+		}
+	}
+}
+
+func TestFromRecords(t *testing.T) {
+	records := []recfile.Record{
+		{{Name: "internal_name", Value: "wall"}, {Name: "autotile", Value: "wang16"}, {Name: "autotile_tiles", Value: "0:10 5:11"}},
+		{{Name: "internal_name", Value: "water"}, {Name: "autotile", Value: "blob47"}, {Name: "autotile_tiles", Value: "0:20 oops"}},
+		{{Name: "internal_name", Value: "lava"}, {Name: "autotile", Value: "hex"}},
+		{{Name: "internal_name", Value: "floor"}},
+	}
+	rules, err := FromRecords(records, DefaultFields)
+	if _, hasWall := rules["wall"]; !hasWall || len(rules) != 1 {
+		t.Errorf("rules = %v, want only wall", rules)
+	}
+	joined, isJoined := err.(interface{ Unwrap() []error })
+	if !isJoined {
+		t.Fatalf("error %v does not join the problems", err)
+	}
+	var keys []string
+	for _, problem := range joined.Unwrap() {
+		var recordErr *RecordError
+		if !errors.As(problem, &recordErr) {
+			t.Fatalf("problem %v is no RecordError", problem)
+		}
+		keys = append(keys, recordErr.Key)
+	}
+	if len(keys) != 2 || keys[0] != "water" || keys[1] != "lava" {
+		t.Errorf("problems of %v, want water and lava", keys)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	table, err := ParseTable(Wang16, "0:100 4:101 5:102 85:103")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules := RuleSet{"wall": {Table: table, Connect: []string{"door"}}}
+	level := map[geometry.Point]string{
+		{X: 1, Y: 1}: "wall",
+		{X: 2, Y: 1}: "wall",
+		{X: 1, Y: 0}: "door",
+		{X: 0, Y: 1}: "floor",
+	}
+	keyAt := func(pos geometry.Point) string { return level[pos] }
+
+	tests := []struct {
+		name       string
+		key        string
+		pos        geometry.Point
+		wantIcon   int32
+		wantExists bool
+	}{
+		{"connected to the door above and the wall to the right", "wall", geometry.Point{X: 1, Y: 1}, 102, true},
+		{"only the wall to the left, no icon for west", "wall", geometry.Point{X: 2, Y: 1}, 0, false},
+		{"no rule", "floor", geometry.Point{X: 0, Y: 1}, 0, false},
+	}
+	for _, test := range tests {
+		icon, exists := rules.Resolve(test.key, test.pos, keyAt)
+		if icon != test.wantIcon || exists != test.wantExists {
+			t.Errorf("%s: Resolve = %d, %t, want %d, %t", test.name, icon, exists, test.wantIcon, test.wantExists)
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"ReMapper/autotile"
+	"ReMapper/geometry"
+	"ReMapper/recfile"
+	"errors"
+	"fmt"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"slices"
+)
+
+const (
+	templateCellSize  = 8
+	templateTileScale = 2
+)
+
+// autotilePanel edits the auto-tile table of the selected entry. While it is open,
+// clicks into the atlas assign the cell to the selected neighbor mask.
+type autotilePanel struct {
+	isOpen       bool
+	selectedMask autotile.Mask
+}
+
+type autotileLayout struct {
+	bounds        geometry.Rect
+	titlePos      geometry.PointF
+	buttons       []toolbarButton
+	masks         []autotile.Mask
+	templateRects []geometry.Rect
+}
+
+// updateAutotileRules reads the tables of all records, it runs whenever the records change.
+// Entries with an invalid table get no rule, their problem is shown in the panel and the overlay legend.
+func (e *Engine) updateAutotileRules() {
+	var err error
+	e.autotileRules, err = autotile.FromRecords(e.originalRecords, e.config.AutotileFields())
+	e.autotileProblems = autotileProblemsOf(err)
+}
+
+// autotileProblemsOf returns the problems of the records in an error of autotile.FromRecords by key.
+func autotileProblemsOf(err error) map[string]string {
+	problems := make(map[string]string)
+	joined, isJoined := err.(interface{ Unwrap() []error })
+	if !isJoined {
+		return problems
+	}
+	for _, problem := range joined.Unwrap() {
+		var recordErr *autotile.RecordError
+		if errors.As(problem, &recordErr) {
+			problems[recordErr.Key] = recordErr.Err.Error()
+		}
+	}
+	return problems
+}
+
+// selectedAutotile returns the table of the selected entry, false if it has none.
+func (e *Engine) selectedAutotile() (autotile.Table, bool) {
+	rule, hasRule := e.autotileRules[e.selectedKey]
+	return rule.Table, hasRule
+}
+
+// setAutotile stores the table of an entry as one undoable command, nil removes it.
+func (e *Engine) setAutotile(key string, table *autotile.Table, description string) {
+	e.editRecords(description, func(records []recfile.Record, mapping map[string]int32) ([]recfile.Record, string) {
+		recordIndex := e.recordIndexOfKey(records, key)
+		if recordIndex < 0 {
+			return records, key
+		}
+		record := records[recordIndex]
+		if table == nil {
+			record = slices.DeleteFunc(record, func(field recfile.Field) bool {
+				return field.Name == e.config.AutotileField || field.Name == e.config.AutotileTilesField
+			})
+		} else {
+			record = setField(record, e.config.AutotileField, table.Kind.String())
+			record = setField(record, e.config.AutotileTilesField, table.String())
+		}
+		records[recordIndex] = record
+		return records, key
+	})
+}
+
+func (e *Engine) toggleAutotile() {
+	if e.font.isActive {
+		return // glyphs are not auto-tiled
+	}
+	e.autotile.isOpen = !e.autotile.isOpen
+	e.autotile.selectedMask = 0
+	if e.autotile.isOpen {
+		e.timeline.isOpen = false // both use the bottom of the atlas
+	}
+}
+
+// setAutotileKind starts a table of the kind or converts the existing one.
+// Masks that the new kind does not use are dropped.
+func (e *Engine) setAutotileKind(kind autotile.Kind) {
+	if _, isMapped := e.iconMapping[e.selectedKey]; !isMapped {
+		return
+	}
+	table, hasTable := e.selectedAutotile()
+	if hasTable && table.Kind == kind {
+		return
+	}
+	converted := autotile.Table{Kind: kind, Icons: make(map[autotile.Mask]int32)}
+	for mask, icon := range table.Icons {
+		if kind.Reduce(mask) == mask {
+			converted.Icons[mask] = icon
+		}
+	}
+	e.setAutotile(e.selectedKey, &converted, fmt.Sprintf("%s: %s tiles", e.selectedKey, kind))
+	e.autotile.selectedMask = kind.Reduce(e.autotile.selectedMask)
+}
+
+// assignAutotileCells assigns the cells to the masks starting at the selected one, in the order
+// of the templates, and selects the mask after the last one.
+func (e *Engine) assignAutotileCells(cells []geometry.Point) bool {
+	table, hasTable := e.selectedAutotile()
+	if !hasTable || len(cells) == 0 {
+		return false
+	}
+	masks := table.Kind.Masks()
+	start := max(0, slices.Index(masks, e.autotile.selectedMask))
+	icons := make(map[autotile.Mask]int32, len(table.Icons))
+	for mask, icon := range table.Icons {
+		icons[mask] = icon
+	}
+	cellCountX := e.tileAtlas.GetCellCount().X
+	for index, cell := range cells {
+		if start+index >= len(masks) {
+			break
+		}
+		icons[masks[start+index]] = int32(XYToIndex(cell.X, cell.Y, cellCountX))
+	}
+	updated := autotile.Table{Kind: table.Kind, Icons: icons}
+	description := fmt.Sprintf("%s: mask %d", e.selectedKey, e.autotile.selectedMask)
+	if len(cells) > 1 {
+		description = fmt.Sprintf("%s: %d masks", e.selectedKey, min(len(cells), len(masks)-start))
+	}
+	e.setAutotile(e.selectedKey, &updated, description)
+	e.autotile.selectedMask = masks[min(start+len(cells), len(masks)-1)]
+	return true
+}
+
+func (e *Engine) clearAutotileMask(mask autotile.Mask) {
+	table, hasTable := e.selectedAutotile()
+	if _, isAssigned := table.Icons[mask]; !hasTable || !isAssigned {
+		return
+	}
+	icons := make(map[autotile.Mask]int32, len(table.Icons))
+	for otherMask, icon := range table.Icons {
+		if otherMask != mask {
+			icons[otherMask] = icon
+		}
+	}
+	e.setAutotile(e.selectedKey, &autotile.Table{Kind: table.Kind, Icons: icons}, fmt.Sprintf("%s: clear mask %d", e.selectedKey, mask))
+}
+
+// layoutAutotile places the panel along the bottom of the atlas pane, the templates wrap into rows.
+func (e *Engine) layoutAutotile() autotileLayout {
+	var layout autotileLayout
+	_, textHeight := e.renderer.MeasureString("Ag")
+	table, hasTable := e.selectedAutotile()
+	if hasTable {
+		layout.masks = table.Kind.Masks()
+	}
+	tileSize := e.tileAtlas.GetTileSize().MulF(templateTileScale)
+	templateSize := geometry.Point{
+		X: max(templateCellSize*3, tileSize.X) + int(e.padding),
+		Y: templateCellSize*3 + tileSize.Y + int(e.padding*1.5),
+	}
+	viewport := e.atlasPane.viewport()
+	width := viewport.Size().X - int(e.padding*2)
+	perRow := max(1, (width-int(e.padding))/templateSize.X)
+	rows := (len(layout.masks) + perRow - 1) / perRow
+	height := int(textHeight+e.padding*2) + rows*templateSize.Y + int(e.padding)
+	layout.bounds = geometry.NewRect(viewport.Min.X+int(e.padding), viewport.Max.Y-height-int(e.padding), viewport.Max.X-int(e.padding), viewport.Max.Y-int(e.padding))
+	layout.titlePos = geometry.PointF{X: float64(layout.bounds.Min.X) + e.padding, Y: float64(layout.bounds.Min.Y) + e.padding + textHeight - 2}
+
+	buttons := []toolbarButton{
+		{label: "Close", action: e.toggleAutotile},
+		{label: "Remove", action: func() {
+			if hasTable {
+				e.setAutotile(e.selectedKey, nil, fmt.Sprintf("remove auto-tiles of %s", e.selectedKey))
+			}
+		}},
+		{label: "Blob 47", isActive: hasTable && table.Kind == autotile.Blob47, action: func() { e.setAutotileKind(autotile.Blob47) }},
+		{label: "Wang 16", isActive: hasTable && table.Kind == autotile.Wang16, action: func() { e.setAutotileKind(autotile.Wang16) }},
+	}
+	buttonX := layout.bounds.Max.X - int(e.padding)
+	for _, button := range buttons {
+		labelWidth, _ := e.renderer.MeasureString(button.label)
+		buttonX -= int(labelWidth + e.padding*2)
+		button.bounds = geometry.NewRect(buttonX, layout.bounds.Min.Y+int(e.padding/2), buttonX+int(labelWidth+e.padding*2), layout.bounds.Min.Y+int(textHeight+e.padding*1.5))
+		layout.buttons = append(layout.buttons, button)
+		buttonX -= int(e.padding / 2)
+	}
+
+	origin := geometry.Point{X: layout.bounds.Min.X + int(e.padding), Y: layout.bounds.Min.Y + int(textHeight+e.padding*2)}
+	for index := range layout.masks {
+		pos := origin.Add(geometry.Point{X: (index % perRow) * templateSize.X, Y: (index / perRow) * templateSize.Y})
+		layout.templateRects = append(layout.templateRects, geometry.Rect{Min: pos, Max: pos.Add(templateSize.Shift(-int(e.padding/2), -int(e.padding/2)))})
+	}
+	return layout
+}
+
+// autotileHeight is the part of the atlas pane that the panel covers.
+func (e *Engine) autotileHeight() int {
+	if !e.autotile.isOpen {
+		return 0
+	}
+	return e.layoutAutotile().bounds.Size().Y + int(e.padding)
+}
+
+// handleAutotileMouse selects a mask with the left mouse button and clears it with the right one.
+func (e *Engine) handleAutotileMouse() bool {
+	if !e.autotile.isOpen {
+		return false
+	}
+	layout := e.layoutAutotile()
+	if !layout.bounds.Contains(e.mousePosInPixels) {
+		return false
+	}
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonRight) {
+		for index, templateRect := range layout.templateRects {
+			if templateRect.Contains(e.mousePosInPixels) {
+				e.clearAutotileMask(layout.masks[index])
+			}
+		}
+		return true
+	}
+	if !inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		return ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft)
+	}
+	for _, button := range layout.buttons {
+		if button.bounds.Contains(e.mousePosInPixels) {
+			button.action()
+			return true
+		}
+	}
+	for index, templateRect := range layout.templateRects {
+		if templateRect.Contains(e.mousePosInPixels) {
+			e.autotile.selectedMask = layout.masks[index]
+		}
+	}
+	return true
+}
+
+func (e *Engine) drawAutotile() {
+	layout := e.layoutAutotile()
+	e.renderer.DrawFilledRect(layout.bounds.Min, layout.bounds.Size(), withAlpha(e.theme.Panel, 240))
+	e.renderer.DrawRectOutline(layout.bounds.Min, layout.bounds.Size(), 1, e.theme.Border)
+
+	table, hasTable := e.selectedAutotile()
+	title := "Select an entry to edit its auto-tiles"
+	if _, isMapped := e.iconMapping[e.selectedKey]; isMapped {
+		title = fmt.Sprintf("Auto-tiles of %s: choose Wang 16 or Blob 47", e.selectedKey)
+		if hasTable {
+			title = fmt.Sprintf("Auto-tiles of %s: %d of %d masks - click atlas cells to assign, right click clears", e.selectedKey, len(table.Icons), len(layout.masks))
+		}
+	}
+	titleColor := e.theme.Text
+	if problem, hasProblem := e.autotileProblems[e.selectedKey]; hasProblem {
+		title = fmt.Sprintf("Auto-tiles of %s: %s", e.selectedKey, problem)
+		titleColor = e.theme.Error
+	}
+	e.renderer.DrawTTFOnScreen(layout.titlePos.X, layout.titlePos.Y, title, titleColor)
+	e.drawButtons(layout.buttons)
+
+	for index, templateRect := range layout.templateRects {
+		mask := layout.masks[index]
+		if mask == e.autotile.selectedMask {
+			e.renderer.DrawFilledRect(templateRect.Min, templateRect.Size(), e.theme.Selection)
+		}
+		e.drawNeighborTemplate(templateRect.Min.Shift(int(e.padding/4), int(e.padding/4)), mask)
+		if icon, isAssigned := table.Icons[mask]; isAssigned {
+			tilePos := templateRect.Min.Shift(int(e.padding/4), templateCellSize*3+int(e.padding/2))
+			e.renderer.DrawTileWithDefaultOrientation(float64(tilePos.X), float64(tilePos.Y), e.tileAtlas, icon, geometry.PointF{X: templateTileScale, Y: templateTileScale}, e.tintOf(e.selectedKey))
+		}
+	}
+}
+
+// drawNeighborTemplate shows a mask as a 3x3 block, connected neighbors are filled.
+func (e *Engine) drawNeighborTemplate(pos geometry.Point, mask autotile.Mask) {
+	cellSize := geometry.Point{X: templateCellSize - 1, Y: templateCellSize - 1}
+	center := pos.Shift(templateCellSize, templateCellSize)
+	e.renderer.DrawFilledRect(center, cellSize, e.theme.Cursor)
+	for bit := 0; bit < 8; bit++ {
+		neighbor := autotile.Mask(1 << bit)
+		cellPos := center.Add(neighbor.Offset().Mul(templateCellSize))
+		if mask.Has(neighbor) {
+			e.renderer.DrawFilledRect(cellPos, cellSize, e.theme.MutedText)
+		} else {
+			e.renderer.DrawRectOutline(cellPos, cellSize, 1, e.theme.Separator)
+		}
+	}
+}
+
+// autotiledLayers replaces the glyph of an auto-tiled entry with the icon for its neighbors in the layer.
+func (e *Engine) autotiledLayers(key string, layers glyphLayers, pos geometry.Point, keyAt func(geometry.Point) string) glyphLayers {
+	if icon, isResolved := e.autotileRules.Resolve(key, pos, keyAt); isResolved {
+		layers.icon = icon
+		layers.frames = nil
+	}
+	return layers
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"ReMapper/geometry"
+	"ReMapper/recfile"
+	"ReMapper/renderer"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	_ "image/png"
+	"io"
+	"os"
+	"strconv"
+)
+
+type cliCommand struct {
+	name    string
+	args    string
+	summary string
+	run     func(fs *flag.FlagSet, stdout io.Writer) func(args []string) error
+}
+
+// errProblemsFound makes the command exit with status 1 without printing an extra message.
+var errProblemsFound = errors.New("problems found")
+
+func cliCommands() []cliCommand {
+	return []cliCommand{
+		{name: "edit", args: "<mapping rec file>", summary: "open the mapping in the graphical editor", run: editCommand},
+		{name: "font", args: "<font rec file>", summary: "map the runes of a bitmap font to atlas cells", run: fontCommand},
+		{name: "check", args: "<mapping rec file>", summary: "report missing, duplicate and out of range entries", run: checkCommand},
+		{name: "export", args: "<mapping rec file>", summary: "write the mapping as csv, tsv or json", run: exportCommand},
+		{name: "stats", args: "<mapping rec file>", summary: "print usage statistics of the mapping and the atlas", run: statsCommand},
+		{name: "apply", args: "<mapping rec file> <assignments file>", summary: "copy icons from a csv or rec file into the mapping", run: applyCommand},
+		{name: "gen", args: "<mapping rec file>", summary: "generate Go constants for all entries", run: genCommand},
+	}
+}
+
+func runCLI(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		printUsage(stderr)
+		return 2
+	}
+	commandName := args[0]
+	if isLegacyInvocation(args) {
+		// remapper <cell width> <cell height> <atlas png file> <mapping rec file>
+		commandName = "edit"
+		args = []string{"edit", "-tile-width", args[0], "-tile-height", args[1], "-atlas", args[2], args[3]}
+	}
+	switch commandName {
+	case "-h", "-help", "--help":
+		printUsage(stdout)
+		return 0
+	case "help":
+		if len(args) < 2 {
+			printUsage(stdout)
+			return 0
+		}
+		commandName = args[1]
+		args = []string{commandName, "-h"}
+	}
+
+	for _, command := range cliCommands() {
+		if command.name != commandName {
+			continue
+		}
+		fs := flag.NewFlagSet(command.name, flag.ContinueOnError)
+		fs.SetOutput(stderr)
+		fs.Usage = func() {
+			fmt.Fprintf(fs.Output(), "Usage: remapper %s [flags] %s\n\n%s\n\nFlags:\n", command.name, command.args, command.summary)
+			fs.PrintDefaults()
+		}
+		run := command.run(fs, stdout)
+		if err := fs.Parse(args[1:]); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return 0
+			}
+			return 2
+		}
+		if err := run(fs.Args()); err != nil {
+			var usageErr usageError
+			switch {
+			case errors.Is(err, errProblemsFound):
+				return 1
+			case errors.As(err, &usageErr):
+				fmt.Fprintf(stderr, "remapper %s: %s\n", command.name, err.Error())
+				fs.Usage()
+				return 2
+			}
+			fmt.Fprintf(stderr, "remapper %s: %s\n", command.name, err.Error())
+			return 1
+		}
+		return 0
+	}
+	fmt.Fprintf(stderr, "remapper: unknown command '%s'\n\n", commandName)
+	printUsage(stderr)
+	return 2
+}
+
+func printUsage(output io.Writer) {
+	fmt.Fprintln(output, "Usage: remapper <command> [flags] [arguments]")
+	fmt.Fprintln(output)
+	fmt.Fprintln(output, "Commands:")
+	for _, command := range cliCommands() {
+		fmt.Fprintf(output, "  %-8s %s\n", command.name, command.summary)
+	}
+	fmt.Fprintln(output)
+	fmt.Fprintln(output, "Use \"remapper help <command>\" for the flags of a command.")
+}
+
+func isLegacyInvocation(args []string) bool {
+	if len(args) != 4 {
+		return false
+	}
+	_, widthErr := strconv.Atoi(args[0])
+	_, heightErr := strconv.Atoi(args[1])
+	return widthErr == nil && heightErr == nil
+}
+
+type usageError struct {
+	message string
+}
+
+func (u usageError) Error() string {
+	return u.message
+}
+
+func expectArgs(args []string, count int) error {
+	if len(args) != count {
+		return usageError{message: fmt.Sprintf("expected %d argument(s), got %d", count, len(args))}
+	}
+	return nil
+}
+
+// mappingOptions are the flags that choose the fields of the mapping rec file.
+type mappingOptions struct {
+	configFile  string
+	keyField    string
+	iconField   string
+	labelFields stringListFlag
+}
+
+func (o *mappingOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.configFile, "config", "", "rec file with key_field, icon_field and label_field entries")
+	fs.StringVar(&o.keyField, "key", "", "name of the field that identifies a record (default \"internal_name\")")
+	fs.StringVar(&o.iconField, "icon", "", "name of the field that holds the atlas index (default \"icon\")")
+	fs.Var(&o.labelFields, "label", "name of a field to show next to the key, may be repeated")
+}
+
+func (o *mappingOptions) resolve() (MappingConfig, error) {
+	config := DefaultMappingConfig()
+	if o.configFile != "" {
+		loadedConfig, err := LoadMappingConfig(o.configFile, config)
+		if err != nil {
+			return config, err
+		}
+		config = loadedConfig
+	}
+	// explicit flags win over the config file
+	if o.keyField != "" {
+		config.KeyField = o.keyField
+	}
+	if o.iconField != "" {
+		config.IconField = o.iconField
+	}
+	if len(o.labelFields) > 0 {
+		config.LabelFields = o.labelFields
+	}
+	return config, config.Validate()
+}
+
+func (o *mappingOptions) load(mappingFileName string) (MappingConfig, []recfile.Record, map[string]int32, error) {
+	config, err := o.resolve()
+	if err != nil {
+		return config, nil, nil, err
+	}
+	records, mapping, err := buildCurrentMapping(mappingFileName, config)
+	return config, records, mapping, err
+}
+
+// atlasOptions are the flags that describe the texture atlas.
+// The tile size defaults to the values of the sidecar config next to the atlas.
+type atlasOptions struct {
+	atlasFile  string
+	tileWidth  int
+	tileHeight int
+}
+
+type atlasInfo struct {
+	FileName  string
+	TileSize  geometry.Point
+	CellCount geometry.Point
+}
+
+func (o *atlasOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.atlasFile, "atlas", "", "atlas png file")
+	fs.IntVar(&o.tileWidth, "tile-width", 0, "width of a tile in pixels (default from <atlas>.rec)")
+	fs.IntVar(&o.tileHeight, "tile-height", 0, "height of a tile in pixels (default from <atlas>.rec)")
+}
+
+// resolve validates the atlas options. Without an atlas file it returns an empty
+// atlasInfo unless the atlas is required.
+func (o *atlasOptions) resolve(required bool) (atlasInfo, error) {
+	if o.atlasFile == "" {
+		if required {
+			return atlasInfo{}, usageError{message: "no atlas given, use -atlas <png file>"}
+		}
+		return atlasInfo{}, nil
+	}
+	tileSize := geometry.Point{X: o.tileWidth, Y: o.tileHeight}
+	if tileSize.X == 0 || tileSize.Y == 0 {
+		sidecar, err := loadAtlasSidecar(sidecarFileName(o.atlasFile))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return atlasInfo{}, err
+		}
+		if tileSize.X == 0 {
+			tileSize.X = sidecar.X
+		}
+		if tileSize.Y == 0 {
+			tileSize.Y = sidecar.Y
+		}
+	}
+	if tileSize.X <= 0 || tileSize.Y <= 0 {
+		return atlasInfo{}, usageError{message: fmt.Sprintf("invalid tile size %dx%d, use -tile-width and -tile-height or create %s", tileSize.X, tileSize.Y, sidecarFileName(o.atlasFile))}
+	}
+
+	file, err := os.Open(o.atlasFile)
+	if err != nil {
+		return atlasInfo{}, err
+	}
+	imageConfig, _, err := image.DecodeConfig(file)
+	file.Close()
+	if err != nil {
+		return atlasInfo{}, fmt.Errorf("could not read atlas '%s': %w", o.atlasFile, err)
+	}
+	if imageConfig.Width < tileSize.X || imageConfig.Height < tileSize.Y {
+		return atlasInfo{}, fmt.Errorf("atlas '%s' (%dx%d) is smaller than one tile (%dx%d)", o.atlasFile, imageConfig.Width, imageConfig.Height, tileSize.X, tileSize.Y)
+	}
+	return atlasInfo{
+		FileName:  o.atlasFile,
+		TileSize:  tileSize,
+		CellCount: geometry.Point{X: imageConfig.Width / tileSize.X, Y: imageConfig.Height / tileSize.Y},
+	}, nil
+}
+
+// displayOptions are the flags that choose the colors of the editor.
+type displayOptions struct {
+	theme       string
+	paletteFile string
+}
+
+func (o *displayOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.theme, "theme", darkTheme.Name, "colors of the editor, dark or light")
+	fs.StringVar(&o.paletteFile, "palette", "", "gpl or rec file with named colors for the color fields (default the 16 HTML colors)")
+}
+
+// apply sets the theme and the palette of the engine.
+func (o *displayOptions) apply(engine *Engine) error {
+	theme, err := themeByName(o.theme)
+	if err != nil {
+		return usageError{message: err.Error()}
+	}
+	engine.SetTheme(theme)
+	if o.paletteFile == "" {
+		return nil
+	}
+	palette, err := renderer.LoadPalette(o.paletteFile)
+	if err != nil {
+		return err
+	}
+	engine.SetPalette(palette)
+	return nil
+}
+
+func sidecarFileName(atlasFile string) string {
+	return atlasFile + ".rec"
+}
+
+// loadAtlasSidecar reads the tile size from a rec file like this one:
+//
+//	tile_width: 16
+//	tile_height: 16
+func loadAtlasSidecar(fileName string) (geometry.Point, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return geometry.Point{}, err
+	}
+	defer file.Close()
+	records := recfile.Read(file)
+	if len(records) == 0 {
+		return geometry.Point{}, fmt.Errorf("sidecar file '%s' contains no record", fileName)
+	}
+	data := records[0].ToMap(",")
+	return geometry.Point{
+		X: data.GetIntOrDefault("tile_width", 0),
+		Y: data.GetIntOrDefault("tile_height", 0),
+	}, nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// clipboardFallback keeps copied text inside of ReMapper if no clipboard tool is available.
+var clipboardFallback string
+
+// clipboardCommands returns the commands that write and read the system clipboard.
+func clipboardCommands() (copyCommand, pasteCommand []string) {
+	switch runtime.GOOS {
+	case "windows":
+		return []string{"powershell", "-NoProfile", "-Command", "[Console]::In.ReadToEnd() | Set-Clipboard"},
+			[]string{"powershell", "-NoProfile", "-Command", "Get-Clipboard -Raw"}
+	case "darwin":
+		return []string{"pbcopy"}, []string{"pbpaste"}
+	}
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		return []string{"wl-copy"}, []string{"wl-paste", "--no-newline"}
+	}
+	return []string{"xclip", "-selection", "clipboard"}, []string{"xclip", "-selection", "clipboard", "-o"}
+}
+
+// clipboardResult is what a clipboard command that ran next to the game loop reports back.
+type clipboardResult struct {
+	text    string
+	err     error
+	onPaste func(text string) // nil for copies
+}
+
+// clipboardState runs the clipboard tools outside of Update, powershell takes long enough to start
+// that waiting for it freezes the window.
+type clipboardState struct {
+	results   chan clipboardResult
+	isPasting bool
+}
+
+func newClipboardState() clipboardState {
+	return clipboardState{results: make(chan clipboardResult, 4)}
+}
+
+func (e *Engine) writeClipboard(text string) {
+	clipboardFallback = text
+	go func() {
+		if err := runCopyCommand(text); err != nil {
+			e.clipboard.results <- clipboardResult{err: fmt.Errorf("could not copy to the clipboard: %w", err)}
+		}
+	}()
+}
+
+// readClipboard calls onPaste from Update once the text has been read.
+func (e *Engine) readClipboard(onPaste func(text string)) {
+	if e.clipboard.isPasting {
+		return
+	}
+	e.clipboard.isPasting = true
+	go func() {
+		text, err := runPasteCommand()
+		if err != nil {
+			err = fmt.Errorf("could not read the clipboard: %w", err)
+		}
+		e.clipboard.results <- clipboardResult{text: text, err: err, onPaste: onPaste}
+	}()
+}
+
+// updateClipboard hands the results of the clipboard commands to the editor, errors are shown in the grid.
+func (e *Engine) updateClipboard() {
+	for {
+		select {
+		case result := <-e.clipboard.results:
+			if result.err != nil {
+				e.grid.errorText = result.err.Error()
+			}
+			if result.onPaste == nil {
+				continue
+			}
+			e.clipboard.isPasting = false
+			if result.err != nil {
+				if clipboardFallback == "" {
+					continue
+				}
+				result.text = clipboardFallback
+			}
+			result.onPaste(result.text)
+		default:
+			return
+		}
+	}
+}
+
+func runCopyCommand(text string) error {
+	copyCommand, _ := clipboardCommands()
+	command := exec.Command(copyCommand[0], copyCommand[1:]...)
+	command.Stdin = strings.NewReader(text)
+	return command.Run()
+}
+
+func runPasteCommand() (string, error) {
+	_, pasteCommand := clipboardCommands()
+	output, err := exec.Command(pasteCommand[0], pasteCommand[1:]...).Output()
+	if err != nil {
+		return "", err
+	}
+	return string(bytes.ReplaceAll(output, []byte("\r\n"), []byte("\n"))), nil
+}
@@ -0,0 +1,507 @@
+package main
+
+import (
+	"ReMapper/geometry"
+	"ReMapper/recfile"
+	"ReMapper/renderer"
+	"fmt"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"image/color"
+	"math"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	pickerFieldSize     = 200 // the saturation/value square on screen
+	pickerFieldPixels   = 100 // the resolution of the square
+	pickerHueWidth      = 24
+	pickerPreviewSize   = 90
+	pickerSwatchSize    = 22
+	pickerSwatchesInRow = 16
+)
+
+type pickerDrag int
+
+const (
+	pickerDragNone pickerDrag = iota
+	pickerDragField
+	pickerDragHue
+)
+
+type colorSwatch struct {
+	color  color.RGBA
+	bounds geometry.Rect
+}
+
+type pickerButton struct {
+	label  string
+	bounds geometry.Rect
+	action func()
+}
+
+// colorPicker edits the tint of the selected entries in HSV or by picking a swatch.
+// The color can also be typed as hex or r,g,b.
+type colorPicker struct {
+	isOpen      bool
+	keys        []string
+	field       string  // the color field that is edited
+	hue         float64 // in degrees
+	saturation  float64
+	value       float64
+	text        string
+	isTextValid bool
+	asTriple    bool // write the color as r,g,b instead of hex
+	drag        pickerDrag
+	fieldImage  *ebiten.Image
+	fieldHue    float64 // the hue fieldImage was drawn for
+	hueImage    *ebiten.Image
+	bounds      geometry.Rect
+	fieldRect   geometry.Rect
+	hueRect     geometry.Rect
+	previewPos  geometry.Point
+	textPos     geometry.PointF
+	swatches    []colorSwatch
+	buttons     []pickerButton
+}
+
+// tintOf returns the color the icon of an entry is drawn with.
+func (e *Engine) tintOf(key string) color.Color {
+	return e.tintOfRecord(e.keyRecords[key])
+}
+
+// tintOfRecord returns the foreground color of a record, fg_color takes precedence over color.
+func (e *Engine) tintOfRecord(record recfile.Record) color.Color {
+	if tint, isValid := e.parseColor(record.FindFirstFieldValue(e.config.FgColorField)); isValid {
+		return tint
+	}
+	if tint, isValid := e.parseColor(record.FindFirstFieldValue(e.config.ColorField)); isValid {
+		return tint
+	}
+	return color.White
+}
+
+func hsvToRGB(hue, saturation, value float64) color.RGBA {
+	hue = math.Mod(hue, 360)
+	if hue < 0 {
+		hue += 360
+	}
+	chroma := value * saturation
+	x := chroma * (1 - math.Abs(math.Mod(hue/60, 2)-1))
+	var r, g, b float64
+	switch {
+	case hue < 60:
+		r, g, b = chroma, x, 0
+	case hue < 120:
+		r, g, b = x, chroma, 0
+	case hue < 180:
+		r, g, b = 0, chroma, x
+	case hue < 240:
+		r, g, b = 0, x, chroma
+	case hue < 300:
+		r, g, b = x, 0, chroma
+	default:
+		r, g, b = chroma, 0, x
+	}
+	m := value - chroma
+	return color.RGBA{R: uint8(math.Round((r + m) * 255)), G: uint8(math.Round((g + m) * 255)), B: uint8(math.Round((b + m) * 255)), A: 255}
+}
+
+func rgbToHSV(c color.RGBA) (hue, saturation, value float64) {
+	r, g, b := float64(c.R)/255, float64(c.G)/255, float64(c.B)/255
+	maxChannel, minChannel := max(r, g, b), min(r, g, b)
+	delta := maxChannel - minChannel
+	value = maxChannel
+	if maxChannel > 0 {
+		saturation = delta / maxChannel
+	}
+	switch {
+	case delta == 0:
+		hue = 0
+	case maxChannel == r:
+		hue = 60 * math.Mod((g-b)/delta, 6)
+	case maxChannel == g:
+		hue = 60 * ((b-r)/delta + 2)
+	default:
+		hue = 60 * ((r-g)/delta + 4)
+	}
+	if hue < 0 {
+		hue += 360
+	}
+	return hue, saturation, value
+}
+
+// usedColors returns the distinct valid colors of all entries.
+func (e *Engine) usedColors() []color.RGBA {
+	var used []color.RGBA
+	for _, key := range e.orderedKeys {
+		for _, field := range e.keyRecords[key] {
+			if field.Name != e.config.ColorField && field.Name != e.config.FgColorField && field.Name != e.config.BgColorField {
+				continue
+			}
+			tint, isValid := e.parseColor(field.Value)
+			if isValid && !slices.Contains(used, tint) {
+				used = append(used, tint)
+			}
+		}
+	}
+	slices.SortFunc(used, func(a, b color.RGBA) int {
+		return strings.Compare(renderer.FormatColor(a, false), renderer.FormatColor(b, false))
+	})
+	return used
+}
+
+// pickerField returns the field that holds the color of the active layer of a record.
+func (e *Engine) pickerField(record recfile.Record) string {
+	if e.activeLayer == layerBackground {
+		return e.config.BgColorField
+	}
+	if _, hasFgColor := findField(record, e.config.FgColorField); hasFgColor {
+		return e.config.FgColorField
+	}
+	return e.config.ColorField
+}
+
+// openColorPicker edits the color of the active layer of all selected entries,
+// starting with the color of the primary one.
+func (e *Engine) openColorPicker() {
+	keys := e.selectedVisibleKeys()
+	if len(keys) == 0 {
+		if _, exists := e.iconMapping[e.selectedKey]; !exists {
+			return
+		}
+		keys = []string{e.selectedKey}
+	}
+	field := e.pickerField(e.keyRecords[e.selectedKey])
+	currentValue := e.keyRecords[e.selectedKey].FindFirstFieldValue(field)
+	e.colorPicker = colorPicker{isOpen: true, keys: keys, field: field, asTriple: strings.ContainsRune(currentValue, ','), fieldHue: -1}
+	currentColor, isValid := e.parseColor(currentValue)
+	if !isValid {
+		currentColor = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	}
+	e.setPickerColor(currentColor)
+}
+
+func (p *colorPicker) currentColor() color.RGBA {
+	return hsvToRGB(p.hue, p.saturation, p.value)
+}
+
+func (e *Engine) setPickerColor(c color.RGBA) {
+	p := &e.colorPicker
+	hue, saturation, value := rgbToHSV(c)
+	if saturation > 0 && value > 0 {
+		// grays keep the hue that was picked before
+		p.hue = hue
+	}
+	p.saturation, p.value = saturation, value
+	p.text = renderer.FormatColor(c, p.asTriple)
+	p.isTextValid = true
+}
+
+func (e *Engine) onPickerHSVChanged() {
+	p := &e.colorPicker
+	p.text = renderer.FormatColor(p.currentColor(), p.asTriple)
+	p.isTextValid = true
+}
+
+// applyPickedColor stores value as the color of the picked entries, an empty value removes their color.
+func (e *Engine) applyPickedColor(value string) {
+	p := &e.colorPicker
+	p.isOpen = false
+	target := p.keys[0]
+	if len(p.keys) > 1 {
+		target = fmt.Sprintf("%d entries", len(p.keys))
+	}
+	description := fmt.Sprintf("set %s of %s to %s", p.field, target, value)
+	if value == "" {
+		description = fmt.Sprintf("remove %s of %s", p.field, target)
+	}
+	colorField := p.field
+	e.editRecords(description, func(records []recfile.Record, mapping map[string]int32) ([]recfile.Record, string) {
+		for _, key := range p.keys {
+			recordIndex := e.recordIndexOfKey(records, key)
+			if recordIndex < 0 {
+				continue
+			}
+			if value == "" {
+				records[recordIndex] = slices.DeleteFunc(records[recordIndex], func(field recfile.Field) bool { return field.Name == colorField })
+			} else {
+				records[recordIndex] = setField(records[recordIndex], colorField, value)
+			}
+		}
+		return records, e.selectedKey
+	})
+}
+
+func (e *Engine) layoutColorPicker() {
+	p := &e.colorPicker
+	padding := int(e.padding)
+	_, textHeight := e.renderer.MeasureString("Ag")
+	lineHeight := int(textHeight) + padding
+
+	swatchStep := pickerSwatchSize + 4
+	width := max(padding*5+pickerFieldSize+pickerHueWidth+pickerPreviewSize, padding*2+pickerSwatchesInRow*swatchStep)
+	// the colors of the palette are offered first, then the colors already used in the mapping
+	paletteColors := e.palette.Colors()
+	usedColors := e.usedColors()
+	swatchRows := (len(paletteColors)+pickerSwatchesInRow-1)/pickerSwatchesInRow + (len(usedColors)+pickerSwatchesInRow-1)/pickerSwatchesInRow
+	height := padding + lineHeight + pickerFieldSize + padding + swatchRows*swatchStep + padding + lineHeight*2 + padding
+
+	screenSize := e.deviceIndependentScreenSize
+	pos := geometry.Point{X: (screenSize.X - width) / 2, Y: (screenSize.Y - height) / 2}
+	p.bounds = geometry.NewRect(pos.X, pos.Y, pos.X+width, pos.Y+height)
+
+	y := pos.Y + padding + lineHeight
+	x := pos.X + padding
+	p.fieldRect = geometry.NewRect(x, y, x+pickerFieldSize, y+pickerFieldSize)
+	x += pickerFieldSize + padding
+	p.hueRect = geometry.NewRect(x, y, x+pickerHueWidth, y+pickerFieldSize)
+	x += pickerHueWidth + padding
+	p.previewPos = geometry.Point{X: x, Y: y}
+
+	y += pickerFieldSize + padding
+	p.swatches = p.swatches[:0]
+	for _, row := range [][]color.RGBA{paletteColors, usedColors} {
+		for index, swatchColor := range row {
+			if index > 0 && index%pickerSwatchesInRow == 0 {
+				y += swatchStep
+			}
+			swatchX := pos.X + padding + (index%pickerSwatchesInRow)*swatchStep
+			p.swatches = append(p.swatches, colorSwatch{color: swatchColor, bounds: geometry.NewRect(swatchX, y, swatchX+pickerSwatchSize, y+pickerSwatchSize)})
+		}
+		if len(row) > 0 {
+			y += swatchStep
+		}
+	}
+
+	y += padding
+	p.textPos = geometry.PointF{X: float64(pos.X + padding), Y: float64(y) + textHeight}
+	y += lineHeight
+	buttons := []pickerButton{
+		{label: "OK", action: func() {
+			if p.isTextValid {
+				e.applyPickedColor(p.text)
+			}
+		}},
+		{label: "No color", action: func() { e.applyPickedColor("") }},
+		{label: "Cancel", action: func() { p.isOpen = false }},
+	}
+	buttonX := pos.X + padding
+	for index, button := range buttons {
+		labelWidth, _ := e.renderer.MeasureString(button.label)
+		buttonWidth := int(labelWidth) + padding*2
+		buttons[index].bounds = geometry.NewRect(buttonX, y, buttonX+buttonWidth, y+lineHeight)
+		buttonX += buttonWidth + padding
+	}
+	p.buttons = buttons
+}
+
+// handleColorPicker consumes all input while the picker is open.
+func (e *Engine) handleColorPicker() bool {
+	p := &e.colorPicker
+	if !p.isOpen {
+		return false
+	}
+	e.layoutColorPicker()
+	if e.handleColorPickerMouse() {
+		return true
+	}
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyEscape):
+		p.isOpen = false
+	case isEnterJustPressed():
+		if p.isTextValid {
+			e.applyPickedColor(p.text)
+		}
+	case isKeyRepeated(ebiten.KeyBackspace):
+		if p.text != "" {
+			_, lastSize := utf8.DecodeLastRuneInString(p.text)
+			p.text = p.text[:len(p.text)-lastSize]
+			e.onPickerTextChanged()
+		}
+	default:
+		e.inputChars = ebiten.AppendInputChars(e.inputChars[:0])
+		if isControlPressed() {
+			return true
+		}
+		for _, char := range e.inputChars {
+			if unicode.IsPrint(char) {
+				p.text += string(char)
+				e.onPickerTextChanged()
+			}
+		}
+	}
+	return true
+}
+
+// onPickerTextChanged follows a typed color if it can be parsed.
+func (e *Engine) onPickerTextChanged() {
+	p := &e.colorPicker
+	typedColor, isValid := e.parseColor(p.text)
+	p.isTextValid = isValid
+	if !isValid {
+		return
+	}
+	p.asTriple = strings.ContainsRune(p.text, ',')
+	text := p.text
+	e.setPickerColor(typedColor)
+	p.text = text
+}
+
+func (e *Engine) handleColorPickerMouse() bool {
+	p := &e.colorPicker
+	mousePos := e.mousePosInPixels
+	if p.drag != pickerDragNone {
+		if !ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
+			p.drag = pickerDragNone
+			return true
+		}
+		e.dragPicker(mousePos)
+		return true
+	}
+	if !inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		return false
+	}
+	switch {
+	case p.fieldRect.Contains(mousePos):
+		p.drag = pickerDragField
+		e.dragPicker(mousePos)
+	case p.hueRect.Contains(mousePos):
+		p.drag = pickerDragHue
+		e.dragPicker(mousePos)
+	case !p.bounds.Contains(mousePos):
+		p.isOpen = false
+	}
+	for _, swatch := range p.swatches {
+		if swatch.bounds.Contains(mousePos) {
+			e.setPickerColor(swatch.color)
+		}
+	}
+	for _, button := range p.buttons {
+		if button.bounds.Contains(mousePos) {
+			button.action()
+		}
+	}
+	return true
+}
+
+func (e *Engine) dragPicker(mousePos geometry.Point) {
+	p := &e.colorPicker
+	fractionOf := func(value, start, size int) float64 {
+		return math.Max(0, math.Min(1, float64(value-start)/float64(size)))
+	}
+	if p.drag == pickerDragField {
+		p.saturation = fractionOf(mousePos.X, p.fieldRect.Min.X, pickerFieldSize)
+		p.value = 1 - fractionOf(mousePos.Y, p.fieldRect.Min.Y, pickerFieldSize)
+	} else {
+		p.hue = fractionOf(mousePos.Y, p.hueRect.Min.Y, pickerFieldSize) * 359.9
+	}
+	e.onPickerHSVChanged()
+}
+
+// updatePickerImages redraws the saturation/value square when the hue changed.
+func (p *colorPicker) updatePickerImages() {
+	if p.hueImage == nil {
+		pixels := make([]byte, pickerFieldPixels*4)
+		for y := 0; y < pickerFieldPixels; y++ {
+			c := hsvToRGB(float64(y)/pickerFieldPixels*360, 1, 1)
+			copy(pixels[y*4:], []byte{c.R, c.G, c.B, 255})
+		}
+		p.hueImage = ebiten.NewImage(1, pickerFieldPixels)
+		p.hueImage.WritePixels(pixels)
+	}
+	if p.fieldImage != nil && p.fieldHue == p.hue {
+		return
+	}
+	if p.fieldImage == nil {
+		p.fieldImage = ebiten.NewImage(pickerFieldPixels, pickerFieldPixels)
+	}
+	pixels := make([]byte, pickerFieldPixels*pickerFieldPixels*4)
+	for y := 0; y < pickerFieldPixels; y++ {
+		for x := 0; x < pickerFieldPixels; x++ {
+			c := hsvToRGB(p.hue, float64(x)/(pickerFieldPixels-1), 1-float64(y)/(pickerFieldPixels-1))
+			copy(pixels[(y*pickerFieldPixels+x)*4:], []byte{c.R, c.G, c.B, 255})
+		}
+	}
+	p.fieldImage.WritePixels(pixels)
+	p.fieldHue = p.hue
+}
+
+func (e *Engine) drawColorPicker() {
+	p := &e.colorPicker
+	e.layoutColorPicker()
+	p.updatePickerImages()
+	_, textHeight := e.renderer.MeasureString("Ag")
+	outlineColor := e.theme.Border
+
+	e.renderer.DrawFilledRect(p.bounds.Min, p.bounds.Size(), withAlpha(e.theme.Panel, 245))
+	e.renderer.DrawRectOutline(p.bounds.Min, p.bounds.Size(), 2, e.theme.Cursor)
+	title := fmt.Sprintf("%s of %s", p.field, p.keys[0])
+	if len(p.keys) > 1 {
+		title = fmt.Sprintf("%s of %d entries", p.field, len(p.keys))
+	}
+	e.renderer.DrawTTFOnScreen(float64(p.bounds.Min.X)+e.padding, float64(p.bounds.Min.Y)+e.padding+textHeight-2, title, e.theme.Text)
+
+	// saturation/value square and hue bar with their markers
+	e.renderer.DrawImageOnScreen(p.fieldRect.Min.X, p.fieldRect.Min.Y, p.fieldRect.Size(), p.fieldImage)
+	e.renderer.DrawImageOnScreen(p.hueRect.Min.X, p.hueRect.Min.Y, p.hueRect.Size(), p.hueImage)
+	markerColor := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	if p.value > 0.6 && p.saturation < 0.4 {
+		markerColor = color.RGBA{A: 255}
+	}
+	markerPos := geometry.Point{
+		X: p.fieldRect.Min.X + int(p.saturation*pickerFieldSize) - 4,
+		Y: p.fieldRect.Min.Y + int((1-p.value)*pickerFieldSize) - 4,
+	}
+	e.renderer.DrawRectOutline(markerPos, geometry.Point{X: 8, Y: 8}, 2, markerColor)
+	hueY := p.hueRect.Min.Y + int(p.hue/360*pickerFieldSize)
+	e.renderer.DrawRectOutline(geometry.Point{X: p.hueRect.Min.X - 2, Y: hueY - 2}, geometry.Point{X: pickerHueWidth + 4, Y: 4}, 2, color.White)
+
+	// the icon with the picked tint on a dark and on a light background
+	pickedColor := p.currentColor()
+	previewLayers := e.layersOf(p.keys[0])
+	if p.field == e.config.BgColorField {
+		previewLayers.bgColor, previewLayers.hasBgColor = pickedColor, true
+	} else {
+		previewLayers.fgColor = pickedColor
+	}
+	tileSize := e.tileAtlas.GetTileSize()
+	previewScale := float64(pickerPreviewSize) / float64(max(tileSize.X, tileSize.Y))
+	for index, background := range []color.RGBA{{R: 16, G: 16, B: 20, A: 255}, {R: 200, G: 200, B: 205, A: 255}} {
+		boxPos := p.previewPos.Add(geometry.Point{Y: index * (pickerPreviewSize + int(e.padding)*2)})
+		boxSize := geometry.Point{X: pickerPreviewSize, Y: pickerPreviewSize}
+		e.renderer.DrawFilledRect(boxPos, boxSize, background)
+		e.drawLayers(float64(boxPos.X), float64(boxPos.Y), previewLayers, previewScale)
+		e.renderer.DrawRectOutline(boxPos, boxSize, 1, outlineColor)
+	}
+
+	for _, swatch := range p.swatches {
+		e.renderer.DrawFilledRect(swatch.bounds.Min, swatch.bounds.Size(), swatch.color)
+		swatchOutline := outlineColor
+		if swatch.color == pickedColor {
+			swatchOutline = e.theme.Cursor
+		}
+		e.renderer.DrawRectOutline(swatch.bounds.Min, swatch.bounds.Size(), 1, swatchOutline)
+	}
+
+	textColor := color.Color(e.theme.Text)
+	label := fmt.Sprintf("Color: %s_", p.text)
+	if !p.isTextValid {
+		textColor = e.theme.Current
+		label += "  (hex like #ff8000 or r,g,b)"
+	}
+	e.renderer.DrawTTFOnScreen(p.textPos.X, p.textPos.Y, label, textColor)
+
+	for _, button := range p.buttons {
+		buttonColor := e.theme.Button
+		if button.bounds.Contains(e.mousePosInPixels) {
+			buttonColor = e.theme.ButtonHover
+		}
+		e.renderer.DrawFilledRect(button.bounds.Min, button.bounds.Size(), buttonColor)
+		e.renderer.DrawRectOutline(button.bounds.Min, button.bounds.Size(), 1, outlineColor)
+		e.renderer.DrawTTFOnScreen(float64(button.bounds.Min.X)+e.padding, float64(button.bounds.Max.Y)-e.padding/2-2, button.label, e.theme.Text)
+	}
+}
@@ -0,0 +1,446 @@
+package main
+
+import (
+	"ReMapper/recfile"
+	"ReMapper/renderer"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+func editCommand(fs *flag.FlagSet, stdout io.Writer) func(args []string) error {
+	var mappingOpts mappingOptions
+	var atlasOpts atlasOptions
+	var displayOpts displayOptions
+	mappingOpts.register(fs)
+	atlasOpts.register(fs)
+	displayOpts.register(fs)
+	autosaveInterval := fs.Duration("autosave", 0, "write unsaved changes to <mapping>.recovery in this interval, e.g. 2m (default off)")
+	mapFile := fs.String("map", "", "sample map to preview the mapping with, a rec map or a text grid of keys")
+	return func(args []string) error {
+		if err := expectArgs(args, 1); err != nil {
+			return err
+		}
+		if *autosaveInterval < 0 {
+			return usageError{message: "the autosave interval must not be negative"}
+		}
+		atlasInfo, err := atlasOpts.resolve(true)
+		if err != nil {
+			return err
+		}
+		mappingFileName := args[0]
+		config, originalRecords, mapping, err := mappingOpts.load(mappingFileName)
+		if err != nil {
+			return err
+		}
+		atlas := renderer.NewTextureAtlas(atlasInfo.FileName, atlasInfo.TileSize.X, atlasInfo.TileSize.Y)
+
+		engine := NewEngine(1200, 800, "ReMapper")
+		engine.SetTTFFont(mustOpenEmbedded("FiraSans-Regular.ttf"), 16)
+		engine.SetAtlas(atlas)
+		if err := displayOpts.apply(engine); err != nil {
+			return err
+		}
+		engine.SetMapping(mappingFileName, config, mapping, originalRecords)
+		engine.EnableAutosave(*autosaveInterval)
+		if *mapFile != "" {
+			if err := engine.OpenMapPreview(*mapFile); err != nil {
+				return err
+			}
+		}
+		engine.OfferRecovery()
+
+		return runAppWithEbiten(engine)
+	}
+}
+
+func fontCommand(fs *flag.FlagSet, stdout io.Writer) func(args []string) error {
+	var atlasOpts atlasOptions
+	var displayOpts displayOptions
+	atlasOpts.register(fs)
+	displayOpts.register(fs)
+	sample := fs.String("sample", defaultFontSample, "text shown in the bitmap font, \\n starts a new line")
+	return func(args []string) error {
+		if err := expectArgs(args, 1); err != nil {
+			return err
+		}
+		atlasInfo, err := atlasOpts.resolve(true)
+		if err != nil {
+			return err
+		}
+		fontFileName := args[0]
+		config := fontMappingConfig()
+		records, mapping, err := buildCurrentMapping(fontFileName, config)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			// a new font map, it is written on the first save
+			records, mapping = newFontRecords(config)
+		case err != nil:
+			return err
+		default:
+			mapping = normalizeFontKeys(records, config)
+		}
+		atlas := renderer.NewTextureAtlas(atlasInfo.FileName, atlasInfo.TileSize.X, atlasInfo.TileSize.Y)
+
+		engine := NewEngine(1200, 800, "ReMapper")
+		engine.SetTTFFont(mustOpenEmbedded("FiraSans-Regular.ttf"), 16)
+		engine.SetAtlas(atlas)
+		if err := displayOpts.apply(engine); err != nil {
+			return err
+		}
+		engine.SetFontMode(*sample)
+		engine.SetMapping(fontFileName, config, mapping, records)
+
+		return runAppWithEbiten(engine)
+	}
+}
+
+func checkCommand(fs *flag.FlagSet, stdout io.Writer) func(args []string) error {
+	var mappingOpts mappingOptions
+	var atlasOpts atlasOptions
+	mappingOpts.register(fs)
+	atlasOpts.register(fs)
+	quiet := fs.Bool("q", false, "only report problems, no warnings")
+	return func(args []string) error {
+		if err := expectArgs(args, 1); err != nil {
+			return err
+		}
+		atlasInfo, err := atlasOpts.resolve(false)
+		if err != nil {
+			return err
+		}
+		config, records, _, err := mappingOpts.load(args[0])
+		if err != nil {
+			return err
+		}
+		report := analyzeMapping(records, config, atlasInfo.CellCount)
+		for _, recIndex := range report.MissingKey {
+			fmt.Fprintf(stdout, "error: %s has no '%s' field\n", describeRecord(recIndex), config.KeyField)
+		}
+		for _, key := range report.DuplicateKeys {
+			fmt.Fprintf(stdout, "error: duplicate key '%s'\n", key)
+		}
+		for _, key := range report.InvalidIcon {
+			fmt.Fprintf(stdout, "error: '%s' has a non-numeric '%s' field\n", key, config.IconField)
+		}
+		for _, key := range report.OutOfRange {
+			fmt.Fprintf(stdout, "error: icon of '%s' is outside of the atlas\n", key)
+		}
+		for _, key := range sortedKeys(report.AutotileProblems) {
+			fmt.Fprintf(stdout, "error: auto-tile table of '%s': %s\n", key, report.AutotileProblems[key])
+		}
+		if !*quiet {
+			for _, key := range report.MissingIcon {
+				fmt.Fprintf(stdout, "warning: '%s' has no '%s' field, it will be added on save\n", key, config.IconField)
+			}
+		}
+		if report.ProblemCount() > 0 {
+			fmt.Fprintf(stdout, "%d problem(s) in %s\n", report.ProblemCount(), args[0])
+			return errProblemsFound
+		}
+		return nil
+	}
+}
+
+func statsCommand(fs *flag.FlagSet, stdout io.Writer) func(args []string) error {
+	var mappingOpts mappingOptions
+	var atlasOpts atlasOptions
+	mappingOpts.register(fs)
+	atlasOpts.register(fs)
+	return func(args []string) error {
+		if err := expectArgs(args, 1); err != nil {
+			return err
+		}
+		atlasInfo, err := atlasOpts.resolve(false)
+		if err != nil {
+			return err
+		}
+		config, records, mapping, err := mappingOpts.load(args[0])
+		if err != nil {
+			return err
+		}
+		report := analyzeMapping(records, config, atlasInfo.CellCount)
+		sharedIcons := report.sharedIcons()
+
+		fmt.Fprintf(stdout, "records:        %d\n", report.RecordCount)
+		fmt.Fprintf(stdout, "entries:        %d\n", len(mapping))
+		fmt.Fprintf(stdout, "unique icons:   %d\n", len(report.UsersOfIcon))
+		fmt.Fprintf(stdout, "shared icons:   %d\n", len(sharedIcons))
+		fmt.Fprintf(stdout, "missing icons:  %d\n", len(report.MissingIcon))
+		fmt.Fprintf(stdout, "problems:       %d\n", report.ProblemCount())
+		if atlasInfo.FileName != "" {
+			cellCount := atlasInfo.CellCount.X * atlasInfo.CellCount.Y
+			fmt.Fprintf(stdout, "atlas cells:    %d (%dx%d)\n", cellCount, atlasInfo.CellCount.X, atlasInfo.CellCount.Y)
+			fmt.Fprintf(stdout, "unused cells:   %d\n", report.UnusedCellCount)
+			fmt.Fprintf(stdout, "out of range:   %d\n", len(report.OutOfRange))
+		}
+		for _, icon := range sharedIcons {
+			fmt.Fprintf(stdout, "icon %d is used by %s\n", icon, strings.Join(report.UsersOfIcon[icon], ", "))
+		}
+		return nil
+	}
+}
+
+func exportCommand(fs *flag.FlagSet, stdout io.Writer) func(args []string) error {
+	var mappingOpts mappingOptions
+	mappingOpts.register(fs)
+	exportFormat := fs.String("format", "csv", "output format: csv, tsv or json")
+	outputFile := fs.String("o", "", "output file (default stdout)")
+	return func(args []string) error {
+		if err := expectArgs(args, 1); err != nil {
+			return err
+		}
+		if *exportFormat != "csv" && *exportFormat != "tsv" && *exportFormat != "json" {
+			return usageError{message: fmt.Sprintf("unknown format '%s'", *exportFormat)}
+		}
+		config, records, mapping, err := mappingOpts.load(args[0])
+		if err != nil {
+			return err
+		}
+		fieldNames := append([]string{config.KeyField, config.IconField}, config.LabelFields...)
+		var exportRecords []recfile.Record
+		for _, key := range sortedKeys(mapping) {
+			exportRecord := recfile.Record{
+				{Name: config.KeyField, Value: key},
+				{Name: config.IconField, Value: recfile.Int32Str(mapping[key])},
+			}
+			original := findRecord(records, config.KeyField, key)
+			for _, labelField := range config.LabelFields {
+				exportRecord = append(exportRecord, recfile.Field{Name: labelField, Value: original.FindFirstFieldValue(labelField)})
+			}
+			exportRecords = append(exportRecords, exportRecord)
+		}
+
+		output := stdout
+		if *outputFile != "" {
+			file, createErr := os.Create(*outputFile)
+			if createErr != nil {
+				return createErr
+			}
+			defer file.Close()
+			output = file
+		}
+		switch *exportFormat {
+		case "csv":
+			recfile.WriteCSV(output, fieldNames, exportRecords)
+		case "tsv":
+			tsvWriter := csv.NewWriter(output)
+			tsvWriter.Comma = '\t'
+			tsvWriter.Write(fieldNames)
+			for _, record := range exportRecords {
+				tsvWriter.Write(record.ToFixedSizeValueList(fieldNames))
+			}
+			tsvWriter.Flush()
+			return tsvWriter.Error()
+		case "json":
+			var entries []map[string]any
+			for _, record := range exportRecords {
+				entry := make(map[string]any, len(record))
+				for _, field := range record {
+					entry[field.Name] = field.Value
+				}
+				entry[config.IconField] = mapping[record.FindFirstFieldValue(config.KeyField)]
+				entries = append(entries, entry)
+			}
+			encoder := json.NewEncoder(output)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(entries)
+		}
+		return nil
+	}
+}
+
+func applyCommand(fs *flag.FlagSet, stdout io.Writer) func(args []string) error {
+	var mappingOpts mappingOptions
+	mappingOpts.register(fs)
+	outputFile := fs.String("o", "", "write the result to this file instead of the mapping file")
+	dryRun := fs.Bool("n", false, "only print the changes")
+	return func(args []string) error {
+		if err := expectArgs(args, 2); err != nil {
+			return err
+		}
+		config, records, mapping, err := mappingOpts.load(args[0])
+		if err != nil {
+			return err
+		}
+		assignments, err := readAssignments(args[1], config)
+		if err != nil {
+			return err
+		}
+		changeCount := 0
+		for _, key := range sortedKeys(assignments) {
+			oldIcon, exists := mapping[key]
+			newIcon := assignments[key]
+			if !exists {
+				fmt.Fprintf(fs.Output(), "skipping unknown key '%s'\n", key)
+				continue
+			}
+			if oldIcon == newIcon && findRecordHasField(records, config, key) {
+				continue
+			}
+			fmt.Fprintf(stdout, "%s: %d -> %d\n", key, oldIcon, newIcon)
+			mapping[key] = newIcon
+			changeCount++
+		}
+		fmt.Fprintf(stdout, "%d change(s)\n", changeCount)
+		if *dryRun || changeCount == 0 {
+			return nil
+		}
+		applyIconMapping(records, config, mapping)
+		sections, err := loadRecSections(args[0])
+		if err != nil {
+			return err
+		}
+		target := args[0]
+		if *outputFile != "" {
+			target = *outputFile
+		}
+		return writeMappingFile(target, records, sections)
+	}
+}
+
+// readAssignments reads key to icon assignments from a rec file or from a csv/tsv
+// file whose header names the key and icon fields.
+func readAssignments(fileName string, config MappingConfig) (map[string]int32, error) {
+	extension := strings.ToLower(filepath.Ext(fileName))
+	if extension == ".rec" {
+		_, assignments, err := buildCurrentMapping(fileName, config)
+		return assignments, err
+	}
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	csvReader := csv.NewReader(file)
+	if extension == ".tsv" {
+		csvReader.Comma = '\t'
+	}
+	rows, err := csvReader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("'%s' is empty", fileName)
+	}
+	keyColumn, iconColumn := -1, -1
+	for column, name := range rows[0] {
+		switch name {
+		case config.KeyField:
+			keyColumn = column
+		case config.IconField:
+			iconColumn = column
+		}
+	}
+	if keyColumn < 0 || iconColumn < 0 {
+		return nil, fmt.Errorf("'%s' needs a header with the columns '%s' and '%s'", fileName, config.KeyField, config.IconField)
+	}
+	assignments := make(map[string]int32)
+	for rowIndex, row := range rows[1:] {
+		icon, parseErr := strconv.ParseInt(strings.TrimSpace(row[iconColumn]), 10, 32)
+		if parseErr != nil {
+			return nil, fmt.Errorf("%s:%d: invalid icon '%s'", fileName, rowIndex+2, row[iconColumn])
+		}
+		assignments[row[keyColumn]] = int32(icon)
+	}
+	return assignments, nil
+}
+
+func findRecord(records []recfile.Record, keyField, key string) recfile.Record {
+	for _, record := range records {
+		if record.FindFirstFieldValue(keyField) == key {
+			return record
+		}
+	}
+	return nil
+}
+
+func findRecordHasField(records []recfile.Record, config MappingConfig, key string) bool {
+	_, hasField := findField(findRecord(records, config.KeyField, key), config.IconField)
+	return hasField
+}
+
+func genCommand(fs *flag.FlagSet, stdout io.Writer) func(args []string) error {
+	var mappingOpts mappingOptions
+	mappingOpts.register(fs)
+	packageName := fs.String("package", "tiles", "name of the generated package")
+	prefix := fs.String("prefix", "Icon", "prefix of the generated constant names")
+	outputFile := fs.String("o", "", "output file (default stdout)")
+	return func(args []string) error {
+		if err := expectArgs(args, 1); err != nil {
+			return err
+		}
+		_, _, mapping, err := mappingOpts.load(args[0])
+		if err != nil {
+			return err
+		}
+		source, err := generateGoSource(*packageName, *prefix, mapping)
+		if err != nil {
+			return err
+		}
+		if *outputFile == "" {
+			_, err = stdout.Write(source)
+			return err
+		}
+		return os.WriteFile(*outputFile, source, 0644)
+	}
+}
+
+func generateGoSource(packageName, prefix string, mapping map[string]int32) ([]byte, error) {
+	var buffer bytes.Buffer
+	fmt.Fprintln(&buffer, "// Code generated by remapper gen; DO NOT EDIT.")
+	fmt.Fprintln(&buffer)
+	fmt.Fprintf(&buffer, "package %s\n\n", packageName)
+
+	keys := sortedKeys(mapping)
+	usedNames := make(map[string]bool)
+	fmt.Fprintln(&buffer, "const (")
+	for _, key := range keys {
+		baseName := prefix + goIdentifier(key)
+		if baseName == "" || unicode.IsDigit([]rune(baseName)[0]) {
+			baseName = "_" + baseName
+		}
+		name := baseName
+		for suffix := 2; usedNames[name]; suffix++ {
+			name = baseName + strconv.Itoa(suffix)
+		}
+		usedNames[name] = true
+		fmt.Fprintf(&buffer, "%s int32 = %d\n", name, mapping[key])
+	}
+	fmt.Fprintln(&buffer, ")")
+	fmt.Fprintln(&buffer)
+	fmt.Fprintf(&buffer, "var %sByName = map[string]int32{\n", prefix)
+	for _, key := range keys {
+		fmt.Fprintf(&buffer, "%s: %d,\n", strconv.Quote(key), mapping[key])
+	}
+	fmt.Fprintln(&buffer, "}")
+	return format.Source(buffer.Bytes())
+}
+
+// goIdentifier turns "wall_stone-corner" into "WallStoneCorner".
+func goIdentifier(key string) string {
+	var builder strings.Builder
+	upperNext := true
+	for _, char := range key {
+		if !unicode.IsLetter(char) && !unicode.IsDigit(char) {
+			upperNext = true
+			continue
+		}
+		if upperNext {
+			char = unicode.ToUpper(char)
+			upperNext = false
+		}
+		builder.WriteRune(char)
+	}
+	return builder.String()
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"ReMapper/autotile"
+	"ReMapper/recfile"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// MappingConfig names the fields of a rec file that ReMapper works with.
+// KeyField identifies a record, IconField holds the atlas index and the
+// optional LabelFields are shown next to the key in the list.
+// GroupSeparator splits keys into groups for the tree view, GroupField names
+// the field that is used when grouping by field. The optional ColorField tints the icon.
+// BgIconField, FgColorField and BgColorField describe an optional background tile
+// below the icon and the colors of both layers; FgColorField takes precedence over ColorField.
+// FlipXField, FlipYField and RotateField mirror and turn the icon. FramesField lists the
+// frames of an animation, FrameCountField animates the icon and the cells after it instead,
+// FrameTicksField is the default duration of a frame. OpaqueField and WalkableField mark
+// entries that block the sight and entries that can be walked on in the playtest.
+// AutotileField holds the kind of an auto-tile table, AutotileTilesField its icons by
+// neighbor mask and AutotileConnectField the other keys that join the entry.
+type MappingConfig struct {
+	KeyField             string
+	IconField            string
+	LabelFields          []string
+	GroupSeparator       string
+	GroupField           string
+	ColorField           string
+	BgIconField          string
+	FgColorField         string
+	BgColorField         string
+	FlipXField           string
+	FlipYField           string
+	RotateField          string
+	FramesField          string
+	FrameCountField      string
+	FrameTicksField      string
+	OpaqueField          string
+	WalkableField        string
+	AutotileField        string
+	AutotileTilesField   string
+	AutotileConnectField string
+}
+
+func DefaultMappingConfig() MappingConfig {
+	return MappingConfig{
+		KeyField:             "internal_name",
+		IconField:            "icon",
+		GroupSeparator:       "_",
+		GroupField:           "category",
+		ColorField:           "color",
+		BgIconField:          "bg_icon",
+		FgColorField:         "fg_color",
+		BgColorField:         "bg_color",
+		FlipXField:           "flip_x",
+		FlipYField:           "flip_y",
+		RotateField:          "rotate",
+		FramesField:          "frames",
+		FrameCountField:      "frame_count",
+		FrameTicksField:      "frame_ticks",
+		OpaqueField:          "opaque",
+		WalkableField:        "walkable",
+		AutotileField:        "autotile",
+		AutotileTilesField:   "autotile_tiles",
+		AutotileConnectField: "autotile_connect",
+	}
+}
+
+// LoadMappingConfig reads a config rec file like this one:
+//
+//	key_field: id
+//	icon_field: glyph_index
+//	label_field: name
+//	group_separator: .
+//	group_field: kind
+//	color_field: tint
+//	bg_icon_field: background
+//	fg_color_field: glyph_color
+//	bg_color_field: background_color
+//	flip_x_field: mirrored
+//	flip_y_field: upside_down
+//	rotate_field: rotation
+//	frames_field: animation
+//	frame_count_field: animation_length
+//	frame_ticks_field: animation_speed
+//	opaque_field: blocks_sight
+//	walkable_field: passable
+//	autotile_field: tiling
+//	autotile_tiles_field: tiling_icons
+//	autotile_connect_field: tiling_joins
+//
+// label_field may be repeated. Fields that are not present keep the values of base.
+func LoadMappingConfig(fileName string, base MappingConfig) (MappingConfig, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return base, err
+	}
+	defer file.Close()
+
+	records := recfile.Read(file)
+	if len(records) == 0 {
+		return base, fmt.Errorf("config file '%s' contains no record", fileName)
+	}
+
+	config := base
+	var labelFields []string
+	for _, field := range records[0] {
+		switch field.Name {
+		case "key_field":
+			config.KeyField = field.Value
+		case "icon_field":
+			config.IconField = field.Value
+		case "label_field":
+			labelFields = append(labelFields, field.Value)
+		case "group_separator":
+			config.GroupSeparator = field.Value
+		case "group_field":
+			config.GroupField = field.Value
+		case "color_field":
+			config.ColorField = field.Value
+		case "bg_icon_field":
+			config.BgIconField = field.Value
+		case "fg_color_field":
+			config.FgColorField = field.Value
+		case "bg_color_field":
+			config.BgColorField = field.Value
+		case "flip_x_field":
+			config.FlipXField = field.Value
+		case "flip_y_field":
+			config.FlipYField = field.Value
+		case "rotate_field":
+			config.RotateField = field.Value
+		case "frames_field":
+			config.FramesField = field.Value
+		case "frame_count_field":
+			config.FrameCountField = field.Value
+		case "frame_ticks_field":
+			config.FrameTicksField = field.Value
+		case "opaque_field":
+			config.OpaqueField = field.Value
+		case "walkable_field":
+			config.WalkableField = field.Value
+		case "autotile_field":
+			config.AutotileField = field.Value
+		case "autotile_tiles_field":
+			config.AutotileTilesField = field.Value
+		case "autotile_connect_field":
+			config.AutotileConnectField = field.Value
+		}
+	}
+	if len(labelFields) > 0 {
+		config.LabelFields = labelFields
+	}
+	return config, config.Validate()
+}
+
+func (c MappingConfig) Validate() error {
+	if c.KeyField == "" {
+		return fmt.Errorf("no key field configured")
+	}
+	if c.IconField == "" {
+		return fmt.Errorf("no icon field configured")
+	}
+	if c.KeyField == c.IconField {
+		return fmt.Errorf("key field and icon field must differ, both are '%s'", c.KeyField)
+	}
+	if c.BgIconField == c.IconField {
+		return fmt.Errorf("icon field and background icon field must differ, both are '%s'", c.IconField)
+	}
+	return nil
+}
+
+// DisplayLabel returns the key followed by the values of the label fields in parentheses.
+func (c MappingConfig) DisplayLabel(key string, record recfile.Record) string {
+	var labels []string
+	for _, labelField := range c.LabelFields {
+		if value := record.FindFirstFieldValue(labelField); value != "" {
+			labels = append(labels, value)
+		}
+	}
+	if len(labels) == 0 {
+		return key
+	}
+	return fmt.Sprintf("%s (%s)", key, strings.Join(labels, ", "))
+}
+
+// AutotileFields returns the field names that autotile.FromRecords reads.
+func (c MappingConfig) AutotileFields() autotile.Fields {
+	return autotile.Fields{Key: c.KeyField, Kind: c.AutotileField, Tiles: c.AutotileTilesField, Connect: c.AutotileConnectField}
+}
+
+// stringListFlag collects the values of a flag that may be given multiple times.
+type stringListFlag []string
+
+func (s *stringListFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringListFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
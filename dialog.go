@@ -0,0 +1,128 @@
+package main
+
+import (
+	"ReMapper/geometry"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+type dialogButton struct {
+	Label  string
+	Key    ebiten.Key
+	Action func()
+	bounds geometry.Rect
+}
+
+// dialog is a modal message box. While it is open it receives all input.
+type dialog struct {
+	Title   string
+	Lines   []string
+	Buttons []dialogButton
+
+	confirmsQuit bool // the dialog asks what to do before quitting
+}
+
+func (e *Engine) openDialog(d *dialog) {
+	e.activeDialog = d
+}
+
+func (e *Engine) closeDialog() {
+	e.activeDialog = nil
+}
+
+// cancelDialog closes the dialog like its Escape button would.
+func (e *Engine) cancelDialog() {
+	d := e.activeDialog
+	e.closeDialog()
+	for _, button := range d.Buttons {
+		if button.Key == ebiten.KeyEscape {
+			button.Action()
+			return
+		}
+	}
+}
+
+// handleDialogInput returns true while a dialog is open, so no other input is processed.
+func (e *Engine) handleDialogInput() bool {
+	d := e.activeDialog
+	if d == nil {
+		return false
+	}
+	for _, button := range d.Buttons {
+		if inpututil.IsKeyJustPressed(button.Key) {
+			e.closeDialog()
+			button.Action()
+			return true
+		}
+	}
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		for _, button := range d.Buttons {
+			if button.bounds.Contains(e.mousePosInPixels) {
+				e.closeDialog()
+				button.Action()
+				return true
+			}
+		}
+	}
+	// drop the typed characters, so they don't end up in the search box later
+	e.inputChars = ebiten.AppendInputChars(e.inputChars[:0])
+	return true
+}
+
+func (e *Engine) drawDialog() {
+	d := e.activeDialog
+	_, lineHeight := e.renderer.MeasureString("Ag")
+	lineHeight += 6
+	buttonPadding := e.padding
+
+	contentWidth, _ := e.renderer.MeasureString(d.Title)
+	for _, line := range d.Lines {
+		lineWidth, _ := e.renderer.MeasureString(line)
+		contentWidth = max(contentWidth, lineWidth)
+	}
+	buttonsWidth := 0.0
+	for _, button := range d.Buttons {
+		buttonWidth, _ := e.renderer.MeasureString(button.Label)
+		buttonsWidth += buttonWidth + buttonPadding*3
+	}
+	contentWidth = max(contentWidth, buttonsWidth)
+
+	size := geometry.Point{
+		X: int(contentWidth + e.padding*4),
+		Y: int(lineHeight*float64(len(d.Lines)+1) + lineHeight + buttonPadding*2 + e.padding*4),
+	}
+	screenSize := e.deviceIndependentScreenSize
+	pos := geometry.Point{X: (screenSize.X - size.X) / 2, Y: (screenSize.Y - size.Y) / 2}
+
+	// dim everything behind the dialog
+	e.renderer.DrawFilledRect(geometry.Point{}, screenSize, e.theme.Dim)
+	e.renderer.DrawFilledRect(pos, size, e.theme.Panel)
+	e.renderer.DrawRectOutline(pos, size, 2, e.theme.Cursor)
+
+	drawX := float64(pos.X) + e.padding*2
+	drawY := float64(pos.Y) + e.padding*2 + lineHeight - 6
+	e.renderer.DrawTTFOnScreen(drawX, drawY, d.Title, e.theme.Cursor)
+	for _, line := range d.Lines {
+		drawY += lineHeight
+		e.renderer.DrawTTFOnScreen(drawX, drawY, line, e.theme.Text)
+	}
+
+	buttonX := drawX
+	buttonY := drawY + lineHeight
+	for i := range d.Buttons {
+		button := &d.Buttons[i]
+		labelWidth, labelHeight := e.renderer.MeasureString(button.Label)
+		buttonSize := geometry.Point{X: int(labelWidth + buttonPadding*2), Y: int(labelHeight + buttonPadding)}
+		buttonPos := geometry.Point{X: int(buttonX), Y: int(buttonY)}
+		button.bounds = geometry.Rect{Min: buttonPos, Max: buttonPos.Add(buttonSize)}
+
+		buttonColor := e.theme.Button
+		if button.bounds.Contains(e.mousePosInPixels) {
+			buttonColor = e.theme.ButtonHover
+		}
+		e.renderer.DrawFilledRect(buttonPos, buttonSize, buttonColor)
+		e.renderer.DrawRectOutline(buttonPos, buttonSize, 1, e.theme.MutedText)
+		e.renderer.DrawTTFOnScreen(buttonX+buttonPadding, buttonY+labelHeight+buttonPadding/2-2, button.Label, e.theme.Text)
+		buttonX += float64(buttonSize.X) + buttonPadding
+	}
+}
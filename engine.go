@@ -1,18 +1,18 @@
 package main
 
 import (
+	"ReMapper/autotile"
 	"ReMapper/geometry"
 	"ReMapper/recfile"
 	"ReMapper/renderer"
-	"cmp"
+	"fmt"
 	"github.com/hajimehoshi/ebiten/v2"
 	"golang.org/x/image/font"
 	"golang.org/x/image/font/opentype"
 	"image/color"
 	"io"
-	"os"
-	"slices"
-	"strconv"
+	"path/filepath"
+	"time"
 )
 
 type Engine struct {
@@ -23,13 +23,41 @@ type Engine struct {
 	renderer                    *renderer.TileRenderer
 	tileScale                   float64
 	mousePosInPixels            geometry.Point
+	inputChars                  []rune
 
 	// use-case specific
 	iconMapping        map[string]int32
 	orderedKeys        []string
+	visibleKeys        []string
+	visibleMatches     [][]int
+	visiblePaths       [][]string
+	listRows           []listRow
+	rowOfEntry         []int
+	groupMode          groupMode
+	collapsedGroups    map[string]bool
+	keyRecords         map[string]recfile.Record
+	searchText         string
+	selectedKey        string
+	selectedKeys       map[string]bool
+	selectionAnchor    string
+	fillColumnMajor    bool
 	tileAtlas          renderer.TextureAtlas
-	scrollOffset       float64
 	listWidth          float64
+	rowHeight          float64
+	listPane           scrollPane
+	atlasPane          scrollPane
+	splitterX          int
+	isDraggingSplitter bool
+	isSplitterMoved    bool
+	isPanningAtlas     bool
+	isDraggingAtlas    bool
+	atlasDragStart     geometry.Point
+	panStartMouse      geometry.Point
+	panStartScroll     geometry.PointF
+	cursorShape        ebiten.CursorShapeType
+	ttfFont            *opentype.Font
+	ttfFontSize        float64
+	listTop            float64
 	padding            float64
 	drawInfos          []ElementInfo
 	bounds             [][2]int
@@ -39,11 +67,52 @@ type Engine struct {
 	atlasSelectorPos   geometry.Point
 	drawAtlasCursor    bool
 	selectedAtlasIndex int32
+	activeLayer        mappingLayer
+	atlasOverlay       atlasOverlay
+	iconUsers          map[int32][]string
+	outOfRangeKeys     []string
+	outOfRangeRows     []clickableRow
+	tooltip            atlasTooltip
 	originalRecords    []recfile.Record
+	recSections        []recSection // the %rec sections of the mapping file, only the default one is edited
 	mappingFileName    string
+	config             MappingConfig
+	displayLabels      map[string]string
 	saveTicks          int
+	ticks              uint64
+	timeline           timelinePanel
+	autotile           autotilePanel
+	autotileRules      autotile.RuleSet
+	autotileProblems   map[string]string // the problems of invalid auto-tile tables by key
+	font               fontMode
+	theme              Theme
+	palette            *renderer.Palette
+	history            *History
+	showHistory        bool
+	focus              focusPane
+	atlasCursor        geometry.Point
+	jumpPrompt         jumpPrompt
+	textPrompt         textPrompt
+	colorPicker        colorPicker
+	showInspector      bool
+	inspectorPane      scrollPane
+	inspector          inspectorState
+	grid               gridView
+	clipboard          clipboardState
+	gridPane           scrollPane
+	preview            mapPreview
+	activeDialog       *dialog
+	savedRecords       []recfile.Record
+	dirty              bool
+	dirtyCheckNeeded   bool
+	shownWindowTitle   string
+	autosaveInterval   time.Duration
+	lastAutosave       time.Time
+	autosavedChange    int
 }
 
+const defaultAtlasScale = 3
+
 func NewEngine(width, height int, title string) *Engine {
 	engine := &Engine{
 		deviceDPIScale:              ebiten.DeviceScaleFactor(),
@@ -52,28 +121,38 @@ func NewEngine(width, height int, title string) *Engine {
 		tileScale:                   4,
 		padding:                     10.0,
 		selectedListIndex:           -1,
+		selectedKeys:                make(map[string]bool),
+		collapsedGroups:             make(map[string]bool),
+		inspector:                   inspectorState{editingField: -1},
+		grid:                        gridView{sortColumn: -1},
+		clipboard:                   newClipboardState(),
 		selectedAtlasIndex:          -1,
-		atlasScale:                  3,
+		atlasScale:                  defaultAtlasScale,
+		theme:                       darkTheme,
+		palette:                     renderer.DefaultPalette(),
+		history:                     NewHistory(),
+		showHistory:                 true,
+		autosavedChange:             -1,
 	}
 	engine.renderer = renderer.NewTileRenderer(engine.GetDeviceDPIScale, engine.GetTileScale)
 	engine.renderer.SetWhiteTile(18)
 	return engine
 }
 
-func (e *Engine) saveChanges(fileName string) {
-	records := e.originalRecords
-	for recIndex, rec := range records {
-		internalName := rec.FindFirstFieldValue("internal_name")
-		for fieldIndex, field := range rec {
-			if field.Name == "icon" {
-				changedIcon := e.iconMapping[internalName]
-				field.Value = strconv.Itoa(int(changedIcon))
-				records[recIndex][fieldIndex] = field
-			}
-		}
+func (e *Engine) saveChanges(fileName string) error {
+	records := e.currentRecords()
+	if duplicates := duplicateKeys(records, e.config); len(duplicates) > 0 {
+		return fmt.Errorf("the key '%s' is used by more than one entry", duplicates[0])
 	}
-	file, _ := os.Create(fileName)
-	recfile.Write(file, records)
+	if err := writeMappingFile(fileName, records, e.recSections); err != nil {
+		return err
+	}
+	e.originalRecords = records
+	e.savedRecords = cloneRecords(records)
+	e.history.MarkSaved()
+	e.removeRecoveryFile()
+	e.markChanged()
+	return nil
 }
 func (e *Engine) GetDeviceDPIScale() float64 {
 	return e.deviceDPIScale
@@ -88,14 +167,25 @@ func (e *Engine) SetTTFFont(file io.ReaderAt, size float64) {
 		println(err.Error())
 		return
 	}
+	e.ttfFont = tt
+	e.ttfFontSize = size
+	e.applyTTFFont()
+}
+
+// applyTTFFont creates the font face for the current DPI scale.
+func (e *Engine) applyTTFFont() {
+	if e.ttfFont == nil {
+		return
+	}
 	dpi := 72 * e.deviceDPIScale
-	fontFace, faceErr := opentype.NewFace(tt, &opentype.FaceOptions{
-		Size:    size,
+	fontFace, faceErr := opentype.NewFace(e.ttfFont, &opentype.FaceOptions{
+		Size:    e.ttfFontSize,
 		DPI:     dpi,
 		Hinting: font.HintingVertical,
 	})
 	if faceErr != nil {
 		println(faceErr.Error())
+		return
 	}
 	//mplusBigFont = text.FaceWithLineHeight(mplusBigFont, 54) // adjust line height
 	e.renderer.SetTTF(fontFace)
@@ -105,15 +195,24 @@ func (e *Engine) Update() error {
 	if e.shouldQuit {
 		return ebiten.Termination
 	}
+	if ebiten.IsWindowBeingClosed() {
+		e.handleWindowClose()
+	}
 	e.handleInput()
+	e.updateClipboard()
+	e.updatePlaytest()
+	e.ticks++
 	if e.saveTicks > 0 {
 		e.saveTicks--
 	}
+	e.updateDirtyState()
+	e.updateAutosave()
 	return nil
 }
 
 func (e *Engine) Draw(screen *ebiten.Image) {
 	e.renderer.SetRenderTarget(screen)
+	screen.Fill(e.theme.Background)
 	iconScale := geometry.PointF{X: 1, Y: 1}
 
 	if e.saveTicks > 0 {
@@ -122,36 +221,162 @@ func (e *Engine) Draw(screen *ebiten.Image) {
 		// center on screen
 		saveTextX := (float64(e.deviceIndependentScreenSize.X) - float64(saveTextWidth)) / 2
 		saveTextY := (float64(e.deviceIndependentScreenSize.Y) - float64(saveTextHeight)) / 2
-		e.renderer.DrawTTFOnScreen(saveTextX, saveTextY, saveText, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+		e.renderer.DrawTTFOnScreen(saveTextX, saveTextY, saveText, e.theme.Text)
+		return
+	}
+	// search box
+	searchBoxPos, searchBoxSize := e.searchBoxRect()
+	e.renderer.DrawFilledRect(searchBoxPos, searchBoxSize, e.theme.Field)
+	e.renderer.DrawRectOutline(searchBoxPos, searchBoxSize, 1, e.theme.Border)
+	searchLabel := e.searchText
+	searchColor := e.theme.Text
+	if searchLabel == "" {
+		searchLabel = "Type to filter.."
+		searchColor = e.theme.MutedText
+	} else {
+		searchLabel = fmt.Sprintf("%s  (%d/%d)", searchLabel, len(e.visibleKeys), len(e.orderedKeys))
+	}
+	_, searchTextHeight := e.renderer.MeasureString(searchLabel)
+	e.renderer.DrawTTFOnScreen(float64(searchBoxPos.X)+e.padding/2, float64(searchBoxPos.Y)+(float64(searchBoxSize.Y)+searchTextHeight)/2-2, searchLabel, searchColor)
+
+	if e.grid.isActive {
+		e.drawGrid(screen)
+		if e.showInspector {
+			e.drawInspector(screen)
+		}
+		e.drawPopups()
 		return
 	}
+
 	// list
-	for index, drawInfo := range e.drawInfos {
-		key := e.orderedKeys[index]
-		currentIcon := e.iconMapping[key]
-		e.renderer.DrawScaledTile(drawInfo.IconPosition.X, drawInfo.IconPosition.Y, e.tileAtlas, currentIcon, iconScale, color.White)
-		drawColor := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	listViewport := e.listPane.viewport()
+	e.renderer.SetRenderTarget(e.clipTo(screen, listViewport))
+	for rowIndex, drawInfo := range e.drawInfos {
+		bound := e.bounds[rowIndex]
+		if bound[1] < listViewport.Min.Y || bound[0] > listViewport.Max.Y {
+			continue
+		}
+		row := e.listRows[rowIndex]
+		if row.group != nil {
+			e.drawGroupRow(row, drawInfo)
+			continue
+		}
+		index := row.entryIndex
+		key := e.visibleKeys[index]
+		e.drawLayers(drawInfo.IconPosition.X, drawInfo.IconPosition.Y, e.layersOf(key), e.tileScale*iconScale.X)
+		drawColor := e.theme.Text
+		if e.selectedKeys[key] && len(e.selectedKeys) > 1 {
+			rowPos := geometry.Point{X: listViewport.Min.X, Y: bound[0]}
+			e.renderer.DrawFilledRect(rowPos, geometry.Point{X: listViewport.Size().X, Y: bound[1] - bound[0]}, e.theme.Selection)
+			drawColor = e.theme.SelectedText
+		}
+		if e.isOutOfRange(key) {
+			drawColor = e.theme.Warning
+		}
 		if index == e.selectedListIndex {
-			drawColor = color.RGBA{R: 255, G: 76, B: 67, A: 255}
+			drawColor = e.theme.Current
 		}
-		e.renderer.DrawTTFOnScreen(drawInfo.TextPosition.X, drawInfo.TextPosition.Y, key, drawColor)
+		e.drawHighlightedText(drawInfo.TextPosition, e.displayLabels[key], e.visibleMatches[index], drawColor, e.theme.Cursor)
 	}
+	e.renderer.SetRenderTarget(screen)
+	e.drawScrollBars(&e.listPane)
 
 	// atlas
+	e.renderer.SetRenderTarget(e.clipTo(screen, e.atlasPane.viewport()))
 	e.renderer.DrawImageOnScreen(e.atlasBounds.Min.X, e.atlasBounds.Min.Y, e.atlasBounds.Size(), e.tileAtlas.GetImage())
+	e.drawAtlasOverlay()
 
 	atlasTileSize := e.tileAtlas.GetTileSize().MulF(e.atlasScale)
 
 	if e.drawAtlasCursor { // selection cursor
-		e.renderer.DrawColoredRect(e.atlasSelectorPos, atlasTileSize, color.RGBA{R: 30, G: 200, B: 30, A: 75})
+		e.renderer.DrawColoredRect(e.atlasSelectorPos, atlasTileSize, e.theme.Hover)
 	}
 
 	if e.selectedAtlasIndex > 0 {
 		cellCountX := e.tileAtlas.GetCellCount().X
 		gridPosX, gridPosY := IndexToXY(int(e.selectedAtlasIndex), cellCountX)
 		drawPos := e.gridToScreen(geometry.Point{X: gridPosX, Y: gridPosY})
-		e.renderer.DrawColoredRect(drawPos, atlasTileSize, color.RGBA{R: 30, G: 25, B: 200, A: 75})
+		e.renderer.DrawColoredRect(drawPos, atlasTileSize, e.theme.CursorFill)
+	}
+
+	if e.focus == focusAtlas {
+		e.renderer.DrawRectOutline(e.gridToScreen(e.atlasCursor), atlasTileSize, 2, e.theme.Cursor)
+	}
+	e.drawAtlasSelectionHints()
+	e.renderer.SetRenderTarget(screen)
+	e.drawScrollBars(&e.atlasPane)
+	if e.preview.isOpen {
+		e.drawMapPreview(screen)
+	}
+	e.drawToolbar()
+	if e.timeline.isOpen {
+		e.drawTimeline()
+	}
+	if e.autotile.isOpen {
+		e.drawAutotile()
+	}
+	if e.font.isActive {
+		e.drawFontPanel()
+	}
+	e.drawTilePreview()
+	e.drawOverlayLegend()
+	if e.showInspector {
+		e.drawInspector(screen)
+	}
+
+	// splitter
+	splitter := e.splitterRect()
+	splitterColor := e.theme.Separator
+	if e.isDraggingSplitter || splitter.Shift(-2, 0, 2, 0).Contains(e.mousePosInPixels) {
+		splitterColor = e.theme.Border
 	}
+	e.renderer.DrawFilledRect(splitter.Min, splitter.Size(), splitterColor)
+
+	e.drawFocusIndicators()
+	e.drawTooltip()
+	e.drawPopups()
+}
+
+// drawPopups draws the panels and prompts that are shown on top of everything else.
+func (e *Engine) drawPopups() {
+	if e.showHistory {
+		e.drawHistoryPanel()
+	}
+
+	if e.jumpPrompt.isOpen {
+		e.drawJumpPrompt()
+	}
+
+	if e.textPrompt.isOpen {
+		e.drawTextPrompt()
+	}
+
+	if e.colorPicker.isOpen {
+		e.drawColorPicker()
+	}
+
+	if e.activeDialog != nil {
+		e.drawDialog()
+	}
+}
+
+func (e *Engine) drawHighlightedText(position geometry.PointF, label string, highlightedPositions []int, textColor, highlightColor color.Color) {
+	drawX := position.X
+	for _, run := range splitIntoRuns(label, highlightedPositions) {
+		runColor := textColor
+		if run.Highlighted {
+			runColor = highlightColor
+		}
+		e.renderer.DrawTTFOnScreen(drawX, position.Y, run.Text, runColor)
+		runWidth, _ := e.renderer.MeasureString(run.Text)
+		drawX += runWidth
+	}
+}
+
+func (e *Engine) searchBoxRect() (geometry.Point, geometry.Point) {
+	_, textHeight := e.renderer.MeasureString("Ag")
+	width := int(e.listWidth - e.padding*2)
+	return geometry.Point{X: int(e.padding), Y: int(e.padding)}, geometry.Point{X: width, Y: int(textHeight + e.padding)}
 }
 
 type ElementInfo struct {
@@ -162,53 +387,65 @@ type ElementInfo struct {
 func (e *Engine) updateElementBounds() {
 	iconScale := geometry.PointF{X: 1, Y: 1}
 	maxWidth := 0.0
-	maxHeight := 0.0
 	tileSize := e.tileAtlas.GetTileSize()
 	scaledIconSize := geometry.PointF{X: float64(tileSize.X) * e.tileScale * iconScale.X, Y: float64(tileSize.Y) * e.tileScale * iconScale.Y}
 
 	lineDistance := 20.0
+	_, textHeight := e.renderer.MeasureString("Ag")
+	e.rowHeight = max(textHeight+lineDistance, scaledIconSize.Y+4)
 
-	drawX := e.padding
-	drawY := e.scrollOffset
+	// the width depends on all entries, so filtering does not change it
+	for _, key := range e.orderedKeys {
+		tW, _ := e.renderer.MeasureString(e.displayLabels[key])
+		maxWidth = max(maxWidth, tW)
+	}
+	maxDepth := 0
+	for _, row := range e.listRows {
+		maxDepth = max(maxDepth, row.depth)
+	}
+	maxWidth += float64(maxDepth * treeIndent)
+	listContentSize := geometry.Point{
+		X: int(maxWidth + scaledIconSize.X + e.padding*3),
+		Y: int(e.rowHeight*float64(len(e.listRows)) + e.padding),
+	}
+	e.layoutPanes(listContentSize)
+
+	listOrigin := e.listPane.contentOrigin()
+	drawX := float64(listOrigin.X) + e.padding
+	drawY := float64(listOrigin.Y)
 	var drawInfo []ElementInfo
 	var boundsInfo [][2]int
-	for _, key := range e.orderedKeys {
-		//e.renderer.DrawScaledTile(drawX, drawY, e.tileAtlas, currentIcon, iconScale, color.White)
-		iconPosition := geometry.PointF{X: drawX, Y: drawY}
-		tW, tH := e.renderer.MeasureString(key)
-		if tW > maxWidth {
-			maxWidth = tW
+	for _, row := range e.listRows {
+		indentX := drawX + float64(row.depth*treeIndent)
+		iconPosition := geometry.PointF{X: indentX, Y: drawY}
+		textPosition := geometry.PointF{X: indentX + scaledIconSize.X + e.padding, Y: drawY + textHeight}
+		if row.group != nil {
+			textPosition.X = indentX
 		}
-		if tH > maxHeight {
-			maxHeight = tH
-		}
-		textPosition := geometry.PointF{X: drawX + scaledIconSize.X + e.padding, Y: drawY + tH}
-		//e.renderer.DrawTTFOnScreen(drawX+scaledIconSize.X+e.padding, drawY+tH, key, color.White)
 
 		drawInfo = append(drawInfo, ElementInfo{
 			IconPosition: iconPosition,
 			TextPosition: textPosition,
 		})
 
-		boundsInfo = append(boundsInfo, [2]int{int(drawY), int(drawY + scaledIconSize.Y)})
-
-		drawY += tH + lineDistance
+		boundsInfo = append(boundsInfo, [2]int{int(drawY), int(drawY + e.rowHeight)})
 
+		drawY += e.rowHeight
 	}
-	e.listWidth = maxWidth + scaledIconSize.X + e.padding*3
 	e.bounds = boundsInfo
 	e.drawInfos = drawInfo
-
-	atlasX := int(e.listWidth + e.padding)
-	atlasSize := e.tileAtlas.GetAtlasSize().MulF(e.atlasScale)
-	e.atlasBounds = geometry.NewRect(atlasX, 0, atlasX+atlasSize.X, atlasSize.Y)
 }
+
 func (e *Engine) Layout(outsideWidth, outsideHeight int) (screenWidth, screenHeight int) {
-	panic("implement me")
+	width, height := e.LayoutF(float64(outsideWidth), float64(outsideHeight))
+	return int(width), int(height)
 }
 
 func (e *Engine) LayoutF(outsideWidth, outsideHeight float64) (screenWidth, screenHeight float64) {
-	//e.deviceDPIScale = ebiten.DeviceScaleFactor()
+	if dpiScale := ebiten.DeviceScaleFactor(); dpiScale != e.deviceDPIScale {
+		e.deviceDPIScale = dpiScale
+		e.OnDPIScaleChanged()
+	}
 	intWidth := int(outsideWidth)
 	intHeight := int(outsideHeight)
 	if e.deviceIndependentScreenSize.X != intWidth || e.deviceIndependentScreenSize.Y != intHeight {
@@ -219,67 +456,108 @@ func (e *Engine) LayoutF(outsideWidth, outsideHeight float64) (screenWidth, scre
 }
 
 func (e *Engine) OnScreenSizeChanged() {
-	newW, newH := ebiten.WindowSize()
-	//e.deviceDPIScale = ebiten.DeviceScaleFactor()
-	e.deviceIndependentScreenSize = geometry.Point{X: newW, Y: newH}
+	e.updateElementBounds()
+}
+
+// OnDPIScaleChanged recreates the font for the new pixel density, e.g. when the window moved to another monitor.
+func (e *Engine) OnDPIScaleChanged() {
+	e.applyTTFFont()
+	e.updateElementBounds()
 }
 
 func (e *Engine) GetDeviceIndependentScreenSize() geometry.Point {
 	return e.deviceIndependentScreenSize
 }
 
+// GetTitle returns the window title, a trailing '*' marks unsaved changes.
 func (e *Engine) GetTitle() string {
-	return e.title
+	if e.mappingFileName == "" {
+		return e.title
+	}
+	title := e.title + " - " + filepath.Base(e.mappingFileName)
+	if e.isDirty() {
+		title += " *"
+	}
+	return title
 }
 
 func (e *Engine) SetAtlas(atlas renderer.TextureAtlas) {
 	e.tileAtlas = atlas
 	e.renderer.SetDefaultAtlas(atlas)
+	e.updateIconUsage()
 	e.updateElementBounds()
 }
 
-func (e *Engine) SetMapping(mappingFileName string, mapping map[string]int32, records []recfile.Record) {
-	e.iconMapping = mapping
+func (e *Engine) SetMapping(mappingFileName string, config MappingConfig, mapping map[string]int32, records []recfile.Record) {
 	e.mappingFileName = mappingFileName
-	var orderedKeys []string
-
-	for k := range mapping {
-		orderedKeys = append(orderedKeys, k)
+	e.config = config
+	e.loadViewState()
+	sections, err := loadRecSections(mappingFileName)
+	if err != nil {
+		println(err.Error())
 	}
+	e.recSections = sections
+	e.setDocument(records, mapping)
+	e.savedRecords = e.currentRecords()
+	e.dirty = false
+}
 
-	slices.SortStableFunc(orderedKeys, func(i, j string) int {
-		return cmp.Compare(i, j)
-	})
-
-	e.orderedKeys = orderedKeys
-	e.updateElementBounds()
-
+// setDocument replaces the records and icons that are edited.
+func (e *Engine) setDocument(records []recfile.Record, mapping map[string]int32) {
+	e.iconMapping = mapping
 	e.originalRecords = records
+	e.displayLabels = make(map[string]string, len(mapping))
+	e.keyRecords = make(map[string]recfile.Record, len(mapping))
+	for _, record := range records {
+		key := record.FindFirstFieldValue(e.config.KeyField)
+		if _, isMapped := mapping[key]; isMapped {
+			e.displayLabels[key] = e.config.DisplayLabel(key, record)
+			if e.font.isActive {
+				e.displayLabels[key] = fontLabel(key)
+			}
+			e.keyRecords[key] = record
+		}
+	}
+
+	e.orderedKeys = sortedKeys(mapping)
+	if e.font.isActive {
+		sortRuneKeys(e.orderedKeys)
+	}
+	e.updateAutotileRules()
+	e.applyFilter()
+	e.onIconsChanged()
+	e.markChanged()
 }
 
 func (e *Engine) handleMouseClick() bool {
-	// find the selected icon
-	for index, bound := range e.bounds {
-		if e.mousePosInPixels.X <= int(e.listWidth) {
+	if e.listPane.viewport().Contains(e.mousePosInPixels) {
+		// find the selected icon
+		for rowIndex, bound := range e.bounds {
 			if e.mousePosInPixels.Y >= bound[0] && e.mousePosInPixels.Y <= bound[1] {
-				key := e.orderedKeys[index]
-				e.selectedListIndex = index
-				e.selectedAtlasIndex = e.iconMapping[key]
+				e.focus = focusList
+				row := e.listRows[rowIndex]
+				if row.group != nil {
+					e.clickGroupRow(row, e.drawInfos[rowIndex].TextPosition.X)
+				} else {
+					e.clickListIndex(row.entryIndex)
+				}
 				return true
 			}
-		} else if e.selectedListIndex >= 0 && e.selectedListIndex < len(e.orderedKeys) {
-			// atlas clicked..
-			atlasPos := e.atlasGridFromScreenPos(e.mousePosInPixels)
-			atlasIndex := XYToIndex(atlasPos.X, atlasPos.Y, e.tileAtlas.GetCellCount().X)
-			//println(fmt.Sprintf("atlas %s", atlasPos.String()))
-			selectedKey := e.orderedKeys[e.selectedListIndex]
-			e.iconMapping[selectedKey] = int32(atlasIndex)
-			e.selectedAtlasIndex = int32(atlasIndex)
 		}
 	}
 	return false
 }
 
+// selectListIndex makes the entry at index the only selected entry.
+func (e *Engine) selectListIndex(index int) {
+	key := e.visibleKeys[index]
+	e.selectedKeys = map[string]bool{key: true}
+	e.selectionAnchor = key
+	e.setPrimarySelection(index)
+}
+
+// atlasGridFromScreenPos converts a screen position to an atlas cell.
+// atlasBounds already contains the zoom and the pan offset of the atlas pane.
 func (e *Engine) atlasGridFromScreenPos(screenPos geometry.Point) geometry.Point {
 	relativeToAtlas := screenPos.Sub(e.atlasBounds.Min)
 	relativeToAtlas = relativeToAtlas.DivF(e.atlasScale)
@@ -290,7 +568,7 @@ func (e *Engine) atlasGridFromScreenPos(screenPos geometry.Point) geometry.Point
 
 func (e *Engine) OnMouseMoved(mousePos geometry.Point) {
 	e.drawAtlasCursor = false
-	if !e.atlasBounds.Contains(mousePos) {
+	if !e.isOverAtlas(mousePos) {
 		return
 	}
 
@@ -300,6 +578,7 @@ func (e *Engine) OnMouseMoved(mousePos geometry.Point) {
 	e.drawAtlasCursor = true
 }
 
+// gridToScreen returns the screen position of the top left corner of an atlas cell.
 func (e *Engine) gridToScreen(gridPos geometry.Point) geometry.Point {
 	tileSize := e.tileAtlas.GetTileSize()
 	drawPosForSelector := geometry.Point{
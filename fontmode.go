@@ -0,0 +1,310 @@
+package main
+
+import (
+	"ReMapper/geometry"
+	"ReMapper/recfile"
+	"ReMapper/renderer"
+	"cmp"
+	"fmt"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+const (
+	defaultFontSample = `The quick brown fox jumps over the lazy dog.\nTHE QUICK BROWN FOX! 0123456789 (+-*/=?)`
+	fontSampleScale   = 2
+)
+
+// fontMode edits the rune map of a renderer.BitmapFont instead of a mapping of keys.
+// Each record holds one rune and its atlas cell, the list shows the runes.
+type fontMode struct {
+	isActive   bool
+	isChaining bool
+	sample     []string
+	font       renderer.BitmapFont
+}
+
+type fontPanelLayout struct {
+	bounds    geometry.Rect
+	titlePos  geometry.PointF
+	samplePos geometry.Point
+	buttons   []toolbarButton
+}
+
+// fontMappingConfig stores font maps with the fields that renderer.ReadFontMap reads.
+func fontMappingConfig() MappingConfig {
+	config := DefaultMappingConfig()
+	config.KeyField = renderer.FontRuneField
+	config.IconField = renderer.FontIconField
+	return config
+}
+
+// newFontRecords starts a font map with the printable ASCII runes, each mapped to the cell
+// of its code point, the layout of most code page 437 fonts.
+func newFontRecords(config MappingConfig) ([]recfile.Record, map[string]int32) {
+	var records []recfile.Record
+	mapping := make(map[string]int32)
+	for char := ' '; char <= '~'; char++ {
+		key := renderer.FormatFontRune(char)
+		records = append(records, recfile.Record{
+			{Name: config.KeyField, Value: key},
+			{Name: config.IconField, Value: recfile.Int32Str(int32(char))},
+		})
+		mapping[key] = int32(char)
+	}
+	return records, mapping
+}
+
+// normalizeFontKeys rewrites the keys of a font map as U+0041, renderer.ReadFontMap also accepts
+// the character itself. It returns the icons by the rewritten keys, like buildCurrentMapping.
+func normalizeFontKeys(records []recfile.Record, config MappingConfig) map[string]int32 {
+	mapping := make(map[string]int32, len(records))
+	for index, record := range records {
+		key := record.FindFirstFieldValue(config.KeyField)
+		if key == "" {
+			continue
+		}
+		if char, err := renderer.ParseFontRune(key); err == nil {
+			key = renderer.FormatFontRune(char)
+			records[index] = setField(record, config.KeyField, key)
+		}
+		mapping[key] = recfile.Field{Value: record.FindFirstFieldValue(config.IconField)}.AsInt32()
+	}
+	return mapping
+}
+
+// isSameRune is true if both font map keys stand for the same rune, however they are written.
+func isSameRune(a, b string) bool {
+	charA, errA := renderer.ParseFontRune(a)
+	charB, errB := renderer.ParseFontRune(b)
+	return errA == nil && errB == nil && charA == charB
+}
+
+// SetFontMode switches the editor to font maps, it has to be called before SetMapping.
+// In the sample text, \n starts a new line.
+func (e *Engine) SetFontMode(sample string) {
+	e.font.isActive = true
+	e.setFontSample(sample)
+	e.renderer.SetFontScale(fontSampleScale)
+}
+
+func (e *Engine) setFontSample(sample string) {
+	e.font.sample = strings.Split(sample, `\n`)
+}
+
+// runeOfKey returns the rune of a font map key.
+func runeOfKey(key string) rune {
+	char, _ := renderer.ParseFontRune(key)
+	return char
+}
+
+// fontLabel shows the character in front of its code, whitespace and control characters by name.
+func fontLabel(key string) string {
+	char := runeOfKey(key)
+	switch {
+	case char == ' ':
+		return fmt.Sprintf("space  %s", key)
+	case !unicode.IsGraphic(char) || unicode.IsSpace(char):
+		return fmt.Sprintf("ctrl  %s", key)
+	}
+	return fmt.Sprintf("%c  %s", char, key)
+}
+
+func sortRuneKeys(keys []string) {
+	slices.SortStableFunc(keys, func(a, b string) int {
+		return cmp.Compare(runeOfKey(a), runeOfKey(b))
+	})
+}
+
+// updateBitmapFont rebuilds the font of the sample text from the current icons.
+func (e *Engine) updateBitmapFont() {
+	if !e.font.isActive {
+		return
+	}
+	fontMap := make(map[rune]uint16, len(e.iconMapping))
+	for key, icon := range e.iconMapping {
+		if icon >= 0 {
+			fontMap[runeOfKey(key)] = uint16(icon)
+		}
+	}
+	e.font.font = renderer.NewBitmapFont(e.tileAtlas, fontMap)
+	e.renderer.SetFont(e.font.font)
+}
+
+// runeClass groups runes for chains: upper case letters, lower case letters, digits, spaces and the rest.
+func runeClass(char rune) int {
+	switch {
+	case unicode.IsUpper(char):
+		return 1
+	case unicode.IsLower(char):
+		return 2
+	case unicode.IsDigit(char):
+		return 3
+	case unicode.IsSpace(char):
+		return 4
+	}
+	return 0
+}
+
+// fontChainKeys returns the selected rune and the following code points of the same class
+// that are in the font, so a chain from A stops at Z and one from 0 stops at 9.
+func (e *Engine) fontChainKeys() []string {
+	if _, isMapped := e.iconMapping[e.selectedKey]; !isMapped {
+		return nil
+	}
+	start := runeOfKey(e.selectedKey)
+	keys := []string{e.selectedKey}
+	for next := start + 1; runeClass(next) == runeClass(start); next++ {
+		key := renderer.FormatFontRune(next)
+		if _, isMapped := e.iconMapping[key]; !isMapped {
+			break
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// assignFontChain assigns consecutive cells starting at gridPos to the chain of the selected rune,
+// like a renderer.SpecialCharacterChain.
+func (e *Engine) assignFontChain(gridPos geometry.Point) bool {
+	keys := e.fontChainKeys()
+	if len(keys) == 0 {
+		return false
+	}
+	cells := sequentialCells(gridPos, len(keys), e.tileAtlas.GetCellCount(), e.fillColumnMajor)
+	cellCountX := e.tileAtlas.GetCellCount().X
+	icons := make([]int32, len(cells))
+	for index, cell := range cells {
+		icons[index] = int32(XYToIndex(cell.X, cell.Y, cellCountX))
+	}
+	e.assignIcons(keys[:len(cells)], icons)
+	return true
+}
+
+// openFontCharsPrompt asks for characters that get consecutive cells starting at the atlas cursor.
+// Characters that are not in the font yet are added.
+func (e *Engine) openFontCharsPrompt() {
+	e.openTextPrompt("Characters from the atlas cursor", "", func(text string) error {
+		var chars []rune
+		for _, char := range text {
+			if !slices.Contains(chars, char) {
+				chars = append(chars, char)
+			}
+		}
+		if len(chars) == 0 {
+			return fmt.Errorf("type the characters in the order of the atlas")
+		}
+		cells := sequentialCells(e.atlasCursor, len(chars), e.tileAtlas.GetCellCount(), e.fillColumnMajor)
+		cellCountX := e.tileAtlas.GetCellCount().X
+		e.editRecords(fmt.Sprintf("chain %d characters", len(cells)), func(records []recfile.Record, mapping map[string]int32) ([]recfile.Record, string) {
+			var firstKey string
+			for index, cell := range cells {
+				key := renderer.FormatFontRune(chars[index])
+				icon := int32(XYToIndex(cell.X, cell.Y, cellCountX))
+				if recordIndex := e.recordIndexOfKey(records, key); recordIndex >= 0 {
+					records[recordIndex] = setField(records[recordIndex], e.config.IconField, recfile.Int32Str(icon))
+				} else {
+					records = append(records, recfile.Record{
+						{Name: e.config.KeyField, Value: key},
+						{Name: e.config.IconField, Value: recfile.Int32Str(icon)},
+					})
+				}
+				mapping[key] = icon
+				if index == 0 {
+					firstKey = key
+				}
+			}
+			return records, firstKey
+		})
+		return nil
+	})
+}
+
+func (e *Engine) openFontSamplePrompt() {
+	e.openTextPrompt(`Sample text, \n starts a new line`, strings.Join(e.font.sample, `\n`), func(text string) error {
+		e.setFontSample(text)
+		return nil
+	})
+}
+
+// layoutFontPanel places the sample text along the bottom of the atlas pane.
+func (e *Engine) layoutFontPanel() fontPanelLayout {
+	var layout fontPanelLayout
+	_, textHeight := e.renderer.MeasureString("Ag")
+	_, lineHeight := e.renderer.MeasureBitmapString("")
+	height := int(textHeight+e.padding*2) + int(lineHeight*float64(len(e.font.sample))) + int(e.padding)
+	viewport := e.atlasPane.viewport()
+	layout.bounds = geometry.NewRect(viewport.Min.X+int(e.padding), viewport.Max.Y-height-int(e.padding), viewport.Max.X-int(e.padding), viewport.Max.Y-int(e.padding))
+	layout.titlePos = geometry.PointF{X: float64(layout.bounds.Min.X) + e.padding, Y: float64(layout.bounds.Min.Y) + e.padding + textHeight - 2}
+	layout.samplePos = geometry.Point{X: layout.bounds.Min.X + int(e.padding), Y: layout.bounds.Min.Y + int(textHeight+e.padding*2)}
+
+	buttons := []toolbarButton{
+		{label: "Sample", action: e.openFontSamplePrompt},
+		{label: "Add characters", action: e.openFontCharsPrompt},
+		{label: "Chain", isActive: e.font.isChaining, action: func() { e.font.isChaining = !e.font.isChaining }},
+	}
+	buttonX := layout.bounds.Max.X - int(e.padding)
+	for _, button := range buttons {
+		labelWidth, _ := e.renderer.MeasureString(button.label)
+		buttonX -= int(labelWidth + e.padding*2)
+		button.bounds = geometry.NewRect(buttonX, layout.bounds.Min.Y+int(e.padding/2), buttonX+int(labelWidth+e.padding*2), layout.bounds.Min.Y+int(textHeight+e.padding*1.5))
+		layout.buttons = append(layout.buttons, button)
+		buttonX -= int(e.padding / 2)
+	}
+	return layout
+}
+
+// fontPanelHeight is the part of the atlas pane that the sample text covers.
+func (e *Engine) fontPanelHeight() int {
+	if !e.font.isActive {
+		return 0
+	}
+	return e.layoutFontPanel().bounds.Size().Y + int(e.padding)
+}
+
+func (e *Engine) handleFontPanelMouse() bool {
+	if !e.font.isActive {
+		return false
+	}
+	layout := e.layoutFontPanel()
+	if !layout.bounds.Contains(e.mousePosInPixels) {
+		return false
+	}
+	if !inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		return ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft)
+	}
+	for _, button := range layout.buttons {
+		if button.bounds.Contains(e.mousePosInPixels) {
+			button.action()
+			return true
+		}
+	}
+	return true
+}
+
+func (e *Engine) drawFontPanel() {
+	layout := e.layoutFontPanel()
+	e.renderer.DrawFilledRect(layout.bounds.Min, layout.bounds.Size(), withAlpha(e.theme.Panel, 240))
+	e.renderer.DrawRectOutline(layout.bounds.Min, layout.bounds.Size(), 1, e.theme.Border)
+
+	title := fmt.Sprintf("%d runes - click a cell to assign the selected rune", len(e.iconMapping))
+	if e.font.isChaining {
+		chainKeys := e.fontChainKeys()
+		title = fmt.Sprintf("%d runes - click a cell to assign the chain of %d runes from the selected one", len(e.iconMapping), len(chainKeys))
+		if len(chainKeys) > 1 {
+			title = fmt.Sprintf("%d runes - click a cell to assign %s to %s", len(e.iconMapping), fontLabel(chainKeys[0]), fontLabel(chainKeys[len(chainKeys)-1]))
+		}
+	}
+	e.renderer.DrawTTFOnScreen(layout.titlePos.X, layout.titlePos.Y, title, e.theme.Text)
+	e.drawButtons(layout.buttons)
+
+	if !e.font.font.IsLoaded() {
+		return
+	}
+	// DrawMultiString works in device pixels
+	scale := e.GetDeviceDPIScale()
+	e.renderer.DrawMultiString(int(float64(layout.samplePos.X)*scale), int(float64(layout.samplePos.Y)*scale), e.font.sample, e.theme.Text)
+}
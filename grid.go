@@ -0,0 +1,744 @@
+package main
+
+import (
+	"ReMapper/geometry"
+	"ReMapper/recfile"
+	"cmp"
+	"encoding/csv"
+	"fmt"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"image/color"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	gridMinColumnWidth = 60
+	gridMaxColumnWidth = 300
+)
+
+// gridView shows the records as rows and their fields as columns.
+type gridView struct {
+	isActive       bool
+	records        []recfile.Record // the current records the grid was built from
+	columns        []string
+	rows           []int      // indices into records, filtered and sorted
+	cells          [][]string // the values of every row in the order of columns
+	columnWidths   []int
+	sortColumn     int // -1 keeps the order of the file
+	sortDescending bool
+	cursor         geometry.Point // X is the column, Y the row
+	anchor         geometry.Point // the other corner of the selected range
+	isDragging     bool
+	isEditing      bool
+	editText       string
+	errorText      string
+}
+
+// gridCellValue is a new value for the cell in the given row and column of the grid.
+type gridCellValue struct {
+	row, column int
+	value       string
+}
+
+// gridColumns lists the key and the icon field first, all other fields in the order they appear.
+func gridColumns(records []recfile.Record, config MappingConfig) []string {
+	columns := []string{config.KeyField, config.IconField}
+	for _, record := range records {
+		if isDescriptor(record) {
+			continue
+		}
+		for _, field := range record {
+			if !slices.Contains(columns, field.Name) {
+				columns = append(columns, field.Name)
+			}
+		}
+	}
+	return columns
+}
+
+// matchesGridFilter checks every word of the filter. "field:text" only looks at one column,
+// other words may appear in any column. Case is ignored.
+func matchesGridFilter(values, columns []string, filterText string) bool {
+	for _, term := range strings.Fields(strings.ToLower(filterText)) {
+		columnName, text, hasColumn := strings.Cut(term, ":")
+		columnIndex := slices.IndexFunc(columns, func(column string) bool { return strings.ToLower(column) == columnName })
+		if hasColumn && columnIndex >= 0 {
+			if !strings.Contains(strings.ToLower(values[columnIndex]), text) {
+				return false
+			}
+			continue
+		}
+		if !slices.ContainsFunc(values, func(value string) bool { return strings.Contains(strings.ToLower(value), term) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// compareCellValues compares numbers by their value and everything else as text.
+func compareCellValues(a, b string) int {
+	numberA, errA := strconv.ParseFloat(a, 64)
+	numberB, errB := strconv.ParseFloat(b, 64)
+	if errA == nil && errB == nil {
+		return cmp.Compare(numberA, numberB)
+	}
+	return cmp.Compare(strings.ToLower(a), strings.ToLower(b))
+}
+
+func (e *Engine) toggleGrid() {
+	e.grid.isActive = !e.grid.isActive
+	e.grid.isEditing = false
+	e.grid.errorText = ""
+	e.rebuildGrid()
+	e.updateElementBounds()
+}
+
+// rebuildGrid lays out the current records, it is called whenever the records or the filter change.
+func (e *Engine) rebuildGrid() {
+	g := &e.grid
+	if !g.isActive {
+		return
+	}
+	g.records = e.currentRecords()
+	g.columns = gridColumns(g.records, e.config)
+	g.rows = g.rows[:0]
+	g.cells = g.cells[:0]
+	for index, record := range g.records {
+		if isDescriptor(record) {
+			continue
+		}
+		values := record.ToFixedSizeValueList(g.columns)
+		if matchesGridFilter(values, g.columns, e.searchText) {
+			g.rows = append(g.rows, index)
+			g.cells = append(g.cells, values)
+		}
+	}
+	if g.sortColumn >= len(g.columns) {
+		g.sortColumn = -1
+	}
+	if g.sortColumn >= 0 {
+		order := make([]int, len(g.rows))
+		for i := range order {
+			order[i] = i
+		}
+		slices.SortStableFunc(order, func(a, b int) int {
+			result := compareCellValues(g.cells[a][g.sortColumn], g.cells[b][g.sortColumn])
+			if g.sortDescending {
+				return -result
+			}
+			return result
+		})
+		sortedRows := make([]int, len(order))
+		sortedCells := make([][]string, len(order))
+		for newIndex, oldIndex := range order {
+			sortedRows[newIndex] = g.rows[oldIndex]
+			sortedCells[newIndex] = g.cells[oldIndex]
+		}
+		g.rows, g.cells = sortedRows, sortedCells
+	}
+	maxCell := geometry.Point{X: len(g.columns) - 1, Y: max(0, len(g.rows)-1)}
+	g.cursor = geometry.Point{X: clamp(g.cursor.X, 0, maxCell.X), Y: clamp(g.cursor.Y, 0, maxCell.Y)}
+	g.anchor = geometry.Point{X: clamp(g.anchor.X, 0, maxCell.X), Y: clamp(g.anchor.Y, 0, maxCell.Y)}
+
+	iconWidth := float64(e.tileAtlas.GetTileSize().X) * e.tileScale
+	g.columnWidths = make([]int, len(g.columns))
+	for column, name := range g.columns {
+		width, _ := e.renderer.MeasureString(name + " ^")
+		for _, values := range g.cells {
+			firstLine, _, _ := strings.Cut(values[column], "\n")
+			valueWidth, _ := e.renderer.MeasureString(firstLine)
+			width = max(width, valueWidth)
+		}
+		if name == e.config.IconField {
+			width += iconWidth + e.padding
+		}
+		g.columnWidths[column] = clamp(int(width+e.padding*2), gridMinColumnWidth, gridMaxColumnWidth)
+	}
+}
+
+func (e *Engine) gridHeaderHeight() int {
+	_, textHeight := e.renderer.MeasureString("Ag")
+	return int(textHeight + e.padding)
+}
+
+func (e *Engine) gridContentSize() geometry.Point {
+	width := 0
+	for _, columnWidth := range e.grid.columnWidths {
+		width += columnWidth
+	}
+	return geometry.Point{X: width, Y: e.gridHeaderHeight() + int(e.rowHeight*float64(len(e.grid.rows)))}
+}
+
+// gridCellRect returns the cell in content coordinates, the header is not scrolled vertically.
+func (e *Engine) gridCellRect(cell geometry.Point) geometry.Rect {
+	x := 0
+	for column := 0; column < cell.X; column++ {
+		x += e.grid.columnWidths[column]
+	}
+	y := e.gridHeaderHeight() + int(float64(cell.Y)*e.rowHeight)
+	return geometry.NewRect(x, y, x+e.grid.columnWidths[cell.X], y+int(e.rowHeight))
+}
+
+// gridCellAt finds the cell under a screen position. Row -1 is the header.
+func (e *Engine) gridCellAt(pos geometry.Point) (geometry.Point, bool) {
+	viewport := e.gridPane.viewport()
+	if !viewport.Contains(pos) || len(e.grid.columns) == 0 {
+		return geometry.Point{}, false
+	}
+	origin := e.gridPane.contentOrigin()
+	column := -1
+	x := origin.X
+	for index, width := range e.grid.columnWidths {
+		if pos.X >= x && pos.X < x+width {
+			column = index
+			break
+		}
+		x += width
+	}
+	if column < 0 {
+		return geometry.Point{}, false
+	}
+	if pos.Y < viewport.Min.Y+e.gridHeaderHeight() {
+		return geometry.Point{X: column, Y: -1}, true
+	}
+	row := int(float64(pos.Y-origin.Y-e.gridHeaderHeight()) / e.rowHeight)
+	if row < 0 || row >= len(e.grid.rows) {
+		return geometry.Point{}, false
+	}
+	return geometry.Point{X: column, Y: row}, true
+}
+
+// gridRange returns the selected cells, Min and Max are both inclusive.
+func (g *gridView) gridRange() geometry.Rect {
+	return geometry.Rect{
+		Min: geometry.Point{X: min(g.cursor.X, g.anchor.X), Y: min(g.cursor.Y, g.anchor.Y)},
+		Max: geometry.Point{X: max(g.cursor.X, g.anchor.X), Y: max(g.cursor.Y, g.anchor.Y)},
+	}
+}
+
+func (g *gridView) isSelected(cell geometry.Point) bool {
+	selection := g.gridRange()
+	return cell.X >= selection.Min.X && cell.X <= selection.Max.X && cell.Y >= selection.Min.Y && cell.Y <= selection.Max.Y
+}
+
+func (e *Engine) scrollToGridCursor() {
+	cellRect := e.gridCellRect(e.grid.cursor)
+	// keep the row out from under the header
+	cellRect.Min.Y -= e.gridHeaderHeight()
+	e.gridPane.scrollToShow(cellRect)
+	e.updateElementBounds()
+}
+
+// syncGridSelection selects the record under the cursor in the list and the inspector.
+func (e *Engine) syncGridSelection() {
+	g := &e.grid
+	if len(g.rows) == 0 {
+		return
+	}
+	if key := g.records[g.rows[g.cursor.Y]].FindFirstFieldValue(e.config.KeyField); key != "" {
+		e.selectKey(key)
+	}
+}
+
+func (e *Engine) moveGridCursorToKey(key string) {
+	g := &e.grid
+	for row, recordIndex := range g.rows {
+		if g.records[recordIndex].FindFirstFieldValue(e.config.KeyField) == key {
+			g.cursor = geometry.Point{X: g.cursor.X, Y: row}
+			g.anchor = g.cursor
+			e.scrollToGridCursor()
+			return
+		}
+	}
+}
+
+func (e *Engine) startGridEdit() {
+	g := &e.grid
+	if len(g.rows) == 0 {
+		return
+	}
+	g.anchor = g.cursor
+	g.isEditing = true
+	g.editText = g.cells[g.cursor.Y][g.cursor.X]
+	g.errorText = ""
+}
+
+func (e *Engine) commitGridEdit() bool {
+	g := &e.grid
+	change := gridCellValue{row: g.cursor.Y, column: g.cursor.X, value: g.editText}
+	if !e.setGridValues(fmt.Sprintf("set %s", g.columns[g.cursor.X]), []gridCellValue{change}) {
+		return false
+	}
+	g.isEditing = false
+	return true
+}
+
+// cellParts splits the value of a field that appears more than once in the record
+// at "|", the way ToFixedSizeValueList joins them.
+func cellParts(record recfile.Record, column, value string) []string {
+	occurrences := 0
+	for _, field := range record {
+		if field.Name == column {
+			occurrences++
+		}
+	}
+	if occurrences > 1 {
+		return strings.Split(value, "|")
+	}
+	return []string{value}
+}
+
+// setCellValue stores parts as the values of the fields called column.
+// The fields are replaced in place if their number stays the same.
+func setCellValue(record recfile.Record, column string, parts []string) recfile.Record {
+	var positions []int
+	for index, field := range record {
+		if field.Name == column {
+			positions = append(positions, index)
+		}
+	}
+	if len(parts) == len(positions) {
+		for partIndex, position := range positions {
+			record[position].Value = parts[partIndex]
+		}
+		return record
+	}
+	insertAt := len(record)
+	if len(positions) > 0 {
+		insertAt = positions[0]
+	}
+	var newFields recfile.Record
+	for _, part := range parts {
+		newFields = append(newFields, recfile.Field{Name: column, Value: part})
+	}
+	var result recfile.Record
+	for index, field := range record {
+		if index == insertAt {
+			result = append(result, newFields...)
+		}
+		if field.Name != column {
+			result = append(result, field)
+		}
+	}
+	if insertAt == len(record) {
+		result = append(result, newFields...)
+	}
+	return result
+}
+
+// setGridValues validates and applies the changes as one undoable command.
+// An empty value removes the field, except for the key and the icon.
+func (e *Engine) setGridValues(description string, changes []gridCellValue) bool {
+	g := &e.grid
+	records := cloneRecords(g.records)
+	mapping := maps.Clone(e.iconMapping)
+	newSelection := ""
+	for _, change := range changes {
+		recordIndex := g.rows[change.row]
+		record := records[recordIndex]
+		column := g.columns[change.column]
+		key := record.FindFirstFieldValue(e.config.KeyField)
+		parts := cellParts(record, column, change.value)
+		switch column {
+		case e.config.KeyField:
+			if change.value == key {
+				continue
+			}
+			if strings.TrimSpace(change.value) == "" {
+				g.errorText = "the key must not be empty"
+				return false
+			}
+			if _, exists := mapping[change.value]; exists {
+				g.errorText = fmt.Sprintf("there already is an entry '%s'", change.value)
+				return false
+			}
+			icon, isMapped := mapping[key]
+			if !isMapped {
+				icon = recfile.Field{Value: record.FindFirstFieldValue(e.config.IconField)}.AsInt32()
+			}
+			delete(mapping, key)
+			mapping[change.value] = icon
+			if key == e.selectedKey {
+				newSelection = change.value
+			}
+			parts = []string{change.value}
+		case e.config.IconField:
+			icon, err := strconv.ParseInt(change.value, 10, 32)
+			if err != nil {
+				g.errorText = fmt.Sprintf("%s must be a whole number", column)
+				return false
+			}
+			if _, isMapped := mapping[key]; isMapped {
+				mapping[key] = int32(icon)
+			}
+			parts = []string{change.value}
+		default:
+			if change.value == "" {
+				parts = nil
+			}
+			schema := schemaOf(records)
+			for _, part := range parts {
+				if err := schema.validate(recfile.Field{Name: column, Value: part}); err != nil {
+					g.errorText = err.Error()
+					return false
+				}
+			}
+		}
+		records[recordIndex] = setCellValue(record, column, parts)
+	}
+	g.errorText = ""
+	if recordsEqual(records, g.records) {
+		return true
+	}
+	e.replaceRecords(description, records, mapping, newSelection)
+	return true
+}
+
+func (e *Engine) copyGridRange() {
+	g := &e.grid
+	if len(g.rows) == 0 {
+		return
+	}
+	selection := g.gridRange()
+	var text strings.Builder
+	tsvWriter := csv.NewWriter(&text)
+	tsvWriter.Comma = '\t'
+	for row := selection.Min.Y; row <= selection.Max.Y; row++ {
+		tsvWriter.Write(g.cells[row][selection.Min.X : selection.Max.X+1])
+	}
+	tsvWriter.Flush()
+	e.writeClipboard(strings.TrimSuffix(text.String(), "\n"))
+}
+
+// pasteIntoGrid pastes TSV text from the clipboard at the top left corner of the selection.
+// A single value is copied into every selected cell.
+func (e *Engine) pasteIntoGrid() {
+	e.readClipboard(e.pasteTextIntoGrid)
+}
+
+func (e *Engine) pasteTextIntoGrid(text string) {
+	g := &e.grid
+	if !g.isActive || len(g.rows) == 0 {
+		return
+	}
+	tsvReader := csv.NewReader(strings.NewReader(text))
+	tsvReader.Comma = '\t'
+	tsvReader.FieldsPerRecord = -1
+	tsvReader.LazyQuotes = true
+	pastedRows, err := tsvReader.ReadAll()
+	if err != nil {
+		g.errorText = err.Error()
+		return
+	}
+	if len(pastedRows) == 0 {
+		return
+	}
+	selection := g.gridRange()
+	var changes []gridCellValue
+	if len(pastedRows) == 1 && len(pastedRows[0]) == 1 {
+		for row := selection.Min.Y; row <= selection.Max.Y; row++ {
+			for column := selection.Min.X; column <= selection.Max.X; column++ {
+				changes = append(changes, gridCellValue{row: row, column: column, value: pastedRows[0][0]})
+			}
+		}
+	} else {
+		for rowOffset, pastedRow := range pastedRows {
+			for columnOffset, value := range pastedRow {
+				row, column := selection.Min.Y+rowOffset, selection.Min.X+columnOffset
+				if row < len(g.rows) && column < len(g.columns) {
+					changes = append(changes, gridCellValue{row: row, column: column, value: value})
+				}
+			}
+		}
+	}
+	e.setGridValues(fmt.Sprintf("paste %d cells", len(changes)), changes)
+}
+
+// clearGridRange removes the fields of the selected cells. Keys and icons are kept.
+func (e *Engine) clearGridRange() {
+	g := &e.grid
+	if len(g.rows) == 0 {
+		return
+	}
+	selection := g.gridRange()
+	var changes []gridCellValue
+	for row := selection.Min.Y; row <= selection.Max.Y; row++ {
+		for column := selection.Min.X; column <= selection.Max.X; column++ {
+			if !e.isProtectedField(g.columns[column]) {
+				changes = append(changes, gridCellValue{row: row, column: column})
+			}
+		}
+	}
+	e.setGridValues(fmt.Sprintf("clear %d cells", len(changes)), changes)
+}
+
+func (e *Engine) gridPageSize() int {
+	return max(1, int(float64(e.gridPane.viewport().Size().Y-e.gridHeaderHeight())/e.rowHeight)-1)
+}
+
+// handleGridKeys moves the cursor, edits cells and copies and pastes ranges.
+// Typed characters that are not used for editing end up in the filter.
+func (e *Engine) handleGridKeys() bool {
+	g := &e.grid
+	if !g.isActive {
+		return false
+	}
+	if g.isEditing {
+		return e.handleGridEditKeys()
+	}
+	if isControlPressed() {
+		switch {
+		case inpututil.IsKeyJustPressed(ebiten.KeyC):
+			e.copyGridRange()
+		case inpututil.IsKeyJustPressed(ebiten.KeyV):
+			e.pasteIntoGrid()
+		case inpututil.IsKeyJustPressed(ebiten.KeyA):
+			g.anchor = geometry.Point{}
+			g.cursor = geometry.Point{X: len(g.columns) - 1, Y: max(0, len(g.rows)-1)}
+		default:
+			return false
+		}
+		return true
+	}
+	if len(g.rows) == 0 {
+		return false
+	}
+	isShiftPressed := ebiten.IsKeyPressed(ebiten.KeyShift)
+	newCursor := g.cursor
+	extendSelection := isShiftPressed
+	switch {
+	case isKeyRepeated(ebiten.KeyArrowLeft):
+		newCursor.X--
+	case isKeyRepeated(ebiten.KeyArrowRight):
+		newCursor.X++
+	case isKeyRepeated(ebiten.KeyArrowUp):
+		newCursor.Y--
+	case isKeyRepeated(ebiten.KeyArrowDown):
+		newCursor.Y++
+	case isKeyRepeated(ebiten.KeyTab):
+		extendSelection = false
+		if isShiftPressed {
+			newCursor.X--
+		} else {
+			newCursor.X++
+		}
+	case isKeyRepeated(ebiten.KeyPageUp):
+		newCursor.Y -= e.gridPageSize()
+	case isKeyRepeated(ebiten.KeyPageDown):
+		newCursor.Y += e.gridPageSize()
+	case inpututil.IsKeyJustPressed(ebiten.KeyHome):
+		newCursor.Y = 0
+	case inpututil.IsKeyJustPressed(ebiten.KeyEnd):
+		newCursor.Y = len(g.rows) - 1
+	case isEnterJustPressed():
+		e.startGridEdit()
+		return true
+	case inpututil.IsKeyJustPressed(ebiten.KeyDelete):
+		e.clearGridRange()
+		return true
+	default:
+		return false
+	}
+	g.cursor = geometry.Point{X: clamp(newCursor.X, 0, len(g.columns)-1), Y: clamp(newCursor.Y, 0, len(g.rows)-1)}
+	if !extendSelection {
+		g.anchor = g.cursor
+	}
+	e.scrollToGridCursor()
+	e.syncGridSelection()
+	return true
+}
+
+// handleGridEditKeys edits the text of a cell. Shift+Enter starts a new line.
+func (e *Engine) handleGridEditKeys() bool {
+	g := &e.grid
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyEscape):
+		g.isEditing = false
+		g.errorText = ""
+	case isEnterJustPressed() && ebiten.IsKeyPressed(ebiten.KeyShift):
+		g.editText += "\n"
+	case isEnterJustPressed():
+		if e.commitGridEdit() && g.cursor.Y < len(g.rows)-1 {
+			g.cursor.Y++
+			g.anchor = g.cursor
+			e.scrollToGridCursor()
+			e.syncGridSelection()
+		}
+	case isKeyRepeated(ebiten.KeyBackspace):
+		if g.editText != "" {
+			_, lastSize := utf8.DecodeLastRuneInString(g.editText)
+			g.editText = g.editText[:len(g.editText)-lastSize]
+		}
+	default:
+		e.inputChars = ebiten.AppendInputChars(e.inputChars[:0])
+		if isControlPressed() {
+			return true
+		}
+		for _, char := range e.inputChars {
+			if unicode.IsPrint(char) {
+				g.editText += string(char)
+			}
+		}
+	}
+	return true
+}
+
+// handleGridMouse sorts by a column when its header is clicked, selects cells and ranges
+// and starts editing when the cell under the cursor is clicked again.
+func (e *Engine) handleGridMouse() bool {
+	g := &e.grid
+	if e.gridPane.handleMouse(e.mousePosInPixels) {
+		e.updateElementBounds()
+		return true
+	}
+	if g.isDragging {
+		if !ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
+			g.isDragging = false
+			return true
+		}
+		if cell, isOnCell := e.gridCellAt(e.mousePosInPixels); isOnCell && cell.Y >= 0 {
+			g.cursor = cell
+		}
+		return true
+	}
+	if !inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		return false
+	}
+	cell, isOnCell := e.gridCellAt(e.mousePosInPixels)
+	if !isOnCell {
+		return false
+	}
+	if g.isEditing && cell != g.cursor && !e.commitGridEdit() {
+		return true
+	}
+	if cell.Y < 0 {
+		switch {
+		case g.sortColumn != cell.X:
+			g.sortColumn, g.sortDescending = cell.X, false
+		case !g.sortDescending:
+			g.sortDescending = true
+		default:
+			g.sortColumn = -1
+		}
+		e.rebuildGrid()
+		return true
+	}
+	switch {
+	case ebiten.IsKeyPressed(ebiten.KeyShift):
+		g.cursor = cell
+	case cell == g.cursor && g.anchor == g.cursor && !g.isEditing:
+		e.startGridEdit()
+		return true
+	default:
+		g.cursor, g.anchor = cell, cell
+		g.isDragging = true
+	}
+	e.syncGridSelection()
+	return true
+}
+
+// fitText shortens text with ".." until it is at most width pixels wide.
+func (e *Engine) fitText(text string, width float64) string {
+	textWidth, _ := e.renderer.MeasureString(text)
+	if textWidth <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		if shortWidth, _ := e.renderer.MeasureString(string(runes) + ".."); shortWidth <= width {
+			break
+		}
+	}
+	return string(runes) + ".."
+}
+
+func (e *Engine) drawGrid(screen *ebiten.Image) {
+	g := &e.grid
+	viewport := e.gridPane.viewport()
+	origin := e.gridPane.contentOrigin()
+	headerHeight := e.gridHeaderHeight()
+	_, textHeight := e.renderer.MeasureString("Ag")
+	iconWidth := float64(e.tileAtlas.GetTileSize().X) * e.tileScale
+	cellCount := e.tileAtlas.GetCellCount()
+	lineColor := e.theme.Separator
+
+	// rows
+	rowsArea := geometry.Rect{Min: geometry.Point{X: viewport.Min.X, Y: viewport.Min.Y + headerHeight}, Max: viewport.Max}
+	e.renderer.SetRenderTarget(e.clipTo(screen, rowsArea))
+	firstRow := max(0, int(float64(rowsArea.Min.Y-origin.Y-headerHeight)/e.rowHeight))
+	lastRow := min(len(g.rows)-1, int(float64(rowsArea.Max.Y-origin.Y-headerHeight)/e.rowHeight))
+	for row := firstRow; row <= lastRow; row++ {
+		for column, columnName := range g.columns {
+			cell := geometry.Point{X: column, Y: row}
+			cellRect := e.gridCellRect(cell)
+			cellPos := origin.Add(cellRect.Min)
+			if g.isSelected(cell) {
+				e.renderer.DrawFilledRect(cellPos, cellRect.Size(), e.theme.Selection)
+			}
+			value := g.cells[row][column]
+			textColor := color.Color(e.theme.Text)
+			if g.isEditing && cell == g.cursor {
+				e.renderer.DrawFilledRect(cellPos, cellRect.Size(), e.theme.Modified)
+				value = strings.ReplaceAll(g.editText, "\n", "\\n") + "_"
+				textColor = e.theme.Cursor
+			} else if firstLine, _, isMultiLine := strings.Cut(value, "\n"); isMultiLine {
+				value = firstLine + " .."
+			}
+			textX := float64(cellPos.X) + e.padding
+			if columnName == e.config.IconField {
+				if icon, err := strconv.ParseInt(value, 10, 32); err == nil && icon >= 0 && icon < int64(cellCount.X*cellCount.Y) {
+					e.drawLayers(float64(cellPos.X)+e.padding/2, float64(cellPos.Y), e.layersOfRecord(g.records[g.rows[row]], int32(icon)), e.tileScale)
+				}
+				textX += iconWidth
+			}
+			textY := float64(cellPos.Y) + (e.rowHeight+textHeight)/2 - 2
+			e.renderer.DrawTTFOnScreen(textX, textY, e.fitText(value, float64(cellRect.Max.X-cellRect.Min.X)-(textX-float64(cellPos.X))-e.padding/2), textColor)
+		}
+		rowBottom := origin.Y + e.gridCellRect(geometry.Point{Y: row}).Max.Y
+		e.renderer.DrawFilledRect(geometry.Point{X: viewport.Min.X, Y: rowBottom - 1}, geometry.Point{X: viewport.Size().X, Y: 1}, lineColor)
+	}
+	if len(g.rows) > 0 {
+		cursorRect := e.gridCellRect(g.cursor)
+		e.renderer.DrawRectOutline(origin.Add(cursorRect.Min), cursorRect.Size(), 2, e.theme.Current)
+	}
+
+	// header
+	headerArea := geometry.Rect{Min: viewport.Min, Max: geometry.Point{X: viewport.Max.X, Y: viewport.Min.Y + headerHeight}}
+	e.renderer.SetRenderTarget(e.clipTo(screen, headerArea))
+	e.renderer.DrawFilledRect(headerArea.Min, headerArea.Size(), e.theme.Field)
+	columnX := origin.X
+	for column, columnName := range g.columns {
+		label := columnName
+		if column == g.sortColumn && g.sortDescending {
+			label += " v"
+		} else if column == g.sortColumn {
+			label += " ^"
+		}
+		e.renderer.DrawTTFOnScreen(float64(columnX)+e.padding, float64(headerArea.Min.Y)+(float64(headerHeight)+textHeight)/2-2, label, e.theme.Cursor)
+		columnX += g.columnWidths[column]
+	}
+	e.renderer.SetRenderTarget(e.clipTo(screen, viewport))
+	columnX = origin.X
+	for _, width := range g.columnWidths {
+		columnX += width
+		e.renderer.DrawFilledRect(geometry.Point{X: columnX - 1, Y: viewport.Min.Y}, geometry.Point{X: 1, Y: viewport.Size().Y}, lineColor)
+	}
+	e.renderer.SetRenderTarget(screen)
+	e.drawScrollBars(&e.gridPane)
+
+	if g.errorText != "" {
+		errorWidth, _ := e.renderer.MeasureString(g.errorText)
+		boxSize := geometry.Point{X: int(errorWidth + e.padding*2), Y: int(textHeight + e.padding)}
+		boxPos := geometry.Point{X: viewport.Min.X + int(e.padding), Y: viewport.Max.Y - boxSize.Y - int(e.padding)}
+		e.renderer.DrawFilledRect(boxPos, boxSize, withAlpha(e.theme.Panel, 245))
+		e.renderer.DrawRectOutline(boxPos, boxSize, 1, e.theme.Current)
+		e.renderer.DrawTTFOnScreen(float64(boxPos.X)+e.padding, float64(boxPos.Y)+textHeight+e.padding/2-2, g.errorText, e.theme.Error)
+	}
+}
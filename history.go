@@ -0,0 +1,245 @@
+package main
+
+import (
+	"ReMapper/geometry"
+	"fmt"
+)
+
+// EditCommand is a reversible change of the editor state.
+// Every edit operation goes through Engine.execute, so it can be undone.
+type EditCommand interface {
+	Do(e *Engine)
+	Undo(e *Engine)
+	Description() string
+}
+
+// mergingCommand is implemented by commands that can absorb the command that follows them,
+// e.g. repeated clicks into the atlas for the same entry.
+type mergingCommand interface {
+	// MergeWith returns true if next was merged into the receiver.
+	MergeWith(next EditCommand) bool
+	// IsNoOp returns true if the command does not change anything (anymore).
+	IsNoOp() bool
+}
+
+type History struct {
+	commands      []EditCommand
+	position      int // number of commands that are currently applied
+	savedPosition int // position at the last save, -1 if that state is not reachable anymore
+	changeCount   int // incremented by every Execute, Undo and Redo, unlike position it never returns to an earlier value
+}
+
+func NewHistory() *History {
+	return &History{}
+}
+
+// Execute runs the command and records it. Commands that were undone before are dropped.
+func (h *History) Execute(e *Engine, command EditCommand) {
+	command.Do(e)
+	h.changeCount++
+	if h.savedPosition > h.position {
+		h.savedPosition = -1
+	}
+	h.commands = h.commands[:h.position]
+
+	if h.position > 0 && h.position != h.savedPosition {
+		if previous, isMerging := h.commands[h.position-1].(mergingCommand); isMerging && previous.MergeWith(command) {
+			if previous.IsNoOp() {
+				h.commands = h.commands[:h.position-1]
+				h.position--
+			}
+			return
+		}
+	}
+	h.commands = append(h.commands, command)
+	h.position++
+}
+
+func (h *History) Undo(e *Engine) bool {
+	if !h.CanUndo() {
+		return false
+	}
+	h.position--
+	h.commands[h.position].Undo(e)
+	h.changeCount++
+	return true
+}
+
+func (h *History) Redo(e *Engine) bool {
+	if !h.CanRedo() {
+		return false
+	}
+	h.commands[h.position].Do(e)
+	h.position++
+	h.changeCount++
+	return true
+}
+
+// Remove drops the commands for which isRemoved is true, the others keep their order.
+// Only commands that the remaining ones don't depend on may be removed.
+func (h *History) Remove(isRemoved func(command EditCommand) bool) {
+	kept := h.commands[:0]
+	position, savedPosition := h.position, h.savedPosition
+	for index, command := range h.commands {
+		if !isRemoved(command) {
+			kept = append(kept, command)
+			continue
+		}
+		if index < h.position {
+			position--
+		}
+		if index < h.savedPosition {
+			savedPosition--
+		}
+	}
+	clear(h.commands[len(kept):])
+	h.commands, h.position, h.savedPosition = kept, position, savedPosition
+}
+
+func (h *History) CanUndo() bool {
+	return h.position > 0
+}
+
+func (h *History) CanRedo() bool {
+	return h.position < len(h.commands)
+}
+
+func (h *History) MarkSaved() {
+	h.savedPosition = h.position
+}
+
+func (h *History) IsAtSavePoint() bool {
+	return h.position == h.savedPosition
+}
+
+func (e *Engine) execute(command EditCommand) {
+	e.history.Execute(e, command)
+	e.markChanged()
+}
+
+func (e *Engine) undo() bool {
+	e.markChanged()
+	return e.history.Undo(e)
+}
+
+func (e *Engine) redo() bool {
+	e.markChanged()
+	return e.history.Redo(e)
+}
+
+type iconChange struct {
+	Key     string
+	OldIcon int32
+	NewIcon int32
+}
+
+// assignIconCommand changes the icons of one or more entries at once.
+type assignIconCommand struct {
+	changes []iconChange
+}
+
+func (e *Engine) newAssignIconCommand(keys []string, icons []int32) *assignIconCommand {
+	command := &assignIconCommand{}
+	for i, key := range keys {
+		command.changes = append(command.changes, iconChange{Key: key, OldIcon: e.iconMapping[key], NewIcon: icons[i]})
+	}
+	return command
+}
+
+func (c *assignIconCommand) Do(e *Engine) {
+	for _, change := range c.changes {
+		e.iconMapping[change.Key] = change.NewIcon
+	}
+	e.onIconsChanged()
+}
+
+func (c *assignIconCommand) Undo(e *Engine) {
+	for i := len(c.changes) - 1; i >= 0; i-- {
+		change := c.changes[i]
+		e.iconMapping[change.Key] = change.OldIcon
+	}
+	e.onIconsChanged()
+}
+
+func (c *assignIconCommand) Description() string {
+	if len(c.changes) == 1 {
+		change := c.changes[0]
+		return fmt.Sprintf("%s: %d -> %d", change.Key, change.OldIcon, change.NewIcon)
+	}
+	return fmt.Sprintf("assign %d entries", len(c.changes))
+}
+
+func (c *assignIconCommand) MergeWith(next EditCommand) bool {
+	nextAssign, isAssign := next.(*assignIconCommand)
+	if !isAssign || len(c.changes) != 1 || len(nextAssign.changes) != 1 {
+		return false
+	}
+	if c.changes[0].Key != nextAssign.changes[0].Key {
+		return false
+	}
+	c.changes[0].NewIcon = nextAssign.changes[0].NewIcon
+	return true
+}
+
+func (c *assignIconCommand) IsNoOp() bool {
+	for _, change := range c.changes {
+		if change.OldIcon != change.NewIcon {
+			return false
+		}
+	}
+	return true
+}
+
+// onIconsChanged keeps the atlas selection and the cell usage in sync after icons were changed by a command.
+func (e *Engine) onIconsChanged() {
+	if e.selectedKey != "" {
+		e.selectedAtlasIndex = e.activeIconOf(e.selectedKey)
+	}
+	e.updateIconUsage()
+	e.updateBitmapFont()
+	e.rebuildGrid()
+}
+
+const historyPanelEntries = 8
+
+func (e *Engine) drawHistoryPanel() {
+	_, lineHeight := e.renderer.MeasureString("Ag")
+	lineHeight += 4
+	panelSize := geometry.Point{X: 320, Y: int(lineHeight*(historyPanelEntries+1) + e.padding*2)}
+	panelPos := geometry.Point{
+		X: e.atlasPane.bounds.Max.X - panelSize.X - int(e.padding),
+		Y: e.deviceIndependentScreenSize.Y - panelSize.Y - int(e.padding),
+	}
+	e.renderer.DrawFilledRect(panelPos, panelSize, withAlpha(e.theme.Panel, 230))
+	e.renderer.DrawRectOutline(panelPos, panelSize, 1, e.theme.Border)
+
+	drawX := float64(panelPos.X) + e.padding
+	drawY := float64(panelPos.Y) + e.padding + lineHeight - 4
+	header := "History"
+	if e.isDirty() {
+		header = "History - unsaved changes"
+	}
+	e.renderer.DrawTTFOnScreen(drawX, drawY, header, e.theme.Text)
+
+	commands := e.history.commands
+	first := max(0, len(commands)-historyPanelEntries)
+	if e.history.position < first+1 {
+		first = max(0, e.history.position-1)
+	}
+	last := min(len(commands), first+historyPanelEntries)
+	for index := first; index < last; index++ {
+		drawY += lineHeight
+		entryColor := e.theme.Text
+		if index >= e.history.position {
+			entryColor = e.theme.DisabledText // undone
+		}
+		label := commands[index].Description()
+		if index+1 == e.history.savedPosition {
+			label += "  (saved)"
+		}
+		if index+1 == e.history.position {
+			label = "> " + label
+		}
+		e.renderer.DrawTTFOnScreen(drawX, drawY, label, entryColor)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+type namedCommand struct {
+	name string
+}
+
+func (c *namedCommand) Do(e *Engine)        {}
+func (c *namedCommand) Undo(e *Engine)      {}
+func (c *namedCommand) Description() string { return c.name }
+
+func TestHistoryRemove(t *testing.T) {
+	history := NewHistory()
+	for _, name := range []string{"a", "map 1", "b", "map 2", "c", "map 3"} {
+		history.Execute(nil, &namedCommand{name: name})
+		if name == "b" {
+			history.MarkSaved()
+		}
+	}
+	history.Undo(nil) // map 3
+	history.Undo(nil) // c
+
+	history.Remove(func(command EditCommand) bool {
+		return command.Description()[0] == 'm'
+	})
+	var names []string
+	for _, command := range history.commands {
+		names = append(names, command.Description())
+	}
+	if want := []string{"a", "b", "c"}; !slices.Equal(names, want) {
+		t.Errorf("commands %v, want %v", names, want)
+	}
+	if history.position != 2 || history.savedPosition != 2 {
+		t.Errorf("position %d, saved position %d, want 2 and 2", history.position, history.savedPosition)
+	}
+}
@@ -7,16 +7,6 @@ import (
 
 func (e *Engine) handleInput() bool {
 
-    if inpututil.IsKeyJustPressed(ebiten.KeyF10) {
-        e.shouldQuit = true
-    }
-
-    if inpututil.IsKeyJustPressed(ebiten.KeyS) {
-        e.saveChanges(e.mappingFileName)
-        e.saveTicks = 30
-        return true
-    }
-
     mousePosInPixelsX, mousePosInPixelsY := ebiten.CursorPosition()
     mousePosInPixelsX = int(float64(mousePosInPixelsX) / e.deviceDPIScale)
     mousePosInPixelsY = int(float64(mousePosInPixelsY) / e.deviceDPIScale)
@@ -26,18 +16,244 @@ func (e *Engine) handleInput() bool {
         e.mousePosInPixels.Y = mousePosInPixelsY
         e.OnMouseMoved(e.mousePosInPixels)
     }
+    e.updateTooltip()
+
+    if e.handleDialogInput() {
+        return true
+    }
+
+    if inpututil.IsKeyJustPressed(ebiten.KeyF10) {
+        e.requestQuit()
+        return true
+    }
+
+    if inpututil.IsKeyJustPressed(ebiten.KeyF2) {
+        e.showHistory = !e.showHistory
+        return true
+    }
+
+    if inpututil.IsKeyJustPressed(ebiten.KeyF3) {
+        e.fillColumnMajor = !e.fillColumnMajor
+        return true
+    }
+
+    if inpututil.IsKeyJustPressed(ebiten.KeyF4) {
+        e.cycleOverlay()
+        return true
+    }
+
+    if inpututil.IsKeyJustPressed(ebiten.KeyF5) {
+        e.cycleGroupMode()
+        return true
+    }
+
+    if inpututil.IsKeyJustPressed(ebiten.KeyF6) {
+        e.toggleInspector()
+        return true
+    }
+
+    if inpututil.IsKeyJustPressed(ebiten.KeyF7) {
+        e.toggleGrid()
+        return true
+    }
+
+    if inpututil.IsKeyJustPressed(ebiten.KeyF8) {
+        e.openColorPicker()
+        return true
+    }
+
+    if inpututil.IsKeyJustPressed(ebiten.KeyF9) {
+        e.switchLayer((e.activeLayer + 1) % layerCount)
+        return true
+    }
+
+    if inpututil.IsKeyJustPressed(ebiten.KeyF11) {
+        e.toggleTimeline()
+        return true
+    }
+
+    if inpututil.IsKeyJustPressed(ebiten.KeyF12) {
+        e.toggleMapPreview()
+        return true
+    }
+
+    if e.handleJumpPrompt() {
+        return true
+    }
+
+    if e.handleTextPrompt() {
+        return true
+    }
+
+    if e.handleColorPicker() {
+        return true
+    }
+
+    if e.handleInspectorKeys() {
+        return true
+    }
+
+    if e.handleGridKeys() {
+        return true
+    }
+
+    if e.handlePlaytestKeys() {
+        return true
+    }
+
+    if e.handleMapEditorKeys() {
+        return true
+    }
+
+    if isControlPressed() {
+        if inpututil.IsKeyJustPressed(ebiten.KeyG) {
+            e.openJumpPrompt()
+            return true
+        }
+        if inpututil.IsKeyJustPressed(ebiten.KeyA) {
+            e.selectAllVisible()
+            return true
+        }
+        if inpututil.IsKeyJustPressed(ebiten.KeyN) {
+            e.openNewRecordPrompt()
+            return true
+        }
+        if inpututil.IsKeyJustPressed(ebiten.KeyD) {
+            e.openDuplicatePrompt()
+            return true
+        }
+        if inpututil.IsKeyJustPressed(ebiten.KeyR) {
+            e.openRenamePrompt()
+            return true
+        }
+        if inpututil.IsKeyJustPressed(ebiten.KeyO) {
+            e.openMapPrompt()
+            return true
+        }
+        if inpututil.IsKeyJustPressed(ebiten.KeyP) {
+            e.togglePlaytest()
+            return true
+        }
+        if inpututil.IsKeyJustPressed(ebiten.KeyE) {
+            e.toggleMapEditor()
+            return true
+        }
+        if inpututil.IsKeyJustPressed(ebiten.KeyT) {
+            e.toggleAutotile()
+            return true
+        }
+        if e.handleZoomKeys() {
+            return true
+        }
+        isShiftPressed := ebiten.IsKeyPressed(ebiten.KeyShift)
+        if isKeyRepeated(ebiten.KeyZ) && !isShiftPressed {
+            return e.undo()
+        }
+        if isKeyRepeated(ebiten.KeyY) || (isKeyRepeated(ebiten.KeyZ) && isShiftPressed) {
+            return e.redo()
+        }
+    }
+
+    if e.focus == focusList && inpututil.IsKeyJustPressed(ebiten.KeyDelete) {
+        return e.deleteSelectedRecords()
+    }
+
+    if e.focus == focusAtlas && e.activeLayer == layerBackground && inpututil.IsKeyJustPressed(ebiten.KeyDelete) {
+        return e.clearBackgroundIcons()
+    }
+
+    if e.handleNavigationKeys() {
+        return true
+    }
+
+    if e.handleSearchInput() {
+        return true
+    }
+
+    if isControlPressed() && inpututil.IsKeyJustPressed(ebiten.KeyS) {
+        e.saveAndReport()
+        return true
+    }
+
+    if e.grid.isActive {
+        return e.handleInspectorMouse() || e.handleGridMouse()
+    }
+
+    if e.handleOverlayClick() {
+        return true
+    }
+
+    if e.handleTimelineMouse() {
+        return true
+    }
+
+    if e.handleAutotileMouse() {
+        return true
+    }
+
+    if e.handleFontPanelMouse() {
+        return true
+    }
+
+    if e.handleToolbarClick() {
+        return true
+    }
+
+    if e.handleMapPreviewMouse() {
+        return true
+    }
+
+    if e.handleInspectorMouse() {
+        return true
+    }
+
+    if e.handlePaneMouse() {
+        return true
+    }
+
+    if e.handleAtlasDrag() {
+        return true
+    }
 
     if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
         return e.handleMouseClick()
     }
 
-    _, dy := ebiten.Wheel()
-    if dy != 0 {
-        sensitity := 4.0
-        e.scrollOffset += dy * sensitity
-        e.updateElementBounds()
+    return false
+}
+
+// handleSearchInput feeds typed characters into the search box.
+func (e *Engine) handleSearchInput() bool {
+    if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+        e.setSearchText("")
+        return true
+    }
+    if isKeyRepeated(ebiten.KeyBackspace) {
+        e.deleteLastSearchChar()
+        return true
+    }
+    if isControlPressed() {
+        return false
+    }
+    e.inputChars = ebiten.AppendInputChars(e.inputChars[:0])
+    if len(e.inputChars) > 0 {
+        e.appendSearchChars(e.inputChars)
         return true
     }
-
     return false
 }
+
+func isControlPressed() bool {
+    return ebiten.IsKeyPressed(ebiten.KeyControl) || ebiten.IsKeyPressed(ebiten.KeyMeta)
+}
+
+// isKeyRepeated is true on the first frame of a key press and then repeats while the key is held down.
+func isKeyRepeated(key ebiten.Key) bool {
+    const delay = 30
+    const interval = 3
+    duration := inpututil.KeyPressDuration(key)
+    if duration == 1 {
+        return true
+    }
+    return duration >= delay && (duration-delay)%interval == 0
+}
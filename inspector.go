@@ -0,0 +1,495 @@
+package main
+
+import (
+	"ReMapper/geometry"
+	"ReMapper/recfile"
+	"fmt"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"image/color"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const inspectorWidth = 360
+
+// fieldKind decides how the value of a field is edited in the inspector.
+type fieldKind int
+
+const (
+	kindText fieldKind = iota
+	kindInt
+	kindReal
+	kindBool
+	kindEnum
+)
+
+func (k fieldKind) String() string {
+	switch k {
+	case kindInt:
+		return "int"
+	case kindReal:
+		return "real"
+	case kindBool:
+		return "bool"
+	case kindEnum:
+		return "enum"
+	}
+	return "text"
+}
+
+// recordSchema is what the %rec descriptor of the mapping tells about its fields.
+// %type gives the kind, e.g. "%type: hp int" or "%type: size enum small medium large",
+// %allowed and %mandatory name the fields a record may have.
+type recordSchema struct {
+	kinds         map[string]fieldKind
+	enumValues    map[string][]string
+	allowedFields []string // empty if the descriptor allows any field
+}
+
+var fieldNamePattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]*$`)
+
+// isDescriptor is true for the records that describe a %rec section instead of holding data.
+func isDescriptor(record recfile.Record) bool {
+	for _, field := range record {
+		if !strings.HasPrefix(field.Name, "%") {
+			return false
+		}
+	}
+	return len(record) > 0
+}
+
+func schemaOf(records []recfile.Record) recordSchema {
+	schema := recordSchema{kinds: make(map[string]fieldKind), enumValues: make(map[string][]string)}
+	for _, record := range records {
+		if !isDescriptor(record) {
+			continue
+		}
+		for _, field := range record {
+			words := strings.Fields(field.Value)
+			if field.Name == "%allowed" || field.Name == "%mandatory" {
+				schema.allowedFields = append(schema.allowedFields, words...)
+				continue
+			}
+			if field.Name != "%type" || len(words) < 2 {
+				continue
+			}
+			kind := kindText
+			switch words[1] {
+			case "int", "range":
+				kind = kindInt
+			case "real":
+				kind = kindReal
+			case "bool":
+				kind = kindBool
+			case "enum":
+				kind = kindEnum
+			}
+			for _, fieldName := range strings.Split(words[0], ",") {
+				schema.kinds[fieldName] = kind
+				if kind == kindEnum {
+					schema.enumValues[fieldName] = words[2:]
+				}
+			}
+		}
+	}
+	return schema
+}
+
+// kindOf uses the descriptor, fields without a %type are text.
+func (s recordSchema) kindOf(fieldName string) fieldKind {
+	if kind, isDescribed := s.kinds[fieldName]; isDescribed {
+		return kind
+	}
+	return kindText
+}
+
+func (s recordSchema) isAllowed(fieldName string) bool {
+	return len(s.allowedFields) == 0 || slices.Contains(s.allowedFields, fieldName)
+}
+
+func (s recordSchema) validate(field recfile.Field) error {
+	switch s.kindOf(field.Name) {
+	case kindInt:
+		if _, err := strconv.Atoi(field.Value); err != nil {
+			return fmt.Errorf("%s must be a whole number", field.Name)
+		}
+	case kindReal:
+		if _, err := strconv.ParseFloat(field.Value, 64); err != nil {
+			return fmt.Errorf("%s must be a number", field.Name)
+		}
+	case kindEnum:
+		if values := s.enumValues[field.Name]; !slices.Contains(values, field.Value) {
+			return fmt.Errorf("%s must be one of %s", field.Name, strings.Join(values, ", "))
+		}
+	}
+	return nil
+}
+
+func (s recordSchema) defaultValue(fieldName string) string {
+	switch s.kinds[fieldName] {
+	case kindInt:
+		return "0"
+	case kindReal:
+		return "0.0"
+	case kindBool:
+		return "false"
+	case kindEnum:
+		if values := s.enumValues[fieldName]; len(values) > 0 {
+			return values[0]
+		}
+	}
+	return ""
+}
+
+// inspectorState is the field that is edited and the layout of the last frame.
+type inspectorState struct {
+	editingKey   string
+	editingField int // index into the record, -1 if no field is edited
+	editText     string
+	errorText    string
+	rows         []inspectorRow
+	addBounds    geometry.Rect
+}
+
+type inspectorRow struct {
+	fieldIndex   int
+	valueLines   []string
+	namePos      geometry.PointF
+	bounds       geometry.Rect
+	removeBounds geometry.Rect
+}
+
+// inspectedRecord returns a copy of the selected record with the current icon, or nil.
+func (e *Engine) inspectedRecord() recfile.Record {
+	record, isMapped := e.keyRecords[e.selectedKey]
+	if !isMapped {
+		return nil
+	}
+	records := []recfile.Record{slices.Clone(record)}
+	applyIconMapping(records, e.config, e.iconMapping)
+	return records[0]
+}
+
+func (e *Engine) inspectedSchema() recordSchema {
+	return schemaOf(e.originalRecords)
+}
+
+func (e *Engine) toggleInspector() {
+	e.showInspector = !e.showInspector
+	e.cancelFieldEdit()
+	e.updateElementBounds()
+}
+
+func (e *Engine) cancelFieldEdit() {
+	e.inspector.editingField = -1
+	e.inspector.errorText = ""
+}
+
+func (e *Engine) startFieldEdit(fieldIndex int, value string) {
+	e.inspector.editingKey = e.selectedKey
+	e.inspector.editingField = fieldIndex
+	e.inspector.editText = value
+	e.inspector.errorText = ""
+}
+
+// commitFieldEdit validates the edited value and stores it. It returns false if the value is invalid.
+func (e *Engine) commitFieldEdit() bool {
+	record := e.inspectedRecord()
+	fieldIndex := e.inspector.editingField
+	if fieldIndex < 0 || fieldIndex >= len(record) {
+		e.cancelFieldEdit()
+		return true
+	}
+	field := recfile.Field{Name: record[fieldIndex].Name, Value: e.inspector.editText}
+	if field.Name == e.config.IconField {
+		icon, err := strconv.ParseInt(field.Value, 10, 32)
+		if err != nil {
+			e.inspector.errorText = fmt.Sprintf("%s must be a whole number", field.Name)
+			return false
+		}
+		e.cancelFieldEdit()
+		e.execute(e.newAssignIconCommand([]string{e.selectedKey}, []int32{int32(icon)}))
+		return true
+	}
+	if err := e.inspectedSchema().validate(field); err != nil {
+		e.inspector.errorText = err.Error()
+		return false
+	}
+	e.cancelFieldEdit()
+	e.setRecordField(e.selectedKey, fieldIndex, field.Value)
+	return true
+}
+
+// setRecordField changes the value of a field of the record with the given key as an undoable command.
+func (e *Engine) setRecordField(key string, fieldIndex int, value string) {
+	record := e.inspectedRecord()
+	if record[fieldIndex].Value == value {
+		return
+	}
+	e.editRecords(fmt.Sprintf("set %s of %s", record[fieldIndex].Name, key), func(records []recfile.Record, mapping map[string]int32) ([]recfile.Record, string) {
+		recordIndex := e.recordIndexOfKey(records, key)
+		records[recordIndex][fieldIndex].Value = value
+		return records, ""
+	})
+}
+
+func (e *Engine) removeRecordField(key string, fieldIndex int) {
+	record := e.inspectedRecord()
+	e.editRecords(fmt.Sprintf("remove %s of %s", record[fieldIndex].Name, key), func(records []recfile.Record, mapping map[string]int32) ([]recfile.Record, string) {
+		recordIndex := e.recordIndexOfKey(records, key)
+		records[recordIndex] = slices.Delete(records[recordIndex], fieldIndex, fieldIndex+1)
+		return records, ""
+	})
+}
+
+func (e *Engine) openAddFieldPrompt() {
+	key := e.selectedKey
+	e.openTextPrompt(fmt.Sprintf("Add field to %s", key), "", func(name string) error {
+		if !fieldNamePattern.MatchString(name) {
+			return fmt.Errorf("a field name starts with a letter and contains only letters, digits and _")
+		}
+		if name == e.config.KeyField {
+			return fmt.Errorf("%s already has a %s", key, name)
+		}
+		schema := e.inspectedSchema()
+		if !schema.isAllowed(name) {
+			return fmt.Errorf("the descriptor allows only %s", strings.Join(schema.allowedFields, ", "))
+		}
+		value := schema.defaultValue(name)
+		e.editRecords(fmt.Sprintf("add %s to %s", name, key), func(records []recfile.Record, mapping map[string]int32) ([]recfile.Record, string) {
+			recordIndex := e.recordIndexOfKey(records, key)
+			records[recordIndex] = append(records[recordIndex], recfile.Field{Name: name, Value: value})
+			return records, ""
+		})
+		if kind := schema.kinds[name]; kind != kindBool && kind != kindEnum {
+			e.startFieldEdit(len(e.inspectedRecord())-1, value)
+		}
+		return nil
+	})
+}
+
+// clickField toggles booleans, cycles enums and starts editing all other fields.
+func (e *Engine) clickField(fieldIndex int) {
+	record := e.inspectedRecord()
+	field := record[fieldIndex]
+	if field.Name == e.config.KeyField {
+		e.openRenamePrompt()
+		return
+	}
+	if field.Name == e.config.ColorField {
+		e.openColorPicker()
+		return
+	}
+	schema := e.inspectedSchema()
+	switch schema.kindOf(field.Name) {
+	case kindBool:
+		e.setRecordField(e.selectedKey, fieldIndex, recfile.BoolStr(!field.AsBool()))
+	case kindEnum:
+		values := schema.enumValues[field.Name]
+		if len(values) == 0 {
+			return
+		}
+		step := 1
+		if ebiten.IsKeyPressed(ebiten.KeyShift) {
+			step = len(values) - 1
+		}
+		next := (slices.Index(values, field.Value) + step) % len(values)
+		e.setRecordField(e.selectedKey, fieldIndex, values[max(0, next)])
+	default:
+		e.startFieldEdit(fieldIndex, field.Value)
+	}
+}
+
+// handleInspectorKeys edits the value of a field. Shift+Enter starts a new line in text fields.
+func (e *Engine) handleInspectorKeys() bool {
+	state := &e.inspector
+	if state.editingField < 0 {
+		return false
+	}
+	if state.editingKey != e.selectedKey || !e.showInspector {
+		e.cancelFieldEdit()
+		return false
+	}
+	record := e.inspectedRecord()
+	kind := e.inspectedSchema().kindOf(record[state.editingField].Name)
+	if record[state.editingField].Name == e.config.IconField {
+		kind = kindInt
+	}
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyEscape):
+		e.cancelFieldEdit()
+	case isEnterJustPressed() && ebiten.IsKeyPressed(ebiten.KeyShift) && kind == kindText:
+		state.editText += "\n"
+	case isEnterJustPressed():
+		e.commitFieldEdit()
+	case isKeyRepeated(ebiten.KeyBackspace):
+		if state.editText != "" {
+			_, lastSize := utf8.DecodeLastRuneInString(state.editText)
+			state.editText = state.editText[:len(state.editText)-lastSize]
+		}
+	case kind == kindInt && (isKeyRepeated(ebiten.KeyArrowUp) || isKeyRepeated(ebiten.KeyArrowDown)):
+		value, _ := strconv.Atoi(state.editText)
+		if isKeyRepeated(ebiten.KeyArrowUp) {
+			value++
+		} else {
+			value--
+		}
+		state.editText = strconv.Itoa(value)
+	default:
+		e.inputChars = ebiten.AppendInputChars(e.inputChars[:0])
+		if isControlPressed() {
+			return true
+		}
+		for _, char := range e.inputChars {
+			isNumberChar := unicode.IsDigit(char) || char == '-' || (kind == kindReal && char == '.')
+			if (kind == kindText && unicode.IsPrint(char)) || ((kind == kindInt || kind == kindReal) && isNumberChar) {
+				state.editText += string(char)
+			}
+		}
+		return true
+	}
+	state.errorText = ""
+	return true
+}
+
+// layoutInspector places the fields of the record inside of the inspector pane.
+func (e *Engine) layoutInspector(record recfile.Record) {
+	state := &e.inspector
+	_, lineHeight := e.renderer.MeasureString("Ag")
+	lineHeight += 4
+	viewport := e.inspectorPane.viewport()
+	origin := e.inspectorPane.contentOrigin()
+	drawX := float64(origin.X) + e.padding
+	drawY := float64(origin.Y) + e.padding + lineHeight*2
+	width := viewport.Size().X - int(e.padding*2)
+
+	state.rows = state.rows[:0]
+	for fieldIndex, field := range record {
+		value := field.Value
+		if fieldIndex == state.editingField {
+			value = state.editText + "_"
+		}
+		row := inspectorRow{
+			fieldIndex: fieldIndex,
+			valueLines: strings.Split(value, "\n"),
+			namePos:    geometry.PointF{X: drawX, Y: drawY},
+		}
+		rowHeight := lineHeight * float64(1+len(row.valueLines))
+		row.bounds = geometry.NewRect(int(drawX), int(drawY), int(drawX)+width, int(drawY+rowHeight))
+		row.removeBounds = geometry.NewRect(int(drawX)+width-int(lineHeight), int(drawY), int(drawX)+width, int(drawY+lineHeight))
+		state.rows = append(state.rows, row)
+		drawY += rowHeight + e.padding/2
+	}
+	state.addBounds = geometry.NewRect(int(drawX), int(drawY), int(drawX)+width, int(drawY+lineHeight))
+	e.inspectorPane.contentSize = geometry.Point{X: viewport.Size().X, Y: int(drawY+lineHeight*2+e.padding) - origin.Y}
+}
+
+func (e *Engine) isProtectedField(fieldName string) bool {
+	return fieldName == e.config.KeyField || fieldName == e.config.IconField
+}
+
+func (e *Engine) handleInspectorMouse() bool {
+	if !e.showInspector || !e.inspectorPane.bounds.Contains(e.mousePosInPixels) && e.inspectorPane.drag == dragNone {
+		return false
+	}
+	if e.inspectorPane.handleMouse(e.mousePosInPixels) {
+		return true
+	}
+	if !inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		return false
+	}
+	record := e.inspectedRecord()
+	if record == nil {
+		return true
+	}
+	e.layoutInspector(record)
+	if e.inspector.editingField >= 0 && !e.commitFieldEdit() {
+		return true
+	}
+	record = e.inspectedRecord()
+	for _, row := range e.inspector.rows {
+		if row.fieldIndex >= len(record) {
+			continue
+		}
+		fieldName := record[row.fieldIndex].Name
+		if row.removeBounds.Contains(e.mousePosInPixels) && !e.isProtectedField(fieldName) {
+			e.removeRecordField(e.selectedKey, row.fieldIndex)
+			return true
+		}
+		if row.bounds.Contains(e.mousePosInPixels) {
+			e.clickField(row.fieldIndex)
+			return true
+		}
+	}
+	if e.inspector.addBounds.Contains(e.mousePosInPixels) {
+		e.openAddFieldPrompt()
+	}
+	return true
+}
+
+func (e *Engine) drawInspector(screen *ebiten.Image) {
+	bounds := e.inspectorPane.bounds
+	e.renderer.DrawFilledRect(bounds.Min, bounds.Size(), e.theme.Background)
+	e.renderer.DrawFilledRect(bounds.Min, geometry.Point{X: 1, Y: bounds.Size().Y}, e.theme.Separator)
+
+	_, lineHeight := e.renderer.MeasureString("Ag")
+	lineHeight += 4
+	record := e.inspectedRecord()
+	origin := e.inspectorPane.contentOrigin()
+	headerX := float64(origin.X) + e.padding
+	headerY := float64(origin.Y) + e.padding + lineHeight - 4
+	e.renderer.SetRenderTarget(e.clipTo(screen, e.inspectorPane.viewport()))
+	if record == nil {
+		e.renderer.DrawTTFOnScreen(headerX, headerY, "Inspector (F6) - nothing selected", e.theme.MutedText)
+		e.renderer.SetRenderTarget(screen)
+		return
+	}
+	e.layoutInspector(record)
+	e.renderer.DrawTTFOnScreen(headerX, headerY, "Inspector (F6)", e.theme.Text)
+	e.renderer.DrawTTFOnScreen(headerX, headerY+lineHeight, e.selectedKey, e.theme.Cursor)
+
+	schema := e.inspectedSchema()
+	nameColor := e.theme.MutedText
+	for _, row := range e.inspector.rows {
+		field := record[row.fieldIndex]
+		isEditing := row.fieldIndex == e.inspector.editingField
+		if isEditing {
+			e.renderer.DrawFilledRect(row.bounds.Min, row.bounds.Size(), e.theme.Modified)
+		} else if row.bounds.Contains(e.mousePosInPixels) {
+			e.renderer.DrawFilledRect(row.bounds.Min, row.bounds.Size(), e.theme.Field)
+		}
+		kind := schema.kindOf(field.Name)
+		nameLabel := fmt.Sprintf("%s  [%s]", field.Name, kind)
+		if kind == kindEnum {
+			nameLabel = fmt.Sprintf("%s  [%s]", field.Name, strings.Join(schema.enumValues[field.Name], "|"))
+		}
+		e.renderer.DrawTTFOnScreen(row.namePos.X, row.namePos.Y+lineHeight-4, nameLabel, nameColor)
+		if !e.isProtectedField(field.Name) {
+			e.renderer.DrawTTFOnScreen(float64(row.removeBounds.Min.X), row.namePos.Y+lineHeight-4, "x", e.theme.Error)
+		}
+		valueColor := color.Color(e.theme.Text)
+		if isEditing {
+			valueColor = e.theme.Cursor
+		}
+		for lineIndex, line := range row.valueLines {
+			lineY := row.namePos.Y + lineHeight*float64(lineIndex+2) - 4
+			e.renderer.DrawTTFOnScreen(row.namePos.X+e.padding, lineY, line, valueColor)
+		}
+	}
+	addColor := e.theme.Add
+	if e.inspector.addBounds.Contains(e.mousePosInPixels) {
+		addColor = e.theme.AddHover
+	}
+	addPos := e.inspector.addBounds.Min
+	e.renderer.DrawTTFOnScreen(float64(addPos.X), float64(addPos.Y)+lineHeight-4, "+ Add field", addColor)
+	if e.inspector.errorText != "" {
+		e.renderer.DrawTTFOnScreen(float64(addPos.X), float64(addPos.Y)+lineHeight*2-4, e.inspector.errorText, e.theme.Error)
+	}
+	e.renderer.SetRenderTarget(screen)
+	e.drawScrollBars(&e.inspectorPane)
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"ReMapper/geometry"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"strconv"
+	"unicode"
+)
+
+type focusPane int
+
+const (
+	focusList focusPane = iota
+	focusAtlas
+)
+
+// jumpPrompt asks for a number: an entry number when the list has focus,
+// an atlas index when the atlas has focus.
+type jumpPrompt struct {
+	isOpen bool
+	text   string
+	target focusPane
+}
+
+func (e *Engine) handleNavigationKeys() bool {
+	if inpututil.IsKeyJustPressed(ebiten.KeyTab) {
+		if e.focus == focusList {
+			e.setFocus(focusAtlas)
+		} else {
+			e.setFocus(focusList)
+		}
+		return true
+	}
+	if e.focus == focusAtlas {
+		return e.handleAtlasKeys()
+	}
+	return e.handleListKeys()
+}
+
+func (e *Engine) handleListKeys() bool {
+	if len(e.visibleKeys) == 0 {
+		return false
+	}
+	newIndex := e.selectedListIndex
+	switch {
+	case isKeyRepeated(ebiten.KeyArrowUp):
+		newIndex--
+	case isKeyRepeated(ebiten.KeyArrowDown):
+		newIndex++
+	case isKeyRepeated(ebiten.KeyPageUp):
+		newIndex -= e.listPageSize()
+	case isKeyRepeated(ebiten.KeyPageDown):
+		newIndex += e.listPageSize()
+	case inpututil.IsKeyJustPressed(ebiten.KeyHome):
+		newIndex = 0
+	case inpututil.IsKeyJustPressed(ebiten.KeyEnd):
+		newIndex = len(e.visibleKeys) - 1
+	case isEnterJustPressed() && e.selectedListIndex >= 0:
+		e.setFocus(focusAtlas)
+		return true
+	default:
+		return false
+	}
+	if e.selectedListIndex < 0 && newIndex < 0 {
+		newIndex = 0
+	}
+	newIndex = clamp(newIndex, 0, len(e.visibleKeys)-1)
+	newIndex = e.nearestShownEntry(newIndex, newIndex-e.selectedListIndex)
+	if ebiten.IsKeyPressed(ebiten.KeyShift) && e.selectionAnchor != "" {
+		e.selectRangeTo(newIndex, false)
+	} else {
+		e.selectListIndex(newIndex)
+	}
+	e.scrollToSelection()
+	return true
+}
+
+func (e *Engine) handleAtlasKeys() bool {
+	cellCount := e.tileAtlas.GetCellCount()
+	newCursor := e.atlasCursor
+	switch {
+	case isKeyRepeated(ebiten.KeyArrowLeft):
+		newCursor.X--
+	case isKeyRepeated(ebiten.KeyArrowRight):
+		newCursor.X++
+	case isKeyRepeated(ebiten.KeyArrowUp):
+		newCursor.Y--
+	case isKeyRepeated(ebiten.KeyArrowDown):
+		newCursor.Y++
+	case isKeyRepeated(ebiten.KeyPageUp):
+		newCursor.Y -= atlasPageRows
+	case isKeyRepeated(ebiten.KeyPageDown):
+		newCursor.Y += atlasPageRows
+	case inpututil.IsKeyJustPressed(ebiten.KeyHome):
+		newCursor = geometry.Point{}
+	case inpututil.IsKeyJustPressed(ebiten.KeyEnd):
+		newCursor = geometry.Point{X: cellCount.X - 1, Y: cellCount.Y - 1}
+	case isEnterJustPressed():
+		return e.assignAtlasCell(e.atlasCursor)
+	default:
+		return false
+	}
+	e.atlasCursor = geometry.Point{X: clamp(newCursor.X, 0, cellCount.X-1), Y: clamp(newCursor.Y, 0, cellCount.Y-1)}
+	e.scrollToAtlasCursor()
+	return true
+}
+
+const atlasPageRows = 8
+
+// assignAtlasCell assigns the atlas cell at gridPos to the selected entry.
+// With several selected entries, consecutive cells starting at gridPos are assigned in list order.
+// With Alt held down, all selected entries get the same cell.
+func (e *Engine) assignAtlasCell(gridPos geometry.Point) bool {
+	if e.timeline.isOpen {
+		return e.insertFrames([]geometry.Point{gridPos})
+	}
+	if e.autotile.isOpen {
+		return e.assignAutotileCells([]geometry.Point{gridPos})
+	}
+	if e.font.isChaining {
+		return e.assignFontChain(gridPos)
+	}
+	if selectedCount := len(e.selectedVisibleIndices()); selectedCount > 1 {
+		if ebiten.IsKeyPressed(ebiten.KeyAlt) {
+			sameCell := make([]geometry.Point, selectedCount)
+			for i := range sameCell {
+				sameCell[i] = gridPos
+			}
+			return e.assignCells(sameCell)
+		}
+		return e.assignCells(e.plannedCells(gridPos))
+	}
+	if e.selectedListIndex < 0 || e.selectedListIndex >= len(e.visibleKeys) {
+		return false
+	}
+	atlasIndex := XYToIndex(gridPos.X, gridPos.Y, e.tileAtlas.GetCellCount().X)
+	selectedKey := e.visibleKeys[e.selectedListIndex]
+	e.assignIcons([]string{selectedKey}, []int32{int32(atlasIndex)})
+	return true
+}
+
+func (e *Engine) setFocus(focus focusPane) {
+	e.focus = focus
+	if focus == focusAtlas && e.selectedAtlasIndex >= 0 {
+		cursorX, cursorY := IndexToXY(int(e.selectedAtlasIndex), e.tileAtlas.GetCellCount().X)
+		e.atlasCursor = geometry.Point{X: cursorX, Y: cursorY}
+	}
+}
+
+func (e *Engine) listPageSize() int {
+	visibleHeight := float64(e.listPane.viewport().Size().Y)
+	return max(1, int(visibleHeight/e.rowHeight)-1)
+}
+
+// scrollToSelection scrolls the list just enough to make the selected entry visible.
+func (e *Engine) scrollToSelection() {
+	if e.selectedListIndex < 0 || e.selectedListIndex >= len(e.visibleKeys) {
+		return
+	}
+	if e.rowOfEntry[e.selectedListIndex] < 0 {
+		e.expandGroupsOf(e.selectedListIndex)
+	}
+	rowTop := int(float64(e.rowOfEntry[e.selectedListIndex]) * e.rowHeight)
+	e.listPane.scrollToShow(geometry.NewRect(0, rowTop, 0, rowTop+int(e.rowHeight)))
+	e.updateElementBounds()
+}
+
+// scrollToAtlasCursor scrolls the atlas just enough to make the cell under the cursor visible.
+func (e *Engine) scrollToAtlasCursor() {
+	cellTopLeft := e.gridToScreen(e.atlasCursor).Sub(e.atlasPane.contentOrigin())
+	cellSize := e.tileAtlas.GetTileSize().MulF(e.atlasScale)
+	e.atlasPane.scrollToShow(geometry.Rect{Min: cellTopLeft, Max: cellTopLeft.Add(cellSize)})
+	e.updateElementBounds()
+}
+
+func (e *Engine) openJumpPrompt() {
+	e.jumpPrompt = jumpPrompt{isOpen: true, target: e.focus}
+}
+
+// handleJumpPrompt consumes all keyboard input while the prompt is open.
+func (e *Engine) handleJumpPrompt() bool {
+	if !e.jumpPrompt.isOpen {
+		return false
+	}
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyEscape):
+		e.jumpPrompt.isOpen = false
+	case isKeyRepeated(ebiten.KeyBackspace):
+		if len(e.jumpPrompt.text) > 0 {
+			e.jumpPrompt.text = e.jumpPrompt.text[:len(e.jumpPrompt.text)-1]
+		}
+	case isEnterJustPressed():
+		e.jumpPrompt.isOpen = false
+		if number, err := strconv.Atoi(e.jumpPrompt.text); err == nil {
+			e.jumpTo(number)
+		}
+	default:
+		e.inputChars = ebiten.AppendInputChars(e.inputChars[:0])
+		for _, char := range e.inputChars {
+			if unicode.IsDigit(char) {
+				e.jumpPrompt.text += string(char)
+			}
+		}
+	}
+	return true
+}
+
+func (e *Engine) jumpTo(number int) {
+	if e.jumpPrompt.target == focusAtlas {
+		cellCount := e.tileAtlas.GetCellCount()
+		number = clamp(number, 0, cellCount.X*cellCount.Y-1)
+		cursorX, cursorY := IndexToXY(number, cellCount.X)
+		e.atlasCursor = geometry.Point{X: cursorX, Y: cursorY}
+		e.scrollToAtlasCursor()
+		return
+	}
+	if len(e.visibleKeys) == 0 {
+		return
+	}
+	// entries are counted from 1
+	e.selectListIndex(clamp(number-1, 0, len(e.visibleKeys)-1))
+	e.scrollToSelection()
+}
+
+func (e *Engine) drawJumpPrompt() {
+	label := "Go to entry: "
+	if e.jumpPrompt.target == focusAtlas {
+		label = "Go to atlas index: "
+	}
+	label += e.jumpPrompt.text + "_"
+	textWidth, textHeight := e.renderer.MeasureString(label)
+	size := geometry.Point{X: int(textWidth + e.padding*4), Y: int(textHeight + e.padding*2)}
+	pos := geometry.Point{X: (e.deviceIndependentScreenSize.X - size.X) / 2, Y: (e.deviceIndependentScreenSize.Y - size.Y) / 2}
+	e.renderer.DrawFilledRect(pos, size, withAlpha(e.theme.Panel, 245))
+	e.renderer.DrawRectOutline(pos, size, 2, e.theme.Cursor)
+	e.renderer.DrawTTFOnScreen(float64(pos.X)+e.padding*2, float64(pos.Y)+e.padding+textHeight-2, label, e.theme.Text)
+}
+
+// drawFocusIndicators outlines the pane that receives the keyboard input.
+func (e *Engine) drawFocusIndicators() {
+	focusColor := e.theme.Cursor
+	if e.focus == focusList {
+		e.renderer.DrawRectOutline(geometry.Point{X: 1, Y: 1}, geometry.Point{X: e.splitterX - 2, Y: e.deviceIndependentScreenSize.Y - 2}, 2, focusColor)
+		return
+	}
+	e.renderer.DrawRectOutline(e.atlasPane.bounds.Min.Shift(1, 1), e.atlasPane.bounds.Size().Shift(-2, -2), 2, focusColor)
+}
+
+func isEnterJustPressed() bool {
+	return inpututil.IsKeyJustPressed(ebiten.KeyEnter) || inpututil.IsKeyJustPressed(ebiten.KeyNumpadEnter)
+}
+
+func clamp(value, minValue, maxValue int) int {
+	return max(minValue, min(value, maxValue))
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"ReMapper/geometry"
+	"ReMapper/recfile"
+	"ReMapper/renderer"
+	"fmt"
+	"image/color"
+	"slices"
+	"strconv"
+)
+
+// mappingLayer is the part of an entry that clicks into the atlas assign.
+type mappingLayer int
+
+const (
+	layerForeground mappingLayer = iota
+	layerBackground
+	layerCount
+)
+
+func (l mappingLayer) String() string {
+	if l == layerBackground {
+		return "Background"
+	}
+	return "Glyph"
+}
+
+// glyphLayers is a glyph drawn over an optional background tile, each with its own color.
+// A background color without a background tile fills the cell. The orientation and the frames
+// apply to the glyph.
+type glyphLayers struct {
+	icon        int32
+	fgColor     color.Color
+	orientation renderer.TileOrientation
+	frames      renderer.Animation
+	bgIcon      int32
+	hasBgIcon   bool
+	bgColor     color.Color
+	hasBgColor  bool
+}
+
+func (e *Engine) layersOf(key string) glyphLayers {
+	return e.layersOfRecord(e.keyRecords[key], e.iconMapping[key])
+}
+
+func (e *Engine) layersOfRecord(record recfile.Record, icon int32) glyphLayers {
+	layers := glyphLayers{icon: icon, fgColor: e.tintOfRecord(record), orientation: e.orientationOf(record), bgColor: color.White}
+	layers.frames = e.animationOf(record, icon)
+	if bgIcon, hasBgIcon := findField(record, e.config.BgIconField); hasBgIcon {
+		layers.bgIcon = recfile.Field{Value: bgIcon}.AsInt32()
+		layers.hasBgIcon = true
+	}
+	if bgColor, isValid := e.parseColor(record.FindFirstFieldValue(e.config.BgColorField)); isValid {
+		layers.bgColor = bgColor
+		layers.hasBgColor = true
+	}
+	return layers
+}
+
+// drawInfos returns the tile layers from bottom to top, the way MapRenderer draws the infos of a cell.
+func (l glyphLayers) drawInfos(atlas renderer.TextureAtlas) []renderer.CellDrawInfo {
+	var infos []renderer.CellDrawInfo
+	if l.hasBgIcon {
+		infos = append(infos, renderer.CellDrawInfo{Icon: l.bgIcon, Color: l.bgColor, Atlas: atlas})
+	}
+	return append(infos, renderer.CellDrawInfo{Icon: l.icon, Color: l.fgColor, Atlas: atlas, Orientation: l.orientation, Frames: l.frames})
+}
+
+// drawLayers draws the layers of an entry at the given screen position, scale includes the tile scale.
+func (e *Engine) drawLayers(x, y float64, layers glyphLayers, scale float64) {
+	if layers.hasBgColor && !layers.hasBgIcon {
+		tileSize := e.tileAtlas.GetTileSize()
+		cellSize := geometry.Point{X: int(float64(tileSize.X) * scale), Y: int(float64(tileSize.Y) * scale)}
+		e.renderer.DrawFilledRect(geometry.Point{X: int(x), Y: int(y)}, cellSize, layers.bgColor)
+	}
+	for _, info := range layers.drawInfos(e.tileAtlas) {
+		e.renderer.DrawTileWithOrientation(x, y, info.Atlas, info.IconAt(e.ticks), geometry.PointF{X: scale, Y: scale}, info.Color, info.Orientation)
+	}
+}
+
+// activeIconOf returns the icon of the active layer of an entry, -1 if it has no background tile.
+func (e *Engine) activeIconOf(key string) int32 {
+	if e.activeLayer == layerForeground {
+		return e.iconMapping[key]
+	}
+	layers := e.layersOf(key)
+	if !layers.hasBgIcon {
+		return -1
+	}
+	return layers.bgIcon
+}
+
+func (e *Engine) switchLayer(layer mappingLayer) {
+	e.activeLayer = layer
+	if e.selectedKey != "" {
+		e.selectedAtlasIndex = e.activeIconOf(e.selectedKey)
+	}
+}
+
+// assignIcons assigns the icons to the active layer of the entries as one undoable command.
+func (e *Engine) assignIcons(keys []string, icons []int32) {
+	if e.activeLayer == layerForeground {
+		e.execute(e.newAssignIconCommand(keys, icons))
+		return
+	}
+	description := fmt.Sprintf("%s: background -> %d", keys[0], icons[0])
+	if len(keys) > 1 {
+		description = fmt.Sprintf("assign background of %d entries", len(keys))
+	}
+	e.editRecords(description, func(records []recfile.Record, mapping map[string]int32) ([]recfile.Record, string) {
+		for index, key := range keys {
+			if recordIndex := e.recordIndexOfKey(records, key); recordIndex >= 0 {
+				records[recordIndex] = setField(records[recordIndex], e.config.BgIconField, recfile.Int32Str(icons[index]))
+			}
+		}
+		return records, e.selectedKey
+	})
+}
+
+// clearBackgroundIcons removes the background tiles of the selected entries.
+func (e *Engine) clearBackgroundIcons() bool {
+	keys := e.selectedVisibleKeys()
+	if len(keys) == 0 {
+		return false
+	}
+	e.editRecords(fmt.Sprintf("remove background of %d entries", len(keys)), func(records []recfile.Record, mapping map[string]int32) ([]recfile.Record, string) {
+		for _, key := range keys {
+			if recordIndex := e.recordIndexOfKey(records, key); recordIndex >= 0 {
+				records[recordIndex] = slices.DeleteFunc(records[recordIndex], func(field recfile.Field) bool { return field.Name == e.config.BgIconField })
+			}
+		}
+		return records, e.selectedKey
+	})
+	return true
+}
+
+// orientationOf reads the flip and rotate fields of a record. Rotations are rounded down to quarter turns.
+func (e *Engine) orientationOf(record recfile.Record) renderer.TileOrientation {
+	rotation := recfile.Field{Value: record.FindFirstFieldValue(e.config.RotateField)}.AsInt()
+	return renderer.TileOrientation{
+		FlipX:    recfile.Field{Value: record.FindFirstFieldValue(e.config.FlipXField)}.AsBool(),
+		FlipY:    recfile.Field{Value: record.FindFirstFieldValue(e.config.FlipYField)}.AsBool(),
+		Rotation: ((rotation/90)%4 + 4) % 4 * 90,
+	}
+}
+
+// setOrientationFields writes the orientation into a record, fields with default values are removed.
+func (e *Engine) setOrientationFields(record recfile.Record, orientation renderer.TileOrientation) recfile.Record {
+	values := []struct {
+		name      string
+		value     string
+		isDefault bool
+	}{
+		{e.config.FlipXField, recfile.BoolStr(orientation.FlipX), !orientation.FlipX},
+		{e.config.FlipYField, recfile.BoolStr(orientation.FlipY), !orientation.FlipY},
+		{e.config.RotateField, strconv.Itoa(orientation.Rotation), orientation.Rotation == 0},
+	}
+	for _, field := range values {
+		if field.isDefault {
+			record = slices.DeleteFunc(record, func(existing recfile.Field) bool { return existing.Name == field.name })
+		} else {
+			record = setField(record, field.name, field.value)
+		}
+	}
+	return record
+}
+
+// changeOrientation applies change to the orientation of every selected entry as one undoable command.
+func (e *Engine) changeOrientation(description string, change func(orientation renderer.TileOrientation) renderer.TileOrientation) {
+	keys := e.selectedVisibleKeys()
+	if len(keys) == 0 {
+		if _, exists := e.iconMapping[e.selectedKey]; !exists {
+			return
+		}
+		keys = []string{e.selectedKey}
+	}
+	if len(keys) > 1 {
+		description = fmt.Sprintf("%s of %d entries", description, len(keys))
+	} else {
+		description = fmt.Sprintf("%s of %s", description, keys[0])
+	}
+	e.editRecords(description, func(records []recfile.Record, mapping map[string]int32) ([]recfile.Record, string) {
+		for _, key := range keys {
+			if recordIndex := e.recordIndexOfKey(records, key); recordIndex >= 0 {
+				record := records[recordIndex]
+				records[recordIndex] = e.setOrientationFields(record, change(e.orientationOf(record)))
+			}
+		}
+		return records, e.selectedKey
+	})
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"ReMapper/geometry"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"image"
+)
+
+const (
+	scrollBarSize    = 12
+	splitterWidth    = 6
+	minPaneWidth     = 160
+	wheelSensitivity = 24.0
+)
+
+type scrollBarDrag int
+
+const (
+	dragNone scrollBarDrag = iota
+	dragVertical
+	dragHorizontal
+)
+
+// scrollPane is a rectangular part of the window that shows a part of a larger content.
+// Scroll bars are shown on the right and bottom edge when the content does not fit.
+type scrollPane struct {
+	bounds      geometry.Rect   // on screen, including the scroll bars
+	contentSize geometry.Point  // in pixels
+	scroll      geometry.PointF // offset of the visible part of the content
+	drag        scrollBarDrag
+	dragStart   geometry.Point
+	dragScroll  geometry.PointF
+}
+
+// scrollBars returns which scroll bars are needed. A vertical bar takes away width,
+// which can make a horizontal bar necessary and the other way around.
+func (p *scrollPane) scrollBars() (vertical, horizontal bool) {
+	size := p.bounds.Size()
+	horizontal = p.contentSize.X > size.X
+	if horizontal {
+		vertical = p.contentSize.Y > size.Y-scrollBarSize
+	} else {
+		vertical = p.contentSize.Y > size.Y
+		horizontal = vertical && p.contentSize.X > size.X-scrollBarSize
+	}
+	return vertical, horizontal
+}
+
+func (p *scrollPane) hasVerticalBar() bool {
+	vertical, _ := p.scrollBars()
+	return vertical
+}
+
+func (p *scrollPane) hasHorizontalBar() bool {
+	_, horizontal := p.scrollBars()
+	return horizontal
+}
+
+// viewport is the area that shows the content.
+func (p *scrollPane) viewport() geometry.Rect {
+	viewport := p.bounds
+	vertical, horizontal := p.scrollBars()
+	if vertical {
+		viewport.Max.X -= scrollBarSize
+	}
+	if horizontal {
+		viewport.Max.Y -= scrollBarSize
+	}
+	return viewport
+}
+
+func (p *scrollPane) maxScroll() geometry.PointF {
+	viewportSize := p.viewport().Size()
+	return geometry.PointF{
+		X: float64(max(0, p.contentSize.X-viewportSize.X)),
+		Y: float64(max(0, p.contentSize.Y-viewportSize.Y)),
+	}
+}
+
+func (p *scrollPane) setScroll(scroll geometry.PointF) {
+	maxScroll := p.maxScroll()
+	p.scroll = geometry.PointF{
+		X: max(0, min(scroll.X, maxScroll.X)),
+		Y: max(0, min(scroll.Y, maxScroll.Y)),
+	}
+}
+
+func (p *scrollPane) scrollBy(delta geometry.PointF) {
+	p.setScroll(geometry.PointF{X: p.scroll.X + delta.X, Y: p.scroll.Y + delta.Y})
+}
+
+// scrollToShow scrolls just enough to make the content rectangle visible.
+func (p *scrollPane) scrollToShow(contentRect geometry.Rect) {
+	viewportSize := p.viewport().Size()
+	scroll := p.scroll
+	if float64(contentRect.Min.X) < scroll.X {
+		scroll.X = float64(contentRect.Min.X)
+	} else if float64(contentRect.Max.X) > scroll.X+float64(viewportSize.X) {
+		scroll.X = float64(contentRect.Max.X - viewportSize.X)
+	}
+	if float64(contentRect.Min.Y) < scroll.Y {
+		scroll.Y = float64(contentRect.Min.Y)
+	} else if float64(contentRect.Max.Y) > scroll.Y+float64(viewportSize.Y) {
+		scroll.Y = float64(contentRect.Max.Y - viewportSize.Y)
+	}
+	p.setScroll(scroll)
+}
+
+// contentOrigin is the screen position of the top left corner of the content.
+func (p *scrollPane) contentOrigin() geometry.Point {
+	viewport := p.viewport()
+	return geometry.Point{X: viewport.Min.X - int(p.scroll.X), Y: viewport.Min.Y - int(p.scroll.Y)}
+}
+
+func (p *scrollPane) verticalThumb() geometry.Rect {
+	viewport := p.viewport()
+	track := geometry.NewRect(viewport.Max.X, viewport.Min.Y, viewport.Max.X+scrollBarSize, viewport.Max.Y)
+	return thumbRect(track, viewport.Size().Y, p.contentSize.Y, p.scroll.Y, true)
+}
+
+func (p *scrollPane) horizontalThumb() geometry.Rect {
+	viewport := p.viewport()
+	track := geometry.NewRect(viewport.Min.X, viewport.Max.Y, viewport.Max.X, viewport.Max.Y+scrollBarSize)
+	return thumbRect(track, viewport.Size().X, p.contentSize.X, p.scroll.X, false)
+}
+
+func thumbRect(track geometry.Rect, visible, content int, scroll float64, vertical bool) geometry.Rect {
+	trackLength := track.Size().X
+	if vertical {
+		trackLength = track.Size().Y
+	}
+	thumbLength := max(scrollBarSize*2, trackLength*visible/max(1, content))
+	thumbLength = min(thumbLength, trackLength)
+	thumbStart := 0
+	if content > visible {
+		thumbStart = int(scroll * float64(trackLength-thumbLength) / float64(content-visible))
+	}
+	if vertical {
+		return geometry.NewRect(track.Min.X, track.Min.Y+thumbStart, track.Max.X, track.Min.Y+thumbStart+thumbLength)
+	}
+	return geometry.NewRect(track.Min.X+thumbStart, track.Min.Y, track.Min.X+thumbStart+thumbLength, track.Max.Y)
+}
+
+// handleMouse handles the wheel and dragging of the scroll bars. It returns true if the input was used.
+func (p *scrollPane) handleMouse(mousePos geometry.Point) bool {
+	if p.drag != dragNone {
+		if !ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
+			p.drag = dragNone
+			return true
+		}
+		viewportSize := p.viewport().Size()
+		if p.drag == dragVertical {
+			thumb := p.verticalThumb()
+			ratio := float64(p.contentSize.Y-viewportSize.Y) / float64(max(1, viewportSize.Y-thumb.Size().Y))
+			p.setScroll(geometry.PointF{X: p.scroll.X, Y: p.dragScroll.Y + float64(mousePos.Y-p.dragStart.Y)*ratio})
+		} else {
+			thumb := p.horizontalThumb()
+			ratio := float64(p.contentSize.X-viewportSize.X) / float64(max(1, viewportSize.X-thumb.Size().X))
+			p.setScroll(geometry.PointF{X: p.dragScroll.X + float64(mousePos.X-p.dragStart.X)*ratio, Y: p.scroll.Y})
+		}
+		return true
+	}
+	if !p.bounds.Contains(mousePos) {
+		return false
+	}
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		if p.hasVerticalBar() && p.verticalThumb().Contains(mousePos) {
+			p.startDrag(dragVertical, mousePos)
+			return true
+		}
+		if p.hasHorizontalBar() && p.horizontalThumb().Contains(mousePos) {
+			p.startDrag(dragHorizontal, mousePos)
+			return true
+		}
+		if !p.viewport().Contains(mousePos) {
+			return true // a click into the track
+		}
+	}
+	if isControlPressed() {
+		return false // reserved for zooming
+	}
+	dx, dy := ebiten.Wheel()
+	if dx == 0 && dy == 0 {
+		return false
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyShift) {
+		dx, dy = dy, dx
+	}
+	p.scrollBy(geometry.PointF{X: -dx * wheelSensitivity, Y: -dy * wheelSensitivity})
+	return true
+}
+
+func (p *scrollPane) startDrag(drag scrollBarDrag, mousePos geometry.Point) {
+	p.drag = drag
+	p.dragStart = mousePos
+	p.dragScroll = p.scroll
+}
+
+func (e *Engine) drawScrollBars(p *scrollPane) {
+	viewport := p.viewport()
+	trackColor := e.theme.Field
+	thumbColor := e.theme.DisabledText
+	if p.hasVerticalBar() {
+		e.renderer.DrawFilledRect(geometry.Point{X: viewport.Max.X, Y: viewport.Min.Y}, geometry.Point{X: scrollBarSize, Y: viewport.Size().Y}, trackColor)
+		thumb := p.verticalThumb()
+		e.renderer.DrawFilledRect(thumb.Min.Shift(2, 0), thumb.Size().Shift(-4, 0), thumbColor)
+	}
+	if p.hasHorizontalBar() {
+		e.renderer.DrawFilledRect(geometry.Point{X: viewport.Min.X, Y: viewport.Max.Y}, geometry.Point{X: viewport.Size().X, Y: scrollBarSize}, trackColor)
+		thumb := p.horizontalThumb()
+		e.renderer.DrawFilledRect(thumb.Min.Shift(0, 2), thumb.Size().Shift(0, -4), thumbColor)
+	}
+}
+
+// clipTo returns a render target that only allows drawing inside of rect.
+// The coordinates stay the same as on the screen.
+func (e *Engine) clipTo(screen *ebiten.Image, rect geometry.Rect) *ebiten.Image {
+	scale := e.deviceDPIScale
+	clipRect := image.Rect(
+		int(float64(rect.Min.X)*scale), int(float64(rect.Min.Y)*scale),
+		int(float64(rect.Max.X)*scale), int(float64(rect.Max.Y)*scale),
+	)
+	return screen.SubImage(clipRect).(*ebiten.Image)
+}
+
+func (e *Engine) splitterRect() geometry.Rect {
+	return geometry.NewRect(e.splitterX, 0, e.splitterX+splitterWidth, e.deviceIndependentScreenSize.Y)
+}
+
+// handleSplitter lets the user drag the border between the list and the atlas.
+func (e *Engine) handleSplitter() bool {
+	if e.isDraggingSplitter {
+		if !ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
+			e.isDraggingSplitter = false
+			return true
+		}
+		e.splitterX = e.mousePosInPixels.X - splitterWidth/2
+		e.isSplitterMoved = true
+		e.updateElementBounds()
+		return true
+	}
+	isOverSplitter := e.splitterRect().Shift(-2, 0, 2, 0).Contains(e.mousePosInPixels)
+	if isOverSplitter {
+		e.setCursorShape(ebiten.CursorShapeEWResize)
+	} else {
+		e.setCursorShape(ebiten.CursorShapeDefault)
+	}
+	if isOverSplitter && inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		e.isDraggingSplitter = true
+		return true
+	}
+	return false
+}
+
+func (e *Engine) handlePaneMouse() bool {
+	if e.handleAtlasZoomAndPan() {
+		return true
+	}
+	if e.handleSplitter() {
+		return true
+	}
+	if e.listPane.handleMouse(e.mousePosInPixels) || e.atlasPane.handleMouse(e.mousePosInPixels) {
+		e.updateElementBounds()
+		return true
+	}
+	return false
+}
+
+// layoutPanes places the list pane, the splitter, the atlas pane and the inspector inside of the window.
+func (e *Engine) layoutPanes(listContentSize geometry.Point) {
+	screenSize := e.deviceIndependentScreenSize
+	atlasRight := screenSize.X
+	if e.showInspector {
+		atlasRight -= inspectorWidth
+		e.inspectorPane.bounds = geometry.NewRect(atlasRight, 0, screenSize.X, screenSize.Y)
+		e.inspectorPane.setScroll(e.inspectorPane.scroll)
+	}
+	if e.grid.isActive {
+		// the grid takes the place of the list and the atlas
+		e.listWidth = float64(atlasRight)
+		searchBoxPos, searchBoxSize := e.searchBoxRect()
+		e.listTop = float64(searchBoxPos.Y+searchBoxSize.Y) + e.padding
+		e.gridPane.bounds = geometry.NewRect(0, int(e.listTop), atlasRight, screenSize.Y)
+		e.gridPane.contentSize = e.gridContentSize()
+		e.gridPane.setScroll(e.gridPane.scroll)
+		return
+	}
+	if !e.isSplitterMoved {
+		// follow the width of the entries until the user moves the splitter
+		e.splitterX = min(listContentSize.X+scrollBarSize, atlasRight*3/5)
+	}
+	e.splitterX = clamp(e.splitterX, minPaneWidth, max(minPaneWidth, atlasRight-minPaneWidth-splitterWidth))
+	e.listWidth = float64(e.splitterX)
+
+	searchBoxPos, searchBoxSize := e.searchBoxRect()
+	e.listTop = float64(searchBoxPos.Y+searchBoxSize.Y) + e.padding
+
+	e.listPane.bounds = geometry.NewRect(0, int(e.listTop), e.splitterX, screenSize.Y)
+	e.listPane.contentSize = listContentSize
+	e.listPane.setScroll(e.listPane.scroll)
+
+	atlasSize := e.tileAtlas.GetAtlasSize().MulF(e.atlasScale)
+	atlasBottom := screenSize.Y
+	if e.preview.isOpen {
+		// the map preview is below the atlas
+		atlasBottom -= e.previewHeight()
+		e.layoutMapPreview(geometry.NewRect(e.splitterX+splitterWidth, atlasBottom+splitterWidth, atlasRight, screenSize.Y))
+	}
+	e.atlasPane.bounds = geometry.NewRect(e.splitterX+splitterWidth, 0, atlasRight, atlasBottom)
+	e.atlasPane.contentSize = atlasSize.Add(geometry.Point{X: int(e.padding * 2), Y: int(e.padding * 2)})
+	e.atlasPane.setScroll(e.atlasPane.scroll)
+
+	atlasOrigin := e.atlasPane.contentOrigin().Add(geometry.Point{X: int(e.padding), Y: int(e.padding)})
+	e.atlasBounds = geometry.Rect{Min: atlasOrigin, Max: atlasOrigin.Add(atlasSize)}
+}
+
+// isOverAtlas is true if the position is on the visible part of the atlas.
+func (e *Engine) isOverAtlas(screenPos geometry.Point) bool {
+	return !e.grid.isActive && e.atlasBounds.Contains(screenPos) && e.atlasPane.viewport().Contains(screenPos)
+}
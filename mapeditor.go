@@ -0,0 +1,400 @@
+package main
+
+import (
+	"ReMapper/geometry"
+	"fmt"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"path/filepath"
+	"strings"
+)
+
+const maxMapSize = 1000
+
+var defaultMapSize = geometry.Point{X: 40, Y: 25}
+
+type mapTool int
+
+const (
+	toolBrush mapTool = iota
+	toolRectangle
+	toolLine
+	toolFill
+	toolCount
+)
+
+func (t mapTool) String() string {
+	switch t {
+	case toolRectangle:
+		return "Rectangle"
+	case toolLine:
+		return "Line"
+	case toolFill:
+		return "Fill"
+	}
+	return "Brush"
+}
+
+// mapEditor paints the selected entry into the active layer of the preview map.
+// A stroke is applied to the map while the mouse button is down and becomes one undoable command.
+type mapEditor struct {
+	isActive     bool
+	tool         mapTool
+	layer        int
+	isPainting   bool
+	strokeKey    string
+	strokeStart  geometry.Point
+	lastCell     geometry.Point
+	strokeBefore tileMapState
+	savedState   tileMapState // the map as it was loaded or last saved
+	isDirty      bool
+}
+
+// mapEditCommand replaces the cells of a map, strokes, resizing and new layers all use it.
+type mapEditCommand struct {
+	description   string
+	tiles         *tileMap
+	before, after tileMapState
+}
+
+func (c *mapEditCommand) Do(e *Engine) {
+	e.applyMapState(c.tiles, c.after)
+}
+
+func (c *mapEditCommand) Undo(e *Engine) {
+	e.applyMapState(c.tiles, c.before)
+}
+
+func (c *mapEditCommand) Description() string {
+	return c.description
+}
+
+func (e *Engine) applyMapState(tiles *tileMap, state tileMapState) {
+	isResized := tiles.size != state.size
+	tiles.setState(state)
+	e.preview.editor.isDirty = !state.equals(e.preview.editor.savedState)
+	e.preview.editor.layer = min(e.preview.editor.layer, len(tiles.layers)-1)
+	if isResized {
+		e.preview.playtest = playtest{}
+		e.createMapWindow()
+		e.preview.mapWindow.CenterOn(tiles.size.Div(2))
+	}
+}
+
+// editMap runs change on the preview map as one undoable command.
+func (e *Engine) editMap(description string, change func(tiles *tileMap)) {
+	tiles := e.preview.tiles
+	before := tiles.state()
+	change(tiles)
+	after := tiles.state()
+	if after.equals(before) {
+		return
+	}
+	e.execute(&mapEditCommand{description: description, tiles: tiles, before: before, after: after})
+}
+
+// toggleMapEditor switches the preview between showing and painting. Without a map, an empty one is created.
+func (e *Engine) toggleMapEditor() {
+	p := &e.preview
+	if p.editor.isActive {
+		p.editor.isActive = false
+		return
+	}
+	if p.tiles == nil {
+		e.setPreviewMap(newEmptyTileMap(defaultMapSize, "ground"))
+	}
+	p.playtest = playtest{}
+	p.editor.isActive = true
+	p.isOpen = true
+	e.updateElementBounds()
+}
+
+// mapEditorButtons are shown in the header of the preview while editing.
+func (e *Engine) mapEditorButtons() []toolbarButton {
+	p := &e.preview
+	var toolButtons []toolbarButton
+	for tool := mapTool(0); tool < toolCount; tool++ {
+		tool := tool
+		toolButtons = append(toolButtons, toolbarButton{label: tool.String(), isActive: p.editor.tool == tool, action: func() { p.editor.tool = tool }})
+	}
+	var layerButtons []toolbarButton
+	for layer := range p.tiles.layers {
+		layer := layer
+		layerButtons = append(layerButtons, toolbarButton{label: p.tiles.layers[layer].name, isActive: p.editor.layer == layer, action: func() { p.editor.layer = layer }})
+	}
+	layerButtons = append(layerButtons, toolbarButton{label: "+ Layer", action: e.openAddLayerPrompt})
+	fileButtons := []toolbarButton{
+		{label: "Size", action: e.openMapSizePrompt},
+		{label: "Save", action: func() { e.saveMap(nil) }},
+	}
+	origin := p.bounds.Min.Add(geometry.Point{X: int(e.padding), Y: int(e.padding / 2)})
+	return e.layoutButtonGroups([][]toolbarButton{toolButtons, layerButtons, fileButtons}, origin)
+}
+
+// handleMapEditorMouse paints with the left mouse button, Shift erases. The right mouse button
+// selects the entry of a cell in the list.
+func (e *Engine) handleMapEditorMouse() bool {
+	p := &e.preview
+	editor := &p.editor
+	if !editor.isActive {
+		return false
+	}
+	if editor.isPainting {
+		if !ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
+			e.finishStroke()
+			return true
+		}
+		cell := p.mapWindow.GetMapCellAtScreenPos(e.mousePosInPixels.Sub(e.previewArea().Min))
+		cell = geometry.Point{X: clamp(cell.X, 0, p.tiles.size.X-1), Y: clamp(cell.Y, 0, p.tiles.size.Y-1)}
+		e.continueStroke(cell)
+		return true
+	}
+	if !p.bounds.Contains(e.mousePosInPixels) {
+		return false
+	}
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		for _, button := range e.mapEditorButtons() {
+			if button.bounds.Contains(e.mousePosInPixels) {
+				button.action()
+				return true
+			}
+		}
+	}
+	cell, isOnMap := e.previewCellAt(e.mousePosInPixels)
+	if !isOnMap {
+		return false
+	}
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonRight) {
+		key := p.tiles.keyAt(editor.layer, cell)
+		if key == "" {
+			key = p.tiles.topKeyAt(cell)
+		}
+		if _, exists := e.keyRecords[key]; exists {
+			e.jumpToKey(key)
+		}
+		return true
+	}
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		e.startStroke(cell)
+		return true
+	}
+	return false
+}
+
+func (e *Engine) startStroke(cell geometry.Point) {
+	p := &e.preview
+	editor := &p.editor
+	key := e.selectedKey
+	if ebiten.IsKeyPressed(ebiten.KeyShift) {
+		key = ""
+	} else if key == "" {
+		return // nothing to paint with
+	}
+	editor.isPainting = true
+	editor.strokeKey = key
+	editor.strokeStart = cell
+	editor.lastCell = cell
+	editor.strokeBefore = p.tiles.state()
+	switch editor.tool {
+	case toolBrush:
+		p.tiles.setKey(editor.layer, cell, key)
+	case toolFill:
+		e.floodFill(cell, key)
+		e.finishStroke()
+	default:
+		e.drawShape(cell)
+	}
+}
+
+// continueStroke follows the mouse. The brush paints a line from the last cell, so fast
+// movements leave no gaps. Rectangles and lines are drawn again from the map before the stroke.
+func (e *Engine) continueStroke(cell geometry.Point) {
+	p := &e.preview
+	editor := &p.editor
+	if cell == editor.lastCell {
+		return
+	}
+	if editor.tool == toolBrush {
+		for _, linePos := range geometry.BresenhamLine(editor.lastCell, cell, func(x, y int) bool { return true }) {
+			p.tiles.setKey(editor.layer, linePos, editor.strokeKey)
+		}
+	} else {
+		p.tiles.setState(editor.strokeBefore)
+		e.drawShape(cell)
+	}
+	editor.lastCell = cell
+}
+
+func (e *Engine) drawShape(end geometry.Point) {
+	p := &e.preview
+	editor := &p.editor
+	start := editor.strokeStart
+	if editor.tool == toolLine {
+		for _, linePos := range geometry.BresenhamLine(start, end, func(x, y int) bool { return true }) {
+			p.tiles.setKey(editor.layer, linePos, editor.strokeKey)
+		}
+		return
+	}
+	for y := min(start.Y, end.Y); y <= max(start.Y, end.Y); y++ {
+		for x := min(start.X, end.X); x <= max(start.X, end.X); x++ {
+			p.tiles.setKey(editor.layer, geometry.Point{X: x, Y: y}, editor.strokeKey)
+		}
+	}
+}
+
+// floodFill replaces the connected cells with the same key as the start cell, without diagonals.
+func (e *Engine) floodFill(start geometry.Point, key string) {
+	p := &e.preview
+	layer := p.editor.layer
+	target := p.tiles.keyAt(layer, start)
+	if target == key {
+		return
+	}
+	var neighbors geometry.Neighbors
+	isTarget := func(pos geometry.Point) bool {
+		return p.tiles.contains(pos) && p.tiles.keyAt(layer, pos) == target
+	}
+	open := []geometry.Point{start}
+	p.tiles.setKey(layer, start, key)
+	for len(open) > 0 {
+		pos := open[len(open)-1]
+		open = open[:len(open)-1]
+		for _, next := range neighbors.Cardinal(pos, isTarget) {
+			p.tiles.setKey(layer, next, key)
+			open = append(open, next)
+		}
+	}
+}
+
+// finishStroke records the stroke in the history.
+func (e *Engine) finishStroke() {
+	p := &e.preview
+	editor := &p.editor
+	editor.isPainting = false
+	after := p.tiles.state()
+	if after.equals(editor.strokeBefore) {
+		return
+	}
+	what := editor.strokeKey
+	if what == "" {
+		what = "erase"
+	}
+	description := fmt.Sprintf("map %s: %s on %s", strings.ToLower(editor.tool.String()), what, p.tiles.layers[editor.layer].name)
+	e.execute(&mapEditCommand{description: description, tiles: p.tiles, before: editor.strokeBefore, after: after})
+}
+
+func (e *Engine) openMapSizePrompt() {
+	tiles := e.preview.tiles
+	e.openTextPrompt("Map size (width x height)", fmt.Sprintf("%dx%d", tiles.size.X, tiles.size.Y), func(text string) error {
+		var size geometry.Point
+		if _, err := fmt.Sscanf(strings.ReplaceAll(strings.ToLower(text), " ", ""), "%dx%d", &size.X, &size.Y); err != nil {
+			return fmt.Errorf("expected a size like 40x25")
+		}
+		if size.X < 1 || size.Y < 1 || size.X > maxMapSize || size.Y > maxMapSize {
+			return fmt.Errorf("the size must be between 1 and %d", maxMapSize)
+		}
+		e.editMap(fmt.Sprintf("map size %dx%d", size.X, size.Y), func(tiles *tileMap) {
+			tiles.setState(tiles.resized(size))
+		})
+		return nil
+	})
+}
+
+func (e *Engine) openAddLayerPrompt() {
+	e.openTextPrompt("New map layer", fmt.Sprintf("layer %d", len(e.preview.tiles.layers)+1), func(name string) error {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return fmt.Errorf("the layer needs a name")
+		}
+		e.editMap(fmt.Sprintf("add map layer %s", name), func(tiles *tileMap) {
+			tiles.layers = append(tiles.layers, tileMapLayer{name: name, cells: make([]string, tiles.size.X*tiles.size.Y)})
+		})
+		e.preview.editor.layer = len(e.preview.tiles.layers) - 1
+		return nil
+	})
+}
+
+// saveMap writes the preview map and calls onSaved afterwards. A map without a file name, or
+// with several layers in a text file, asks for a file name first.
+func (e *Engine) saveMap(onSaved func()) {
+	tiles := e.preview.tiles
+	write := func(fileName string) error {
+		if err := writeTileMap(fileName, tiles); err != nil {
+			return err
+		}
+		tiles.fileName = fileName
+		if tiles == e.preview.tiles {
+			e.preview.editor.savedState = tiles.state()
+			e.preview.editor.isDirty = false
+		}
+		e.saveTicks = 30
+		if onSaved != nil {
+			onSaved()
+		}
+		return nil
+	}
+	if tiles.fileName == "" || (!isRecMapFile(tiles.fileName) && len(tiles.layers) > 1) {
+		suggestion := strings.TrimSuffix(tiles.fileName, ".txt") + ".rec"
+		if tiles.fileName == "" {
+			suggestion = "map.rec"
+		}
+		e.openTextPrompt("Save map as", suggestion, write)
+		return
+	}
+	if err := write(tiles.fileName); err != nil {
+		e.openDialog(&dialog{
+			Title:   "Could not save the map",
+			Lines:   []string{err.Error()},
+			Buttons: []dialogButton{{Label: "OK (Enter)", Key: ebiten.KeyEnter, Action: func() {}}},
+		})
+	}
+}
+
+// askToSaveMap offers to save the edited map before next runs, e.g. before quitting.
+func (e *Engine) askToSaveMap(next func()) {
+	fileName := e.preview.tiles.fileName
+	if fileName == "" {
+		fileName = "the new map"
+	}
+	e.openDialog(&dialog{
+		Title: "Unsaved map",
+		Lines: []string{fmt.Sprintf("Save the changes to %s?", filepath.Base(fileName))},
+		Buttons: []dialogButton{
+			{Label: "Save (S)", Key: ebiten.KeyS, Action: func() { e.saveMap(next) }},
+			{Label: "Discard (D)", Key: ebiten.KeyD, Action: func() {
+				e.preview.editor.isDirty = false
+				next()
+			}},
+			{Label: "Cancel (Esc)", Key: ebiten.KeyEscape, Action: func() {}},
+		},
+	})
+}
+
+// handleMapEditorKeys moves the camera while the mouse is over the map: the arrows scroll
+// by a cell and Space centers on the cell under the mouse.
+func (e *Engine) handleMapEditorKeys() bool {
+	p := &e.preview
+	if !p.editor.isActive || !p.isOpen || !p.bounds.Contains(e.mousePosInPixels) {
+		return false
+	}
+	cellSize := p.mapRenderer.GetScaledTileSize().ToPoint()
+	scrollKeys := map[ebiten.Key]geometry.Point{
+		ebiten.KeyArrowLeft:  {X: -cellSize.X},
+		ebiten.KeyArrowRight: {X: cellSize.X},
+		ebiten.KeyArrowUp:    {Y: -cellSize.Y},
+		ebiten.KeyArrowDown:  {Y: cellSize.Y},
+	}
+	for key, delta := range scrollKeys {
+		if isKeyRepeated(key) {
+			p.mapWindow.ScrollBy(delta)
+			return true
+		}
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeySpace) {
+		if cell, isOnMap := e.previewCellAt(e.mousePosInPixels); isOnMap {
+			p.mapWindow.CenterOn(cell)
+		}
+		return true
+	}
+	return false
+}
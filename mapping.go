@@ -0,0 +1,268 @@
+package main
+
+import (
+	"ReMapper/autotile"
+	"ReMapper/geometry"
+	"ReMapper/recfile"
+	"bufio"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+func buildCurrentMapping(mappingRecFile string, config MappingConfig) ([]recfile.Record, map[string]int32, error) {
+	mapping := make(map[string]int32)
+	file, err := os.Open(mappingRecFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	records := recfile.Read(file)
+	file.Close()
+
+	for _, rec := range records {
+		var icon int32
+		var internalName string
+		for _, field := range rec {
+			if field.Name == config.IconField {
+				icon = field.AsInt32()
+			} else if field.Name == config.KeyField {
+				internalName = field.Value
+			}
+		}
+		if internalName == "" {
+			continue
+		}
+		mapping[internalName] = icon
+	}
+	return records, mapping, nil
+}
+
+// applyIconMapping writes the icons of mapping into the icon fields of records.
+// Records without an icon field get one appended.
+func applyIconMapping(records []recfile.Record, config MappingConfig, mapping map[string]int32) {
+	for recIndex, rec := range records {
+		internalName := rec.FindFirstFieldValue(config.KeyField)
+		changedIcon, isMapped := mapping[internalName]
+		if internalName == "" || !isMapped {
+			continue
+		}
+		hasIconField := false
+		for fieldIndex, field := range rec {
+			if field.Name == config.IconField {
+				field.Value = strconv.Itoa(int(changedIcon))
+				records[recIndex][fieldIndex] = field
+				hasIconField = true
+			}
+		}
+		if !hasIconField {
+			records[recIndex] = append(rec, recfile.Field{Name: config.IconField, Value: strconv.Itoa(int(changedIcon))})
+		}
+	}
+}
+
+// recSection is a %rec section of a mapping file. Only the default section is edited,
+// the others are kept as the lines they were read from and written back unchanged.
+type recSection struct {
+	recordType string
+	lines      []string
+}
+
+const defaultRecordType = "default"
+
+var recordTypeLinePattern = regexp.MustCompile(`^%rec:\s*([a-zA-Z][a-zA-Z0-9_]*)`)
+
+// readRecSections splits a mapping file into its %rec sections, in the order of the file.
+// The default section holds no lines, it stands for the place of the mapping records.
+func readRecSections(reader io.Reader) ([]recSection, error) {
+	var sections []recSection
+	hasDefault := false
+	addDefault := func() {
+		if !hasDefault {
+			sections = append(sections, recSection{recordType: defaultRecordType})
+			hasDefault = true
+		}
+	}
+	current := -1 // index of the section of the line, -1 in the default section
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if matches := recordTypeLinePattern.FindStringSubmatch(line); matches != nil {
+			if matches[1] == defaultRecordType {
+				addDefault()
+				current = -1
+				continue
+			}
+			sections = append(sections, recSection{recordType: matches[1]})
+			current = len(sections) - 1
+		}
+		if current < 0 {
+			if strings.TrimSpace(line) != "" {
+				addDefault()
+			}
+			continue
+		}
+		sections[current].lines = append(sections[current].lines, line)
+	}
+	if !hasDefault {
+		sections = append([]recSection{{recordType: defaultRecordType}}, sections...)
+	}
+	return sections, scanner.Err()
+}
+
+// loadRecSections reads the sections of a mapping file, a file that doesn't exist has only the default one.
+func loadRecSections(fileName string) ([]recSection, error) {
+	file, err := os.Open(fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return []recSection{{recordType: defaultRecordType}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return readRecSections(file)
+}
+
+func writeRecFile(fileName string, records []recfile.Record) error {
+	return writeMappingFile(fileName, records, nil)
+}
+
+// writeMappingFile writes the records as the default section, between the other sections
+// at the place the default section had when they were read.
+func writeMappingFile(fileName string, records []recfile.Record, sections []recSection) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	writeErr := writeRecSections(file, records, sections)
+	closeErr := file.Close()
+	if writeErr != nil {
+		return writeErr
+	}
+	return closeErr
+}
+
+func writeRecSections(file io.StringWriter, records []recfile.Record, sections []recSection) error {
+	if len(sections) == 0 {
+		return recfile.Write(file, records)
+	}
+	for _, section := range sections {
+		if section.recordType == defaultRecordType {
+			if err := recfile.Write(file, records); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, line := range section.lines {
+			if _, err := file.WriteString(line + "\n"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func sortedKeys[V any](mapping map[string]V) []string {
+	var orderedKeys []string
+	for k := range mapping {
+		orderedKeys = append(orderedKeys, k)
+	}
+	slices.SortStableFunc(orderedKeys, func(i, j string) int {
+		return cmp.Compare(i, j)
+	})
+	return orderedKeys
+}
+
+// MappingReport collects the problems and statistics of a mapping rec file.
+type MappingReport struct {
+	RecordCount      int
+	MissingKey       []int // record indices
+	DuplicateKeys    []string
+	MissingIcon      []string
+	InvalidIcon      []string
+	OutOfRange       []string
+	AutotileProblems map[string]string // invalid auto-tile tables by key
+	UsersOfIcon      map[int32][]string
+	UnusedCellCount  int
+}
+
+func (r MappingReport) ProblemCount() int {
+	return len(r.MissingKey) + len(r.DuplicateKeys) + len(r.InvalidIcon) + len(r.OutOfRange) + len(r.AutotileProblems)
+}
+
+// analyzeMapping checks every record against the config, descriptors are skipped.
+// Cell counts of zero skip the range checks.
+func analyzeMapping(records []recfile.Record, config MappingConfig, cellCount geometry.Point) MappingReport {
+	report := MappingReport{
+		UsersOfIcon: make(map[int32][]string),
+	}
+	maxIndex := int64(cellCount.X * cellCount.Y)
+	seenKeys := make(map[string]bool)
+	for recIndex, rec := range records {
+		if isDescriptor(rec) {
+			continue
+		}
+		report.RecordCount++
+		key := rec.FindFirstFieldValue(config.KeyField)
+		if key == "" {
+			report.MissingKey = append(report.MissingKey, recIndex)
+			continue
+		}
+		if seenKeys[key] {
+			report.DuplicateKeys = append(report.DuplicateKeys, key)
+			continue
+		}
+		seenKeys[key] = true
+
+		iconValue, hasIcon := findField(rec, config.IconField)
+		if !hasIcon {
+			report.MissingIcon = append(report.MissingIcon, key)
+			continue
+		}
+		icon, err := strconv.ParseInt(iconValue, 10, 32)
+		if err != nil {
+			report.InvalidIcon = append(report.InvalidIcon, key)
+			continue
+		}
+		if icon < 0 || (maxIndex > 0 && icon >= maxIndex) {
+			report.OutOfRange = append(report.OutOfRange, key)
+			continue
+		}
+		report.UsersOfIcon[int32(icon)] = append(report.UsersOfIcon[int32(icon)], key)
+	}
+	_, autotileErr := autotile.FromRecords(records, config.AutotileFields())
+	report.AutotileProblems = autotileProblemsOf(autotileErr)
+	if maxIndex > 0 {
+		report.UnusedCellCount = int(maxIndex) - len(report.UsersOfIcon)
+	}
+	return report
+}
+
+func findField(record recfile.Record, fieldName string) (string, bool) {
+	for _, field := range record {
+		if field.Name == fieldName {
+			return field.Value, true
+		}
+	}
+	return "", false
+}
+
+func (r MappingReport) sharedIcons() []int32 {
+	var shared []int32
+	for icon, users := range r.UsersOfIcon {
+		if len(users) > 1 {
+			shared = append(shared, icon)
+		}
+	}
+	slices.Sort(shared)
+	return shared
+}
+
+func describeRecord(recIndex int) string {
+	return fmt.Sprintf("record #%d", recIndex+1)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"ReMapper/geometry"
+	"ReMapper/recfile"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestAnalyzeMapping(t *testing.T) {
+	tests := []struct {
+		name            string
+		rec             string
+		wantRecords     int
+		wantMissingKey  []int
+		wantDuplicates  []string
+		wantMissingIcon []string
+		wantInvalid     []string
+		wantOutOfRange  []string
+	}{
+		{
+			name:        "clean",
+			rec:         "internal_name: wall\nicon: 1\n\ninternal_name: floor\nicon: 2\n",
+			wantRecords: 2,
+		},
+		{
+			name:        "descriptor is no record",
+			rec:         "%rec: default\n%type: icon int\n\ninternal_name: wall\nicon: 1\n",
+			wantRecords: 1,
+		},
+		{
+			name:            "problems after a descriptor",
+			rec:             "%type: icon int\n\nicon: 1\n\ninternal_name: wall\nicon: 1\n\ninternal_name: wall\nicon: 2\n\ninternal_name: door\n\ninternal_name: lava\nicon: hot\n\ninternal_name: water\nicon: 99\n",
+			wantRecords:     6,
+			wantMissingKey:  []int{1},
+			wantDuplicates:  []string{"wall"},
+			wantMissingIcon: []string{"door"},
+			wantInvalid:     []string{"lava"},
+			wantOutOfRange:  []string{"water"},
+		},
+	}
+	for _, test := range tests {
+		records := recfile.Read(strings.NewReader(test.rec))
+		report := analyzeMapping(records, DefaultMappingConfig(), geometry.Point{X: 4, Y: 4})
+		if report.RecordCount != test.wantRecords {
+			t.Errorf("%s: %d records, want %d", test.name, report.RecordCount, test.wantRecords)
+		}
+		if !slices.Equal(report.MissingKey, test.wantMissingKey) {
+			t.Errorf("%s: missing keys in %v, want %v", test.name, report.MissingKey, test.wantMissingKey)
+		}
+		if !slices.Equal(report.DuplicateKeys, test.wantDuplicates) {
+			t.Errorf("%s: duplicates %v, want %v", test.name, report.DuplicateKeys, test.wantDuplicates)
+		}
+		if !slices.Equal(report.MissingIcon, test.wantMissingIcon) {
+			t.Errorf("%s: missing icons %v, want %v", test.name, report.MissingIcon, test.wantMissingIcon)
+		}
+		if !slices.Equal(report.InvalidIcon, test.wantInvalid) {
+			t.Errorf("%s: invalid icons %v, want %v", test.name, report.InvalidIcon, test.wantInvalid)
+		}
+		if !slices.Equal(report.OutOfRange, test.wantOutOfRange) {
+			t.Errorf("%s: out of range %v, want %v", test.name, report.OutOfRange, test.wantOutOfRange)
+		}
+	}
+}
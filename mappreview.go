@@ -0,0 +1,331 @@
+package main
+
+import (
+	"ReMapper/geometry"
+	"ReMapper/renderer"
+	"fmt"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"path/filepath"
+)
+
+const (
+	minPreviewScale     = 1
+	maxPreviewScale     = 8
+	defaultPreviewScale = 2
+)
+
+// mapPreview shows a sample map below the atlas, drawn with the icons of the current mapping.
+type mapPreview struct {
+	isOpen      bool
+	tiles       *tileMap
+	mapWindow   *renderer.MapWindow
+	mapRenderer *renderer.MapRenderer
+	target      *ebiten.Image // the map is drawn here first, MapRenderer starts at the top left corner
+	bounds      geometry.Rect
+	scale       float64
+	isPanning   bool
+	panStart    geometry.Point
+	playtest    playtest
+	editor      mapEditor
+}
+
+// OpenMapPreview loads a sample map and shows it below the atlas.
+func (e *Engine) OpenMapPreview(fileName string) error {
+	tiles, err := loadTileMap(fileName)
+	if err != nil {
+		return err
+	}
+	e.setPreviewMap(tiles)
+	e.preview.isOpen = true
+	e.updateElementBounds()
+	return nil
+}
+
+func (e *Engine) setPreviewMap(tiles *tileMap) {
+	p := &e.preview
+	if p.scale == 0 {
+		p.scale = defaultPreviewScale
+	}
+	p.tiles = tiles
+	// the strokes on the previous map can't be undone anymore, Ctrl+Z goes on with the mapping
+	e.history.Remove(func(command EditCommand) bool {
+		_, isMapEdit := command.(*mapEditCommand)
+		return isMapEdit
+	})
+	p.playtest = playtest{}
+	p.editor.layer = 0
+	p.editor.savedState = tiles.state()
+	p.editor.isDirty = false
+	e.createMapWindow()
+}
+
+// createMapWindow sets up the map window and the renderer for the size of the map.
+func (e *Engine) createMapWindow() {
+	p := &e.preview
+	p.mapWindow = renderer.NewMapWindow(e.previewArea().Size(), p.tiles.size, e.tileAtlas.GetTileSize(), e.getPreviewScale, e.previewCellLayers)
+	p.mapRenderer = renderer.NewMapRenderer(e.renderer, p.mapWindow)
+}
+
+func (e *Engine) getPreviewScale() float64 {
+	return e.preview.scale
+}
+
+// previewCellLayers looks up the entries of a map cell in the mapping every frame,
+// so changed assignments show up immediately. Unmapped keys are left out.
+func (e *Engine) previewCellLayers(x, y int, tick uint64) []renderer.CellDrawInfo {
+	tiles := e.preview.tiles
+	pos := geometry.Point{X: x, Y: y}
+	var infos []renderer.CellDrawInfo
+	for layer := range tiles.layers {
+		key := tiles.keyAt(layer, pos)
+		if _, isMapped := e.iconMapping[key]; !isMapped {
+			continue
+		}
+		keyAt := func(neighbor geometry.Point) string { return tiles.keyAt(layer, neighbor) }
+		infos = append(infos, e.autotiledLayers(key, e.layersOf(key), pos, keyAt).drawInfos(e.tileAtlas)...)
+	}
+	if e.preview.playtest.isActive {
+		return e.lightCell(pos, infos)
+	}
+	return infos
+}
+
+// toggleMapPreview shows or hides the preview, the first time it asks for a map file.
+func (e *Engine) toggleMapPreview() {
+	if e.preview.tiles == nil {
+		e.openMapPrompt()
+		return
+	}
+	e.preview.isOpen = !e.preview.isOpen
+	e.updateElementBounds()
+}
+
+func (e *Engine) openMapPrompt() {
+	if e.preview.editor.isDirty {
+		e.askToSaveMap(e.openMapPrompt)
+		return
+	}
+	fileName := ""
+	if e.preview.tiles != nil {
+		fileName = e.preview.tiles.fileName
+	}
+	e.openTextPrompt("Open map", fileName, e.OpenMapPreview)
+}
+
+// previewHeight is the height of the preview pane below the atlas.
+func (e *Engine) previewHeight() int {
+	if !e.preview.isOpen {
+		return 0
+	}
+	return e.deviceIndependentScreenSize.Y * 2 / 5
+}
+
+// layoutMapPreview places the preview pane and fits the map window into it.
+func (e *Engine) layoutMapPreview(bounds geometry.Rect) {
+	p := &e.preview
+	p.bounds = bounds
+	if p.mapWindow == nil {
+		return
+	}
+	p.mapWindow.OnScreenSizeChanged(e.previewArea().Size())
+	p.mapWindow.ScrollBy(geometry.Point{}) // stay inside of the map
+}
+
+// previewHeaderHeight is the height of the line above the map.
+func (e *Engine) previewHeaderHeight() int {
+	_, textHeight := e.renderer.MeasureString("Ag")
+	return int(textHeight + e.padding)
+}
+
+// previewArea is the part of the preview pane that shows the map.
+func (e *Engine) previewArea() geometry.Rect {
+	area := e.preview.bounds
+	area.Min.Y = min(area.Max.Y, area.Min.Y+e.previewHeaderHeight())
+	return area
+}
+
+// previewCellAt returns the map cell under a screen position.
+func (e *Engine) previewCellAt(screenPos geometry.Point) (geometry.Point, bool) {
+	p := &e.preview
+	area := e.previewArea()
+	if !p.isOpen || p.mapWindow == nil || !area.Contains(screenPos) {
+		return geometry.Point{}, false
+	}
+	cell := p.mapWindow.GetMapCellAtScreenPos(screenPos.Sub(area.Min))
+	return cell, p.tiles.contains(cell)
+}
+
+// zoomPreview changes the scale of the map and keeps the map position under the anchor in place.
+func (e *Engine) zoomPreview(newScale float64, anchor geometry.Point) {
+	p := &e.preview
+	newScale = max(minPreviewScale, min(newScale, maxPreviewScale))
+	if newScale == p.scale {
+		return
+	}
+	anchorInArea := anchor.Sub(e.previewArea().Min)
+	anchorOnMap := p.mapWindow.GetExactMapPositionFromScreenPos(anchorInArea)
+	p.scale = newScale
+	tileSize := p.mapWindow.GetGridSize().ToPointF().Mul(newScale)
+	newScroll := geometry.Point{X: int(anchorOnMap.X*tileSize.X) - anchorInArea.X, Y: int(anchorOnMap.Y*tileSize.Y) - anchorInArea.Y}
+	p.mapWindow.ScrollBy(newScroll.Sub(p.mapWindow.GetScrollOffset()))
+}
+
+// handleMapPreviewMouse scrolls with the wheel, pans with the middle mouse button, zooms with Ctrl+wheel
+// and selects the entry of a clicked cell.
+func (e *Engine) handleMapPreviewMouse() bool {
+	p := &e.preview
+	if !p.isOpen || p.mapWindow == nil {
+		return false
+	}
+	if p.isPanning {
+		if !ebiten.IsMouseButtonPressed(ebiten.MouseButtonMiddle) {
+			p.isPanning = false
+			e.setCursorShape(ebiten.CursorShapeDefault)
+			return true
+		}
+		p.mapWindow.ScrollBy(p.panStart.Sub(e.mousePosInPixels))
+		p.panStart = e.mousePosInPixels
+		return true
+	}
+	if e.handleMapEditorMouse() {
+		return true
+	}
+	if !p.bounds.Contains(e.mousePosInPixels) {
+		return false
+	}
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonMiddle) {
+		p.isPanning = true
+		p.panStart = e.mousePosInPixels
+		e.setCursorShape(ebiten.CursorShapeMove)
+		return true
+	}
+	if dx, dy := ebiten.Wheel(); dx != 0 || dy != 0 {
+		if isControlPressed() {
+			if dy > 0 {
+				e.zoomPreview(p.scale+1, e.mousePosInPixels)
+			} else if dy < 0 {
+				e.zoomPreview(p.scale-1, e.mousePosInPixels)
+			}
+			return true
+		}
+		if ebiten.IsKeyPressed(ebiten.KeyShift) {
+			dx, dy = dy, dx
+		}
+		p.mapWindow.ScrollBy(geometry.Point{X: int(-dx * wheelSensitivity), Y: int(-dy * wheelSensitivity)})
+		return true
+	}
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		if cell, isOnMap := e.previewCellAt(e.mousePosInPixels); isOnMap {
+			if p.playtest.isActive {
+				e.travelTo(cell)
+				return true
+			}
+			if key := p.tiles.topKeyAt(cell); key != "" {
+				if _, exists := e.keyRecords[key]; exists {
+					e.jumpToKey(key)
+				}
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func (e *Engine) drawMapPreview(screen *ebiten.Image) {
+	p := &e.preview
+	e.renderer.DrawFilledRect(p.bounds.Min, p.bounds.Size(), e.theme.Background)
+	e.renderer.DrawFilledRect(geometry.Point{X: p.bounds.Min.X, Y: p.bounds.Min.Y - splitterWidth}, geometry.Point{X: p.bounds.Size().X, Y: splitterWidth}, e.theme.Separator)
+	if p.mapWindow == nil {
+		return
+	}
+	area := e.previewArea()
+	hoveredCell, isHovering := e.previewCellAt(e.mousePosInPixels)
+
+	// header
+	_, textHeight := e.renderer.MeasureString("Ag")
+	titleX := float64(p.bounds.Min.X) + e.padding
+	title := fmt.Sprintf("%s  %dx%d  %d%%", filepath.Base(p.tiles.fileName), p.tiles.size.X, p.tiles.size.Y, int(p.scale*100))
+	if p.tiles.fileName == "" {
+		title = fmt.Sprintf("new map  %dx%d  %d%%", p.tiles.size.X, p.tiles.size.Y, int(p.scale*100))
+	}
+	if p.editor.isDirty {
+		title = "*" + title
+	}
+	if p.editor.isActive {
+		buttons := e.mapEditorButtons()
+		e.drawButtons(buttons)
+		titleX = float64(buttons[len(buttons)-1].bounds.Max.X) + e.padding*2
+		if e.selectedKey != "" {
+			title += "  paint: " + e.selectedKey
+		}
+	}
+	if p.playtest.isActive {
+		title += "  playtest: arrows walk, click travels, Esc stops"
+	}
+	if isHovering {
+		title += fmt.Sprintf("  (%d, %d)", hoveredCell.X, hoveredCell.Y)
+		if key := p.tiles.topKeyAt(hoveredCell); key != "" {
+			title += "  " + key
+		}
+	}
+	e.renderer.DrawTTFOnScreen(titleX, float64(p.bounds.Min.Y)+(float64(e.previewHeaderHeight())+textHeight)/2-2, title, e.theme.MutedText)
+	if area.Size().X <= 0 || area.Size().Y <= 0 {
+		return
+	}
+
+	// the map is drawn into its own image at the top left corner and then placed in the pane
+	targetSize := geometry.Point{X: int(float64(area.Size().X) * e.deviceDPIScale), Y: int(float64(area.Size().Y) * e.deviceDPIScale)}
+	if p.target == nil || p.target.Bounds().Dx() != targetSize.X || p.target.Bounds().Dy() != targetSize.Y {
+		if p.target != nil {
+			p.target.Dispose()
+		}
+		p.target = ebiten.NewImage(targetSize.X, targetSize.Y)
+	}
+	p.target.Clear()
+	e.renderer.SetRenderTarget(p.target)
+	e.renderer.SetTileScale(e.getPreviewScale)
+	p.mapRenderer.Draw(e.ticks)
+	if p.playtest.isActive {
+		e.drawPlaytestMarkers()
+	} else {
+		e.drawPreviewMarkers(hoveredCell, isHovering)
+	}
+	e.renderer.SetTileScale(e.GetTileScale)
+	e.renderer.SetRenderTarget(screen)
+	e.renderer.DrawImageOnScreen(area.Min.X, area.Min.Y, area.Size(), p.target)
+}
+
+// drawPreviewMarkers outlines the cells of the selected entry and the hovered cell,
+// and marks cells with keys that are not in the mapping.
+func (e *Engine) drawPreviewMarkers(hoveredCell geometry.Point, isHovering bool) {
+	p := &e.preview
+	cellSize := p.mapRenderer.GetScaledTileSize().ToPoint()
+	visible := p.mapWindow.GetVisibleMap()
+	for y := visible.Min.Y; y < visible.Max.Y; y++ {
+		for x := visible.Min.X; x < visible.Max.X; x++ {
+			cell := geometry.Point{X: x, Y: y}
+			if !p.tiles.contains(cell) {
+				continue
+			}
+			cellPos := p.mapWindow.MapToScreen(cell)
+			isSelected := false
+			for layer := range p.tiles.layers {
+				key := p.tiles.keyAt(layer, cell)
+				if key == "" {
+					continue
+				}
+				if _, isMapped := e.iconMapping[key]; !isMapped {
+					e.renderer.DrawFilledRect(cellPos, cellSize, e.theme.Missing)
+				}
+				isSelected = isSelected || e.selectedKeys[key] || key == e.selectedKey
+			}
+			if isSelected {
+				e.renderer.DrawRectOutline(cellPos, cellSize, 1, e.theme.Current)
+			}
+		}
+	}
+	if isHovering {
+		e.renderer.DrawRectOutline(p.mapWindow.MapToScreen(hoveredCell), cellSize, 2, e.theme.Cursor)
+	}
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"ReMapper/geometry"
+	"fmt"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"image/color"
+	"slices"
+)
+
+// atlasOverlay selects what is drawn on top of the atlas cells.
+type atlasOverlay int
+
+const (
+	overlayNone atlasOverlay = iota
+	overlayUsage
+	overlayUnused
+	overlayCount
+)
+
+func (o atlasOverlay) String() string {
+	switch o {
+	case overlayUsage:
+		return "usage count"
+	case overlayUnused:
+		return "unused cells"
+	}
+	return "none"
+}
+
+const (
+	tooltipDelayTicks  = 20
+	maxTooltipEntries  = 12
+	maxOutOfRangeLines = 8
+)
+
+// atlasTooltip lists the entries that use the hovered atlas cell.
+// It stays open while the mouse is over it, so the entries can be clicked.
+type atlasTooltip struct {
+	isOpen     bool
+	cell       geometry.Point
+	hoverTicks int
+	keys       []string
+	bounds     geometry.Rect
+	rowBounds  []geometry.Rect
+}
+
+// clickableRow is a line of text that jumps to an entry in the list when clicked.
+type clickableRow struct {
+	key    string
+	bounds geometry.Rect
+}
+
+// updateIconUsage rebuilds the users of every atlas cell and the entries with an icon outside the atlas.
+func (e *Engine) updateIconUsage() {
+	e.iconUsers = make(map[int32][]string)
+	e.outOfRangeKeys = nil
+	if e.tileAtlas.GetImage() == nil {
+		return
+	}
+	cellCount := e.tileAtlas.GetCellCount()
+	maxIndex := int32(cellCount.X * cellCount.Y)
+	for _, key := range e.orderedKeys {
+		icon := e.iconMapping[key]
+		if icon < 0 || icon >= maxIndex {
+			e.outOfRangeKeys = append(e.outOfRangeKeys, key)
+			continue
+		}
+		// the cells of the background and of the animation frames are used by the entry as well
+		cells := []int32{icon}
+		layers := e.layersOf(key)
+		if layers.hasBgIcon && layers.bgIcon >= 0 && layers.bgIcon < maxIndex {
+			cells = append(cells, layers.bgIcon)
+		}
+		hasFrameOutOfRange := false
+		for _, frame := range layers.frames {
+			if frame.Icon < 0 || frame.Icon >= maxIndex {
+				hasFrameOutOfRange = true
+				continue
+			}
+			cells = append(cells, frame.Icon)
+		}
+		if hasFrameOutOfRange {
+			e.outOfRangeKeys = append(e.outOfRangeKeys, key)
+		}
+		slices.Sort(cells)
+		for _, cell := range slices.Compact(cells) {
+			e.iconUsers[cell] = append(e.iconUsers[cell], key)
+		}
+	}
+}
+
+func (e *Engine) isOutOfRange(key string) bool {
+	cellCount := e.tileAtlas.GetCellCount()
+	icon := e.iconMapping[key]
+	return icon < 0 || icon >= int32(cellCount.X*cellCount.Y)
+}
+
+func (e *Engine) cycleOverlay() {
+	e.atlasOverlay = (e.atlasOverlay + 1) % overlayCount
+}
+
+// jumpToKey selects an entry in the list. The filter is cleared if it hides the entry.
+// The grid keeps its filter and moves its cursor to the entry instead.
+func (e *Engine) jumpToKey(key string) {
+	if e.grid.isActive {
+		e.selectKey(key)
+		e.moveGridCursorToKey(key)
+		return
+	}
+	if e.visibleIndexOf(key) < 0 {
+		e.setSearchText("")
+	}
+	index := e.visibleIndexOf(key)
+	if index < 0 {
+		return
+	}
+	e.focus = focusList
+	e.selectListIndex(index)
+	e.scrollToSelection()
+}
+
+// updateTooltip opens the tooltip after the mouse rested on a used atlas cell.
+func (e *Engine) updateTooltip() {
+	tooltip := &e.tooltip
+	if tooltip.isOpen && tooltip.bounds.Contains(e.mousePosInPixels) {
+		return
+	}
+	if !e.drawAtlasCursor || e.isDraggingAtlas || e.isPanningAtlas {
+		*tooltip = atlasTooltip{}
+		return
+	}
+	cell := e.atlasGridFromScreenPos(e.mousePosInPixels)
+	if cell != tooltip.cell {
+		*tooltip = atlasTooltip{cell: cell}
+		return
+	}
+	tooltip.hoverTicks++
+	if tooltip.hoverTicks < tooltipDelayTicks {
+		return
+	}
+	cellCountX := e.tileAtlas.GetCellCount().X
+	tooltip.keys = e.iconUsers[int32(XYToIndex(cell.X, cell.Y, cellCountX))]
+	tooltip.isOpen = len(tooltip.keys) > 0
+}
+
+// handleOverlayClick jumps to the entry that was clicked in the tooltip or the out of range list.
+func (e *Engine) handleOverlayClick() bool {
+	if !inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		return false
+	}
+	if e.tooltip.isOpen && e.tooltip.bounds.Contains(e.mousePosInPixels) {
+		for index, rowBounds := range e.tooltip.rowBounds {
+			if rowBounds.Contains(e.mousePosInPixels) {
+				key := e.tooltip.keys[index]
+				e.tooltip = atlasTooltip{}
+				e.jumpToKey(key)
+				return true
+			}
+		}
+		return true
+	}
+	for _, row := range e.outOfRangeRows {
+		if row.bounds.Contains(e.mousePosInPixels) {
+			e.jumpToKey(row.key)
+			return true
+		}
+	}
+	return false
+}
+
+// usageColor gets stronger and shifts from green to red with the number of entries using a cell.
+func (t Theme) usageColor(users int) color.RGBA {
+	return t.UsageLevels[min(users, len(t.UsageLevels))-1]
+}
+
+// drawAtlasOverlay draws the active overlay on the visible atlas cells.
+func (e *Engine) drawAtlasOverlay() {
+	if e.atlasOverlay == overlayNone {
+		return
+	}
+	cellCount := e.tileAtlas.GetCellCount()
+	viewport := e.atlasPane.viewport()
+	firstCell := e.atlasGridFromScreenPos(viewport.Min)
+	lastCell := e.atlasGridFromScreenPos(viewport.Max)
+	atlasTileSize := e.tileAtlas.GetTileSize().MulF(e.atlasScale)
+	for y := max(0, firstCell.Y); y <= min(lastCell.Y, cellCount.Y-1); y++ {
+		for x := max(0, firstCell.X); x <= min(lastCell.X, cellCount.X-1); x++ {
+			users := len(e.iconUsers[int32(XYToIndex(x, y, cellCount.X))])
+			cellPos := e.gridToScreen(geometry.Point{X: x, Y: y})
+			switch {
+			case e.atlasOverlay == overlayUsage && users > 0:
+				e.renderer.DrawFilledRect(cellPos, atlasTileSize, e.theme.usageColor(users))
+			case e.atlasOverlay == overlayUnused && users == 0:
+				e.renderer.DrawFilledRect(cellPos, atlasTileSize, e.theme.UnusedFill)
+				e.renderer.DrawRectOutline(cellPos, atlasTileSize, 1, e.theme.UnusedBorder)
+			}
+		}
+	}
+}
+
+// legendLine is a line of the overlay legend, lines with a key jump to the entry when clicked.
+type legendLine struct {
+	text string
+	key  string
+}
+
+// drawOverlayLegend shows the active overlay, the entries with an out of range icon and
+// the entries with an invalid auto-tile table in the bottom left corner of the atlas pane.
+func (e *Engine) drawOverlayLegend() {
+	e.outOfRangeRows = e.outOfRangeRows[:0]
+	if e.atlasOverlay == overlayNone {
+		return
+	}
+	_, lineHeight := e.renderer.MeasureString("Ag")
+	lineHeight += 4
+	lines := []legendLine{{text: fmt.Sprintf("Overlay: %s (F4)", e.atlasOverlay)}}
+	addKeys := func(title string, keys []string, describe func(key string) string) {
+		if len(keys) == 0 {
+			return
+		}
+		lines = append(lines, legendLine{text: fmt.Sprintf("%s (%d):", title, len(keys))})
+		shownKeys := keys[:min(len(keys), maxOutOfRangeLines)]
+		for _, key := range shownKeys {
+			lines = append(lines, legendLine{text: "  " + describe(key), key: key})
+		}
+		if hiddenCount := len(keys) - len(shownKeys); hiddenCount > 0 {
+			lines = append(lines, legendLine{text: fmt.Sprintf("  .. and %d more", hiddenCount)})
+		}
+	}
+	addKeys("Out of range", e.outOfRangeKeys, func(key string) string {
+		if e.isOutOfRange(key) {
+			return fmt.Sprintf("%s = %d", key, e.iconMapping[key])
+		}
+		return fmt.Sprintf("%s (animation frame)", key)
+	})
+	addKeys("Auto-tile problems", sortedKeys(e.autotileProblems), func(key string) string {
+		return fmt.Sprintf("%s: %s", key, e.autotileProblems[key])
+	})
+
+	boxWidth := 0.0
+	for _, line := range lines {
+		lineWidth, _ := e.renderer.MeasureString(line.text)
+		boxWidth = max(boxWidth, lineWidth)
+	}
+	boxSize := geometry.Point{X: int(boxWidth + e.padding*2), Y: int(lineHeight*float64(len(lines)) + e.padding*2)}
+	viewport := e.atlasPane.viewport()
+	boxPos := geometry.Point{X: viewport.Min.X + int(e.padding), Y: viewport.Max.Y - boxSize.Y - int(e.padding) - e.timelineHeight() - e.autotileHeight() - e.fontPanelHeight()}
+	e.renderer.DrawFilledRect(boxPos, boxSize, withAlpha(e.theme.Panel, 240))
+	e.renderer.DrawRectOutline(boxPos, boxSize, 1, e.theme.Border)
+
+	drawY := float64(boxPos.Y) + e.padding
+	for _, line := range lines {
+		lineColor := color.Color(e.theme.Text)
+		if line.key != "" {
+			rowBounds := geometry.NewRect(boxPos.X, int(drawY), boxPos.X+boxSize.X, int(drawY+lineHeight))
+			e.outOfRangeRows = append(e.outOfRangeRows, clickableRow{key: line.key, bounds: rowBounds})
+			lineColor = e.theme.Warning
+			if rowBounds.Contains(e.mousePosInPixels) {
+				lineColor = e.theme.Cursor
+			}
+		}
+		e.renderer.DrawTTFOnScreen(float64(boxPos.X)+e.padding, drawY+lineHeight-4, line.text, lineColor)
+		drawY += lineHeight
+	}
+}
+
+// drawTooltip draws the entries of the hovered cell next to it.
+func (e *Engine) drawTooltip() {
+	tooltip := &e.tooltip
+	if !tooltip.isOpen {
+		return
+	}
+	_, lineHeight := e.renderer.MeasureString("Ag")
+	lineHeight += 4
+	cellCountX := e.tileAtlas.GetCellCount().X
+	title := fmt.Sprintf("#%d used by %d", XYToIndex(tooltip.cell.X, tooltip.cell.Y, cellCountX), len(tooltip.keys))
+	shownKeys := tooltip.keys[:min(len(tooltip.keys), maxTooltipEntries)]
+	lines := append([]string{title}, shownKeys...)
+	if hiddenCount := len(tooltip.keys) - len(shownKeys); hiddenCount > 0 {
+		lines = append(lines, fmt.Sprintf(".. and %d more", hiddenCount))
+	}
+
+	boxWidth := 0.0
+	for _, line := range lines {
+		lineWidth, _ := e.renderer.MeasureString(line)
+		boxWidth = max(boxWidth, lineWidth)
+	}
+	boxSize := geometry.Point{X: int(boxWidth + e.padding*2), Y: int(lineHeight*float64(len(lines)) + e.padding*2)}
+	// right next to the cell, so the mouse can move into the tooltip without leaving the cell
+	cellPos := e.gridToScreen(tooltip.cell)
+	atlasTileSize := e.tileAtlas.GetTileSize().MulF(e.atlasScale)
+	boxPos := geometry.Point{X: cellPos.X + atlasTileSize.X, Y: cellPos.Y}
+	screenSize := e.deviceIndependentScreenSize
+	if boxPos.X+boxSize.X > screenSize.X {
+		boxPos.X = cellPos.X - boxSize.X
+	}
+	boxPos.Y = clamp(boxPos.Y, 0, max(0, screenSize.Y-boxSize.Y))
+	tooltip.bounds = geometry.Rect{Min: boxPos, Max: boxPos.Add(boxSize)}
+
+	e.renderer.DrawFilledRect(boxPos, boxSize, withAlpha(e.theme.Panel, 245))
+	e.renderer.DrawRectOutline(boxPos, boxSize, 1, e.theme.Cursor)
+	tooltip.rowBounds = tooltip.rowBounds[:0]
+	drawY := float64(boxPos.Y) + e.padding
+	for index, line := range lines {
+		lineColor := color.Color(e.theme.Cursor)
+		if keyIndex := index - 1; keyIndex >= 0 && keyIndex < len(shownKeys) {
+			rowBounds := geometry.NewRect(boxPos.X, int(drawY), boxPos.X+boxSize.X, int(drawY+lineHeight))
+			tooltip.rowBounds = append(tooltip.rowBounds, rowBounds)
+			lineColor = e.theme.Text
+			if rowBounds.Contains(e.mousePosInPixels) {
+				e.renderer.DrawFilledRect(rowBounds.Min, rowBounds.Size(), e.theme.Field)
+				lineColor = e.theme.Current
+			}
+		}
+		e.renderer.DrawTTFOnScreen(float64(boxPos.X)+e.padding, drawY+lineHeight-4, line, lineColor)
+		drawY += lineHeight
+	}
+}
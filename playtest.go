@@ -0,0 +1,263 @@
+package main
+
+import (
+	"ReMapper/geometry"
+	"ReMapper/recfile"
+	"ReMapper/renderer"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"image/color"
+	"math"
+)
+
+const (
+	sightRadius      = 8
+	travelStepTicks  = 4
+	memoryBrightness = 0.35
+	memorySaturation = 0.3
+)
+
+// playtest walks an @ over the preview map to see the tiles under fog and lighting.
+// Entries with the opaque field block the sight, entries with the walkable field set to
+// false block the way, so a closed door is opaque but walkable. Empty cells can't be entered.
+type playtest struct {
+	isActive  bool
+	player    geometry.Point
+	fov       *geometry.FOV
+	pathRange *geometry.PathRange
+	visible   []bool
+	explored  []bool
+	path      []geometry.Point // to the cell under the mouse
+	travel    []geometry.Point // the rest of a clicked path
+}
+
+var playtestMoves = map[ebiten.Key]geometry.Point{
+	ebiten.KeyArrowLeft:  {X: -1},
+	ebiten.KeyArrowRight: {X: 1},
+	ebiten.KeyArrowUp:    {Y: -1},
+	ebiten.KeyArrowDown:  {Y: 1},
+	ebiten.KeyNumpad4:    {X: -1},
+	ebiten.KeyNumpad6:    {X: 1},
+	ebiten.KeyNumpad8:    {Y: -1},
+	ebiten.KeyNumpad2:    {Y: 1},
+	ebiten.KeyNumpad7:    {X: -1, Y: -1},
+	ebiten.KeyNumpad9:    {X: 1, Y: -1},
+	ebiten.KeyNumpad1:    {X: -1, Y: 1},
+	ebiten.KeyNumpad3:    {X: 1, Y: 1},
+}
+
+// togglePlaytest starts walking on the preview map or stops it. The explored cells are forgotten.
+func (e *Engine) togglePlaytest() {
+	p := &e.preview
+	if p.playtest.isActive || !p.isOpen || p.tiles == nil {
+		p.playtest = playtest{}
+		return
+	}
+	mapRect := geometry.NewRect(0, 0, p.tiles.size.X, p.tiles.size.Y)
+	cellCount := p.tiles.size.X * p.tiles.size.Y
+	p.playtest = playtest{
+		isActive:  true,
+		player:    e.playtestStart(),
+		fov:       geometry.NewFOV(mapRect),
+		pathRange: geometry.NewPathRange(mapRect),
+		visible:   make([]bool, cellCount),
+		explored:  make([]bool, cellCount),
+	}
+	p.editor.isActive = false
+	e.updateVision()
+	p.mapWindow.CenterOn(p.playtest.player)
+}
+
+// playtestStart is the walkable cell closest to the center of the map.
+func (e *Engine) playtestStart() geometry.Point {
+	tiles := e.preview.tiles
+	center := tiles.size.Div(2)
+	start, bestDistance := center, math.MaxInt
+	for y := 0; y < tiles.size.Y; y++ {
+		for x := 0; x < tiles.size.X; x++ {
+			cell := geometry.Point{X: x, Y: y}
+			if distance := cell.ChebyshevDistanceTo(center); distance < bestDistance && e.isWalkable(cell) {
+				start, bestDistance = cell, distance
+			}
+		}
+	}
+	return start
+}
+
+// cellTerrain combines the opaque and walkable fields of the entries of all layers in a cell.
+func (e *Engine) cellTerrain(cell geometry.Point) (opaque, walkable bool) {
+	tiles := e.preview.tiles
+	hasEntry := false
+	walkable = true
+	for layer := range tiles.layers {
+		key := tiles.keyAt(layer, cell)
+		if key == "" {
+			continue
+		}
+		hasEntry = true
+		record := e.keyRecords[key]
+		if value, hasField := findField(record, e.config.OpaqueField); hasField && recfile.StrBool(value) {
+			opaque = true
+		}
+		if value, hasField := findField(record, e.config.WalkableField); hasField && !recfile.StrBool(value) {
+			walkable = false
+		}
+	}
+	return opaque, walkable && hasEntry
+}
+
+func (e *Engine) isTransparent(cell geometry.Point) bool {
+	opaque, _ := e.cellTerrain(cell)
+	return !opaque
+}
+
+func (e *Engine) isWalkable(cell geometry.Point) bool {
+	_, walkable := e.cellTerrain(cell)
+	return walkable
+}
+
+// isKnownWalkable keeps paths inside of the explored part of the map.
+func (e *Engine) isKnownWalkable(cell geometry.Point) bool {
+	t := &e.preview.playtest
+	return t.explored[e.preview.tiles.cellIndex(cell)] && e.isWalkable(cell)
+}
+
+// updateVision computes the visible cells from the position of the player and remembers them.
+func (e *Engine) updateVision() {
+	t := &e.preview.playtest
+	clear(t.visible)
+	for _, cell := range t.fov.SSCVisionMap(t.player, sightRadius, e.isTransparent, true) {
+		index := e.preview.tiles.cellIndex(cell)
+		t.visible[index] = true
+		t.explored[index] = true
+	}
+}
+
+func (e *Engine) movePlayer(delta geometry.Point) bool {
+	p := &e.preview
+	target := p.playtest.player.Add(delta)
+	if !p.tiles.contains(target) || !e.isWalkable(target) {
+		return false
+	}
+	p.playtest.player = target
+	e.updateVision()
+	p.mapWindow.CenterOn(target)
+	return true
+}
+
+// travelTo follows the path to a clicked cell, one step every few ticks.
+func (e *Engine) travelTo(cell geometry.Point) {
+	t := &e.preview.playtest
+	path := t.pathRange.JPSPath(nil, t.player, cell, e.isKnownWalkable, true)
+	if len(path) < 2 {
+		t.travel = nil
+		return
+	}
+	t.travel = path[1:]
+}
+
+// handlePlaytestKeys moves the player with the arrows and the numpad, Esc stops the playtest.
+func (e *Engine) handlePlaytestKeys() bool {
+	t := &e.preview.playtest
+	if !t.isActive {
+		return false
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		e.togglePlaytest()
+		return true
+	}
+	isMoveKeyPressed := false
+	for key, delta := range playtestMoves {
+		if !ebiten.IsKeyPressed(key) {
+			continue
+		}
+		isMoveKeyPressed = true
+		if isKeyRepeated(key) {
+			t.travel = nil
+			e.movePlayer(delta)
+		}
+	}
+	// held keys must not reach the list navigation or the search box
+	return isMoveKeyPressed
+}
+
+// updatePlaytest follows the vision after edits of the mapping, the path to the mouse and the travel.
+func (e *Engine) updatePlaytest() {
+	p := &e.preview
+	t := &p.playtest
+	if !t.isActive {
+		return
+	}
+	if !p.isOpen {
+		e.togglePlaytest()
+		return
+	}
+	e.updateVision()
+	t.path = t.path[:0]
+	if cell, isOnMap := e.previewCellAt(e.mousePosInPixels); isOnMap && cell != t.player {
+		t.path = t.pathRange.JPSPath(t.path, t.player, cell, e.isKnownWalkable, true)
+	}
+	if len(t.travel) > 0 && e.ticks%travelStepTicks == 0 {
+		if e.movePlayer(t.travel[0].Sub(t.player)) {
+			t.travel = t.travel[1:]
+		} else {
+			t.travel = nil
+		}
+	}
+}
+
+// lightCell darkens the layers of a cell with the distance to the player. Remembered cells
+// are dim and grey, unexplored cells and cells outside of the map are not drawn.
+func (e *Engine) lightCell(cell geometry.Point, infos []renderer.CellDrawInfo) []renderer.CellDrawInfo {
+	t := &e.preview.playtest
+	if !e.preview.tiles.contains(cell) {
+		return nil
+	}
+	index := e.preview.tiles.cellIndex(cell)
+	if !t.explored[index] {
+		return nil
+	}
+	brightness, saturation := memoryBrightness, memorySaturation
+	if t.visible[index] {
+		delta := cell.Sub(t.player)
+		distance := math.Hypot(float64(delta.X), float64(delta.Y))
+		brightness, saturation = 1-0.6*min(1, distance/(sightRadius+1)), 1
+	}
+	for index := range infos {
+		infos[index].Color = shadeColor(infos[index].Color, brightness, saturation)
+	}
+	return infos
+}
+
+func shadeColor(c color.Color, brightness, saturation float64) color.RGBA {
+	r, g, b, a := c.RGBA()
+	values := [3]float64{float64(r >> 8), float64(g >> 8), float64(b >> 8)}
+	grey := 0.299*values[0] + 0.587*values[1] + 0.114*values[2]
+	for index, value := range values {
+		values[index] = (grey + (value-grey)*saturation) * brightness
+	}
+	return color.RGBA{R: uint8(values[0]), G: uint8(values[1]), B: uint8(values[2]), A: uint8(a >> 8)}
+}
+
+// drawPlaytestMarkers draws the path to the mouse and the player into the map image.
+func (e *Engine) drawPlaytestMarkers() {
+	p := &e.preview
+	t := &p.playtest
+	cellSize := p.mapRenderer.GetScaledTileSize().ToPoint()
+	markerSize := cellSize.Div(3)
+	for index, cell := range t.path {
+		if index == 0 {
+			continue // the player
+		}
+		cellPos := p.mapWindow.MapToScreen(cell)
+		if index == len(t.path)-1 {
+			e.renderer.DrawRectOutline(cellPos, cellSize, 2, e.theme.Path)
+			continue
+		}
+		e.renderer.DrawFilledRect(cellPos.Add(markerSize), markerSize, withAlpha(e.theme.Path, 160))
+	}
+	playerPos := p.mapWindow.MapToScreen(t.player)
+	e.renderer.DrawFilledRect(playerPos, cellSize, withAlpha(e.theme.Panel, 200))
+	textWidth, textHeight := e.renderer.MeasureString("@")
+	e.renderer.DrawTTFOnScreen(float64(playerPos.X)+(float64(cellSize.X)-textWidth)/2, float64(playerPos.Y)+(float64(cellSize.Y)+textHeight)/2-2, "@", e.theme.Text)
+}
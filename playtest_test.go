@@ -0,0 +1,30 @@
+package main
+
+import (
+	"ReMapper/geometry"
+	"ReMapper/renderer"
+	"image/color"
+	"testing"
+)
+
+func TestLightCellOutsideOfASmallMap(t *testing.T) {
+	e := &Engine{}
+	e.preview.tiles = newEmptyTileMap(geometry.Point{X: 3, Y: 2}, "ground")
+	e.preview.playtest = playtest{
+		isActive: true,
+		player:   geometry.Point{X: 1, Y: 0},
+		visible:  []bool{true, true, true, false, false, false},
+		explored: []bool{true, true, true, true, true, true},
+	}
+	// the map renderer asks for every cell of the window, no matter how big the map is
+	windowSize := geometry.Point{X: 10, Y: 8}
+	for y := -1; y < windowSize.Y; y++ {
+		for x := -1; x < windowSize.X; x++ {
+			cell := geometry.Point{X: x, Y: y}
+			infos := e.lightCell(cell, []renderer.CellDrawInfo{{Icon: 1, Color: color.White}})
+			if isOnMap := e.preview.tiles.contains(cell); isOnMap != (infos != nil) {
+				t.Errorf("cell %v on the map: %t, drawn: %t", cell, isOnMap, infos != nil)
+			}
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"ReMapper/geometry"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"unicode"
+	"unicode/utf8"
+)
+
+// textPrompt asks for a line of text. onAccept is called with the text when Enter
+// is pressed; if it returns an error, the prompt stays open and shows the error.
+type textPrompt struct {
+	isOpen    bool
+	title     string
+	text      string
+	errorText string
+	onAccept  func(text string) error
+}
+
+func (e *Engine) openTextPrompt(title, initialText string, onAccept func(text string) error) {
+	e.textPrompt = textPrompt{isOpen: true, title: title, text: initialText, onAccept: onAccept}
+}
+
+// handleTextPrompt consumes all keyboard input while the prompt is open.
+func (e *Engine) handleTextPrompt() bool {
+	prompt := &e.textPrompt
+	if !prompt.isOpen {
+		return false
+	}
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyEscape):
+		prompt.isOpen = false
+	case isKeyRepeated(ebiten.KeyBackspace):
+		if prompt.text != "" {
+			_, lastSize := utf8.DecodeLastRuneInString(prompt.text)
+			prompt.text = prompt.text[:len(prompt.text)-lastSize]
+		}
+		prompt.errorText = ""
+	case isEnterJustPressed():
+		if err := prompt.onAccept(prompt.text); err != nil {
+			prompt.errorText = err.Error()
+			return true
+		}
+		prompt.isOpen = false
+	default:
+		e.inputChars = ebiten.AppendInputChars(e.inputChars[:0])
+		if isControlPressed() {
+			// the shortcut that opened the prompt
+			return true
+		}
+		for _, char := range e.inputChars {
+			if unicode.IsPrint(char) {
+				prompt.text += string(char)
+				prompt.errorText = ""
+			}
+		}
+	}
+	return true
+}
+
+func (e *Engine) drawTextPrompt() {
+	prompt := &e.textPrompt
+	label := prompt.title + ": " + prompt.text + "_"
+	textWidth, textHeight := e.renderer.MeasureString(label)
+	size := geometry.Point{X: int(textWidth + e.padding*4), Y: int(textHeight + e.padding*2)}
+	if prompt.errorText != "" {
+		errorWidth, _ := e.renderer.MeasureString(prompt.errorText)
+		size.X = max(size.X, int(errorWidth+e.padding*4))
+		size.Y += int(textHeight + e.padding)
+	}
+	pos := geometry.Point{X: (e.deviceIndependentScreenSize.X - size.X) / 2, Y: (e.deviceIndependentScreenSize.Y - size.Y) / 2}
+	e.renderer.DrawFilledRect(pos, size, withAlpha(e.theme.Panel, 245))
+	e.renderer.DrawRectOutline(pos, size, 2, e.theme.Cursor)
+	textY := float64(pos.Y) + e.padding + textHeight - 2
+	e.renderer.DrawTTFOnScreen(float64(pos.X)+e.padding*2, textY, label, e.theme.Text)
+	if prompt.errorText != "" {
+		e.renderer.DrawTTFOnScreen(float64(pos.X)+e.padding*2, textY+textHeight+e.padding, prompt.errorText, e.theme.Error)
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"ReMapper/geometry"
+	"ReMapper/recfile"
+	"ReMapper/renderer"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// validateNewKey checks a key that is about to be added to the mapping.
+func (e *Engine) validateNewKey(key string) error {
+	if strings.TrimSpace(key) == "" {
+		return fmt.Errorf("the key must not be empty")
+	}
+	if strings.TrimSpace(key) != key {
+		return fmt.Errorf("the key must not start or end with spaces")
+	}
+	if _, exists := e.iconMapping[key]; exists {
+		return fmt.Errorf("there already is an entry '%s'", key)
+	}
+	if e.font.isActive {
+		if char, err := renderer.ParseFontRune(key); err != nil || renderer.FormatFontRune(char) != key {
+			return fmt.Errorf("a font map key is written as %s", renderer.FormatFontRune('A'))
+		}
+	}
+	return nil
+}
+
+// recordIndexOfKey returns the index of the record with the given key in records, or -1.
+// Font maps compare the runes of the keys, so U+0041 finds a record written as A.
+func (e *Engine) recordIndexOfKey(records []recfile.Record, key string) int {
+	for index, record := range records {
+		recordKey := record.FindFirstFieldValue(e.config.KeyField)
+		if recordKey == key || e.font.isActive && isSameRune(recordKey, key) {
+			return index
+		}
+	}
+	return -1
+}
+
+// editRecords changes a copy of the current records and icons with edit and
+// executes the result as one undoable command. edit returns the key to select afterwards.
+func (e *Engine) editRecords(description string, edit func(records []recfile.Record, mapping map[string]int32) ([]recfile.Record, string)) {
+	mapping := maps.Clone(e.iconMapping)
+	records, newSelection := edit(e.currentRecords(), mapping)
+	e.replaceRecords(description, records, mapping, newSelection)
+}
+
+// replaceRecords executes the replacement of all records and icons as one undoable command.
+func (e *Engine) replaceRecords(description string, records []recfile.Record, mapping map[string]int32, newSelection string) {
+	e.execute(&replaceDocumentCommand{
+		description:  description,
+		oldRecords:   e.originalRecords,
+		oldMapping:   e.iconMapping,
+		newRecords:   records,
+		newMapping:   mapping,
+		oldSelection: e.selectedKey,
+		newSelection: newSelection,
+	})
+}
+
+// openNewRecordPrompt asks for the key of a new record. The new record gets the
+// cell under the atlas cursor as icon and is added after the selected record.
+func (e *Engine) openNewRecordPrompt() {
+	if e.font.isActive {
+		e.openFontCharsPrompt()
+		return
+	}
+	e.openTextPrompt("New entry", "", func(key string) error {
+		if err := e.validateNewKey(key); err != nil {
+			return err
+		}
+		icon := int32(XYToIndex(e.atlasCursor.X, e.atlasCursor.Y, e.tileAtlas.GetCellCount().X))
+		e.editRecords(fmt.Sprintf("add %s", key), func(records []recfile.Record, mapping map[string]int32) ([]recfile.Record, string) {
+			newRecord := recfile.Record{
+				{Name: e.config.KeyField, Value: key},
+				{Name: e.config.IconField, Value: recfile.Int32Str(icon)},
+			}
+			insertAt := len(records)
+			if selectedIndex := e.recordIndexOfKey(records, e.selectedKey); selectedIndex >= 0 {
+				insertAt = selectedIndex + 1
+			}
+			mapping[key] = icon
+			return insertRecord(records, insertAt, newRecord), key
+		})
+		return nil
+	})
+}
+
+// openDuplicatePrompt asks for the key of a copy of the selected record.
+func (e *Engine) openDuplicatePrompt() {
+	sourceKey := e.selectedKey
+	if _, exists := e.iconMapping[sourceKey]; !exists {
+		return
+	}
+	e.openTextPrompt(fmt.Sprintf("Duplicate %s as", sourceKey), sourceKey+"_copy", func(key string) error {
+		if err := e.validateNewKey(key); err != nil {
+			return err
+		}
+		e.editRecords(fmt.Sprintf("duplicate %s as %s", sourceKey, key), func(records []recfile.Record, mapping map[string]int32) ([]recfile.Record, string) {
+			sourceIndex := e.recordIndexOfKey(records, sourceKey)
+			copiedRecord := setField(slices.Clone(records[sourceIndex]), e.config.KeyField, key)
+			mapping[key] = mapping[sourceKey]
+			return insertRecord(records, sourceIndex+1, copiedRecord), key
+		})
+		return nil
+	})
+}
+
+// openRenamePrompt asks for a new key of the selected record.
+func (e *Engine) openRenamePrompt() {
+	oldKey := e.selectedKey
+	if _, exists := e.iconMapping[oldKey]; !exists {
+		return
+	}
+	e.openTextPrompt(fmt.Sprintf("Rename %s to", oldKey), oldKey, func(key string) error {
+		if key == oldKey {
+			return nil
+		}
+		if err := e.validateNewKey(key); err != nil {
+			return err
+		}
+		e.editRecords(fmt.Sprintf("rename %s to %s", oldKey, key), func(records []recfile.Record, mapping map[string]int32) ([]recfile.Record, string) {
+			recordIndex := e.recordIndexOfKey(records, oldKey)
+			records[recordIndex] = setField(records[recordIndex], e.config.KeyField, key)
+			mapping[key] = mapping[oldKey]
+			delete(mapping, oldKey)
+			return records, key
+		})
+		return nil
+	})
+}
+
+// deleteSelectedRecords removes the records of all selected entries.
+func (e *Engine) deleteSelectedRecords() bool {
+	keys := e.selectedVisibleKeys()
+	if len(keys) == 0 {
+		return false
+	}
+	description := fmt.Sprintf("delete %s", keys[0])
+	if len(keys) > 1 {
+		description = fmt.Sprintf("delete %d entries", len(keys))
+	}
+	// select the entry after the deleted ones
+	var nextKey string
+	for index := e.selectedListIndex + 1; index < len(e.visibleKeys); index++ {
+		if !e.selectedKeys[e.visibleKeys[index]] {
+			nextKey = e.visibleKeys[index]
+			break
+		}
+	}
+	isDeleted := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		isDeleted[key] = true
+	}
+	e.editRecords(description, func(records []recfile.Record, mapping map[string]int32) ([]recfile.Record, string) {
+		var remaining []recfile.Record
+		for _, record := range records {
+			if !isDeleted[record.FindFirstFieldValue(e.config.KeyField)] {
+				remaining = append(remaining, record)
+			}
+		}
+		for _, key := range keys {
+			delete(mapping, key)
+		}
+		return remaining, nextKey
+	})
+	return true
+}
+
+func insertRecord(records []recfile.Record, index int, record recfile.Record) []recfile.Record {
+	records = append(records, nil)
+	copy(records[index+1:], records[index:])
+	records[index] = record
+	return records
+}
+
+// setField sets the first field called fieldName to value, the field is appended if the record has none.
+func setField(record recfile.Record, fieldName, value string) recfile.Record {
+	for index, field := range record {
+		if field.Name == fieldName {
+			record[index].Value = value
+			return record
+		}
+	}
+	return append(record, recfile.Field{Name: fieldName, Value: value})
+}
+
+// duplicateKeys returns the keys that are used by more than one record.
+func duplicateKeys(records []recfile.Record, config MappingConfig) []string {
+	return analyzeMapping(records, config, geometry.Point{}).DuplicateKeys
+}
@@ -0,0 +1,42 @@
+package renderer
+
+// AnimationFrame shows Icon for Ticks ticks.
+type AnimationFrame struct {
+    Icon  int32
+    Ticks int
+}
+
+// Animation is a looping sequence of frames.
+type Animation []AnimationFrame
+
+// Duration returns the length of one loop in ticks.
+func (a Animation) Duration() int {
+    duration := 0
+    for _, frame := range a {
+        duration += max(1, frame.Ticks)
+    }
+    return duration
+}
+
+// FrameIndexAt returns the index of the frame that is shown at the given tick.
+func (a Animation) FrameIndexAt(tick uint64) int {
+    if len(a) == 0 {
+        return -1
+    }
+    position := int(tick % uint64(a.Duration()))
+    for index, frame := range a {
+        position -= max(1, frame.Ticks)
+        if position < 0 {
+            return index
+        }
+    }
+    return len(a) - 1
+}
+
+// IconAt returns the icon of the cell at the given tick, the frames of an animation take precedence over Icon.
+func (c CellDrawInfo) IconAt(tick uint64) int32 {
+    if len(c.Frames) == 0 {
+        return c.Icon
+    }
+    return c.Frames[c.Frames.FrameIndexAt(tick)].Icon
+}
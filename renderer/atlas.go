@@ -11,10 +11,25 @@ import (
     "os"
 )
 
+// CellDrawInfo is one layer of a map cell. If Frames is set, the cell is animated and Icon is ignored.
 type CellDrawInfo struct {
-    Icon  int32
-    Color color.Color
-    Atlas TextureAtlas
+    Icon        int32
+    Color       color.Color
+    Atlas       TextureAtlas
+    Orientation TileOrientation
+    Frames      Animation
+}
+
+// TileOrientation mirrors and turns a tile. Rotation is clockwise in degrees: 0, 90, 180 or 270.
+// Mirroring happens before the rotation.
+type TileOrientation struct {
+    FlipX    bool
+    FlipY    bool
+    Rotation int
+}
+
+func (o TileOrientation) IsDefault() bool {
+    return !o.FlipX && !o.FlipY && o.Rotation%360 == 0
 }
 type TextureAtlas struct {
     imageData *ebiten.Image
@@ -1,11 +1,49 @@
 package renderer
 
+import (
+	"ReMapper/geometry"
+	"ReMapper/recfile"
+	"fmt"
+	"github.com/hajimehoshi/ebiten/v2"
+	"image"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// BitmapFont draws text from an image. A grid font maps each rune to an atlas cell and
+// advances by the cell width, a glyph font (see LoadBitmapFont) has a rectangle, an offset
+// and an advance per rune and kerning pairs.
 type BitmapFont struct {
 	atlas   TextureAtlas
 	fontMap map[rune]uint16
+
+	pages      []*ebiten.Image
+	glyphs     map[rune]Glyph
+	kerning    map[KerningPair]int
+	lineHeight int
+}
+
+// Glyph is a rune of a glyph font. Offset is the distance from the top left corner of the line
+// to the top left corner of the glyph, Advance the distance to the next rune. All values are in pixels of the font.
+type Glyph struct {
+	Page    int
+	Rect    image.Rectangle
+	Offset  geometry.Point
+	Advance int
+}
+
+// KerningPair is a pair of runes whose distance is adjusted when they follow each other.
+type KerningPair struct {
+	First  rune
+	Second rune
 }
 
 func (f BitmapFont) IsLoaded() bool {
+	if f.glyphs != nil {
+		return len(f.glyphs) > 0 && len(f.pages) > 0
+	}
 	return f.atlas.imageData != nil && f.fontMap != nil && len(f.fontMap) > 0
 }
 
@@ -16,6 +54,88 @@ func NewBitmapFont(atlas TextureAtlas, fontMap map[rune]uint16) BitmapFont {
 	}
 }
 
+// NewGlyphFont creates a font with variable glyphs, the rectangles of the glyphs refer to the pages.
+func NewGlyphFont(pages []*ebiten.Image, glyphs map[rune]Glyph, kerning map[KerningPair]int, lineHeight int) BitmapFont {
+	return BitmapFont{
+		pages:      pages,
+		glyphs:     glyphs,
+		kerning:    kerning,
+		lineHeight: lineHeight,
+	}
+}
+
+// glyphOf returns the image and the metrics of a rune. The glyph of a grid font is its atlas cell.
+func (f BitmapFont) glyphOf(char rune) (*ebiten.Image, Glyph, bool) {
+	if f.glyphs != nil {
+		glyph, ok := f.glyphs[char]
+		if !ok || glyph.Page < 0 || glyph.Page >= len(f.pages) || f.pages[glyph.Page] == nil || glyph.Rect.Empty() {
+			return nil, glyph, false
+		}
+		return f.pages[glyph.Page].SubImage(glyph.Rect).(*ebiten.Image), glyph, true
+	}
+	textureIndex, ok := f.fontMap[char]
+	if !ok {
+		return nil, Glyph{}, false
+	}
+	glyphImage := ExtractSubImageFromAtlas(int32(textureIndex), f.atlas)
+	return glyphImage, Glyph{Rect: glyphImage.Bounds(), Advance: f.atlas.tileSizeX}, true
+}
+
+// Advance returns the distance from a rune to the next one. Grid fonts advance by the cell width,
+// even for runes they don't have.
+func (f BitmapFont) Advance(char rune) int {
+	if f.glyphs != nil {
+		return f.glyphs[char].Advance
+	}
+	return f.atlas.tileSizeX
+}
+
+// Kerning returns the adjustment of the distance between two runes that follow each other.
+func (f BitmapFont) Kerning(first, second rune) int {
+	return f.kerning[KerningPair{First: first, Second: second}]
+}
+
+// LineHeight returns the distance between two lines.
+func (f BitmapFont) LineHeight() int {
+	if f.glyphs != nil {
+		return f.lineHeight
+	}
+	return f.atlas.tileSizeY
+}
+
+// CellSize is the size of a cell when text is drawn on a grid: the atlas cell of a grid font,
+// the advance of the widest rune and the line height of a glyph font.
+func (f BitmapFont) CellSize() geometry.Point {
+	if f.glyphs == nil {
+		return f.atlas.GetTileSize()
+	}
+	size := geometry.Point{Y: f.lineHeight}
+	for _, glyph := range f.glyphs {
+		size.X = max(size.X, glyph.Advance)
+	}
+	return size
+}
+
+// MeasureString returns the size of a line of text in pixels of the font, with advances and kerning.
+func (f BitmapFont) MeasureString(text string) geometry.Point {
+	width := 0
+	previous := rune(-1)
+	for _, char := range text {
+		width += f.Kerning(previous, char) + f.Advance(char)
+		previous = char
+	}
+	return geometry.Point{X: width, Y: f.LineHeight()}
+}
+
+// MeasureMultiString returns the size of the lines, as wide as the widest one.
+func (f BitmapFont) MeasureMultiString(lines []string) geometry.Point {
+	width := 0
+	for _, line := range lines {
+		width = max(width, f.MeasureString(line).X)
+	}
+	return geometry.Point{X: width, Y: f.LineHeight() * len(lines)}
+}
+
 type FontAtlasDescription struct {
 	IndexOfCapitalA int
 	IndexOfSmallA   *int
@@ -70,3 +190,55 @@ func NewFontIndexFromDescription(desc FontAtlasDescription) map[rune]uint16 {
 
 	return result
 }
+
+// FontRuneField and FontIconField are the fields of a font map rec file,
+// one record per rune:
+//
+//	rune: U+0041
+//	icon: 33
+const (
+	FontRuneField = "rune"
+	FontIconField = "icon"
+)
+
+// FormatFontRune writes a rune the way font map files store it, e.g. U+0041.
+func FormatFontRune(r rune) string {
+	return fmt.Sprintf("U+%04X", r)
+}
+
+// ParseFontRune reads a rune written as U+0041 or as the character itself.
+func ParseFontRune(text string) (rune, error) {
+	if utf8.RuneCountInString(text) == 1 {
+		r, _ := utf8.DecodeRuneInString(text)
+		return r, nil
+	}
+	if hexCode, hasPrefix := strings.CutPrefix(strings.ToUpper(text), "U+"); hasPrefix {
+		code, err := strconv.ParseUint(hexCode, 16, 32)
+		if err == nil && utf8.ValidRune(rune(code)) {
+			return rune(code), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid rune '%s', expected U+0041 or a single character", text)
+}
+
+// ReadFontMap reads a font map rec file, the counterpart of NewFontIndexFromDescription
+// for fonts that were mapped in ReMapper.
+func ReadFontMap(reader io.Reader) (map[rune]uint16, error) {
+	result := map[rune]uint16{}
+	for _, record := range recfile.Read(reader) {
+		runeText := record.FindFirstFieldValue(FontRuneField)
+		if runeText == "" {
+			continue
+		}
+		char, err := ParseFontRune(runeText)
+		if err != nil {
+			return nil, err
+		}
+		icon, err := strconv.ParseUint(record.FindFirstFieldValue(FontIconField), 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("rune %s has no valid icon", runeText)
+		}
+		result[char] = uint16(icon)
+	}
+	return result, nil
+}
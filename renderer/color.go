@@ -0,0 +1,45 @@
+package renderer
+
+import (
+    "fmt"
+    "image/color"
+    "strconv"
+    "strings"
+)
+
+// ParseColor reads a color written as hex ("#ff8000" or "ff8000") or as "r,g,b" with values from 0 to 255.
+func ParseColor(text string) (color.RGBA, bool) {
+    text = strings.TrimSpace(text)
+    if strings.ContainsRune(text, ',') {
+        parts := strings.Split(text, ",")
+        if len(parts) != 3 {
+            return color.RGBA{}, false
+        }
+        var channels [3]uint8
+        for i, part := range parts {
+            value, err := strconv.ParseUint(strings.TrimSpace(part), 10, 8)
+            if err != nil {
+                return color.RGBA{}, false
+            }
+            channels[i] = uint8(value)
+        }
+        return color.RGBA{R: channels[0], G: channels[1], B: channels[2], A: 255}, true
+    }
+    hex := strings.TrimPrefix(text, "#")
+    if len(hex) != 6 {
+        return color.RGBA{}, false
+    }
+    value, err := strconv.ParseUint(hex, 16, 32)
+    if err != nil {
+        return color.RGBA{}, false
+    }
+    return color.RGBA{R: uint8(value >> 16), G: uint8(value >> 8), B: uint8(value), A: 255}, true
+}
+
+// FormatColor writes a color as "#rrggbb", or as "r,g,b" if asTriple is set.
+func FormatColor(c color.RGBA, asTriple bool) string {
+    if asTriple {
+        return fmt.Sprintf("%d,%d,%d", c.R, c.G, c.B)
+    }
+    return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
@@ -0,0 +1,356 @@
+package renderer
+
+import (
+	"ReMapper/geometry"
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"github.com/hajimehoshi/ebiten/v2"
+	"image"
+	"image/color"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// LoadBitmapFont loads an AngelCode BMFont (.fnt, text or XML) or a BDF font (.bdf).
+func LoadBitmapFont(fileName string) (BitmapFont, error) {
+	if strings.EqualFold(filepath.Ext(fileName), ".bdf") {
+		return LoadBDFFont(fileName)
+	}
+	return LoadAngelCodeFont(fileName)
+}
+
+// angelCodeFont holds the parts of a BMFont description that BitmapFont uses.
+// The XML format is decoded directly, the text format is read into the same fields.
+type angelCodeFont struct {
+	Common struct {
+		LineHeight int `xml:"lineHeight,attr"`
+	} `xml:"common"`
+	Pages    []angelCodePage    `xml:"pages>page"`
+	Chars    []angelCodeChar    `xml:"chars>char"`
+	Kernings []angelCodeKerning `xml:"kernings>kerning"`
+}
+
+type angelCodePage struct {
+	ID   int    `xml:"id,attr"`
+	File string `xml:"file,attr"`
+}
+
+type angelCodeChar struct {
+	ID       int `xml:"id,attr"`
+	X        int `xml:"x,attr"`
+	Y        int `xml:"y,attr"`
+	Width    int `xml:"width,attr"`
+	Height   int `xml:"height,attr"`
+	XOffset  int `xml:"xoffset,attr"`
+	YOffset  int `xml:"yoffset,attr"`
+	XAdvance int `xml:"xadvance,attr"`
+	Page     int `xml:"page,attr"`
+}
+
+type angelCodeKerning struct {
+	First  int `xml:"first,attr"`
+	Second int `xml:"second,attr"`
+	Amount int `xml:"amount,attr"`
+}
+
+// LoadAngelCodeFont loads a BMFont description and its page images, which are looked up
+// next to the description.
+func LoadAngelCodeFont(fileName string) (BitmapFont, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return BitmapFont{}, err
+	}
+	description, err := parseAngelCodeFont(data)
+	if err != nil {
+		return BitmapFont{}, fmt.Errorf("%s: %w", fileName, err)
+	}
+	// validate made sure that the page ids are 0 to len(Pages)-1
+	pages := make([]*ebiten.Image, len(description.Pages))
+	for _, page := range description.Pages {
+		pageImage, err := loadImage(filepath.Join(filepath.Dir(fileName), page.File))
+		if err != nil {
+			return BitmapFont{}, fmt.Errorf("%s: %w", fileName, err)
+		}
+		pages[page.ID] = ebiten.NewImageFromImage(pageImage)
+	}
+	glyphs, kerning := description.glyphs()
+	return NewGlyphFont(pages, glyphs, kerning, description.Common.LineHeight), nil
+}
+
+// parseAngelCodeFont reads the text or the XML format of a BMFont description.
+func parseAngelCodeFont(data []byte) (angelCodeFont, error) {
+	var description angelCodeFont
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '<' {
+		if err := xml.Unmarshal(trimmed, &description); err != nil {
+			return description, err
+		}
+		return description, description.validate()
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		tag, values, err := parseAngelCodeLine(scanner.Text())
+		if err != nil {
+			return description, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		switch tag {
+		case "common":
+			description.Common.LineHeight = values.int("lineHeight")
+		case "page":
+			description.Pages = append(description.Pages, angelCodePage{ID: values.int("id"), File: values["file"]})
+		case "char":
+			description.Chars = append(description.Chars, angelCodeChar{
+				ID:       values.int("id"),
+				X:        values.int("x"),
+				Y:        values.int("y"),
+				Width:    values.int("width"),
+				Height:   values.int("height"),
+				XOffset:  values.int("xoffset"),
+				YOffset:  values.int("yoffset"),
+				XAdvance: values.int("xadvance"),
+				Page:     values.int("page"),
+			})
+		case "kerning":
+			description.Kernings = append(description.Kernings, angelCodeKerning{First: values.int("first"), Second: values.int("second"), Amount: values.int("amount")})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return description, err
+	}
+	return description, description.validate()
+}
+
+// validate checks that the pages are numbered from 0 without gaps and that every char is on one of them.
+func (d angelCodeFont) validate() error {
+	if len(d.Pages) == 0 {
+		return fmt.Errorf("the font has no page")
+	}
+	isPageSeen := make([]bool, len(d.Pages))
+	for _, page := range d.Pages {
+		if page.ID < 0 || page.ID >= len(d.Pages) || isPageSeen[page.ID] {
+			return fmt.Errorf("invalid page id %d, the %d pages need the ids 0 to %d", page.ID, len(d.Pages), len(d.Pages)-1)
+		}
+		if page.File == "" {
+			return fmt.Errorf("page %d has no file", page.ID)
+		}
+		isPageSeen[page.ID] = true
+	}
+	for _, char := range d.Chars {
+		if char.Page < 0 || char.Page >= len(d.Pages) {
+			return fmt.Errorf("char %d is on page %d, which does not exist", char.ID, char.Page)
+		}
+	}
+	return nil
+}
+
+func (d angelCodeFont) glyphs() (map[rune]Glyph, map[KerningPair]int) {
+	glyphs := make(map[rune]Glyph, len(d.Chars))
+	for _, char := range d.Chars {
+		glyphs[rune(char.ID)] = Glyph{
+			Page:    char.Page,
+			Rect:    image.Rect(char.X, char.Y, char.X+char.Width, char.Y+char.Height),
+			Offset:  geometry.Point{X: char.XOffset, Y: char.YOffset},
+			Advance: char.XAdvance,
+		}
+	}
+	kerning := make(map[KerningPair]int, len(d.Kernings))
+	for _, pair := range d.Kernings {
+		kerning[KerningPair{First: rune(pair.First), Second: rune(pair.Second)}] = pair.Amount
+	}
+	return glyphs, kerning
+}
+
+type angelCodeValues map[string]string
+
+func (v angelCodeValues) int(key string) int {
+	value, _ := strconv.Atoi(v[key])
+	return value
+}
+
+// parseAngelCodeLine splits a line like `page id=0 file="font 0.png"` into the tag and its values.
+func parseAngelCodeLine(line string) (string, angelCodeValues, error) {
+	line = strings.TrimSpace(line)
+	tag, rest, _ := strings.Cut(line, " ")
+	values := angelCodeValues{}
+	for rest = strings.TrimSpace(rest); rest != ""; rest = strings.TrimSpace(rest) {
+		key, afterKey, hasValue := strings.Cut(rest, "=")
+		if !hasValue || strings.ContainsAny(key, " \t") {
+			return tag, values, fmt.Errorf("expected key=value at '%s'", rest)
+		}
+		var value string
+		if strings.HasPrefix(afterKey, `"`) {
+			closing := strings.Index(afterKey[1:], `"`)
+			if closing < 0 {
+				return tag, values, fmt.Errorf("unterminated quote in '%s'", rest)
+			}
+			value, rest = afterKey[1:closing+1], afterKey[closing+2:]
+		} else {
+			value, rest, _ = strings.Cut(afterKey, " ")
+		}
+		values[key] = value
+	}
+	return tag, values, nil
+}
+
+// LoadBDFFont loads a BDF font. The bitmaps of the glyphs are packed into one white page,
+// so the text can be tinted like any other bitmap font.
+func LoadBDFFont(fileName string) (BitmapFont, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return BitmapFont{}, err
+	}
+	defer file.Close()
+	font, err := ReadBDFFont(file)
+	if err != nil {
+		return BitmapFont{}, fmt.Errorf("%s: %w", fileName, err)
+	}
+	return font, nil
+}
+
+type bdfGlyph struct {
+	char    rune
+	size    image.Point
+	offset  image.Point // from the origin to the bottom left corner, y goes up
+	advance int
+	rows    [][]byte
+}
+
+// ReadBDFFont reads a BDF font, see LoadBDFFont.
+func ReadBDFFont(reader io.Reader) (BitmapFont, error) {
+	var glyphs []bdfGlyph
+	var current *bdfGlyph
+	var boundingBox [4]int
+	ascent, descent, defaultAdvance := -1, -1, -1
+	isBitmap := false
+
+	scanner := bufio.NewScanner(reader)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if isBitmap && fields[0] != "ENDCHAR" {
+			row, err := hex.DecodeString(fields[0])
+			if err != nil {
+				return BitmapFont{}, fmt.Errorf("line %d: invalid bitmap row '%s'", lineNumber, fields[0])
+			}
+			current.rows = append(current.rows, row)
+			continue
+		}
+		numbers := make([]int, max(len(fields)-1, 1))
+		for index, field := range fields[1:] {
+			numbers[index], _ = strconv.Atoi(field)
+		}
+		switch fields[0] {
+		case "FONTBOUNDINGBOX":
+			if len(numbers) < 4 {
+				return BitmapFont{}, fmt.Errorf("line %d: FONTBOUNDINGBOX needs 4 values", lineNumber)
+			}
+			copy(boundingBox[:], numbers)
+		case "FONT_ASCENT":
+			ascent = numbers[0]
+		case "FONT_DESCENT":
+			descent = numbers[0]
+		case "DWIDTH":
+			if current == nil {
+				defaultAdvance = numbers[0]
+			} else {
+				current.advance = numbers[0]
+			}
+		case "STARTCHAR":
+			current = &bdfGlyph{
+				char:    -1,
+				size:    image.Pt(boundingBox[0], boundingBox[1]),
+				offset:  image.Pt(boundingBox[2], boundingBox[3]),
+				advance: defaultAdvance,
+			}
+		case "ENCODING":
+			if current != nil && len(numbers) > 0 {
+				current.char = rune(numbers[0])
+			}
+		case "BBX":
+			if current == nil || len(numbers) < 4 {
+				return BitmapFont{}, fmt.Errorf("line %d: BBX needs 4 values inside of a char", lineNumber)
+			}
+			current.size = image.Pt(numbers[0], numbers[1])
+			current.offset = image.Pt(numbers[2], numbers[3])
+		case "BITMAP":
+			if current == nil {
+				return BitmapFont{}, fmt.Errorf("line %d: BITMAP outside of a char", lineNumber)
+			}
+			isBitmap = true
+		case "ENDCHAR":
+			if current != nil && current.char >= 0 {
+				glyphs = append(glyphs, *current)
+			}
+			current = nil
+			isBitmap = false
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return BitmapFont{}, err
+	}
+	if len(glyphs) == 0 {
+		return BitmapFont{}, fmt.Errorf("the font has no glyphs")
+	}
+	if ascent < 0 {
+		ascent = boundingBox[1] + boundingBox[3]
+	}
+	if descent < 0 {
+		descent = -boundingBox[3]
+	}
+	return packBDFGlyphs(glyphs, ascent, ascent+descent), nil
+}
+
+// packBDFGlyphs draws the glyphs row by row into one page.
+func packBDFGlyphs(bdfGlyphs []bdfGlyph, ascent, lineHeight int) BitmapFont {
+	const pageWidth = 512
+	var positions []image.Point
+	cursor, rowHeight := image.Point{}, 0
+	for _, glyph := range bdfGlyphs {
+		if cursor.X+glyph.size.X > pageWidth {
+			cursor = image.Pt(0, cursor.Y+rowHeight+1)
+			rowHeight = 0
+		}
+		positions = append(positions, cursor)
+		cursor.X += glyph.size.X + 1
+		rowHeight = max(rowHeight, glyph.size.Y)
+	}
+	page := image.NewRGBA(image.Rect(0, 0, pageWidth, max(1, cursor.Y+rowHeight)))
+	glyphs := make(map[rune]Glyph, len(bdfGlyphs))
+	for index, bdf := range bdfGlyphs {
+		pos := positions[index]
+		for y, row := range bdf.rows {
+			for x := 0; x < bdf.size.X && y < bdf.size.Y; x++ {
+				if x/8 < len(row) && row[x/8]&(0x80>>(x%8)) != 0 {
+					page.Set(pos.X+x, pos.Y+y, color.White)
+				}
+			}
+		}
+		advance := bdf.advance
+		if advance < 0 {
+			advance = bdf.size.X
+		}
+		glyphs[bdf.char] = Glyph{
+			Rect:    image.Rectangle{Min: pos, Max: pos.Add(bdf.size)},
+			Offset:  geometry.Point{X: bdf.offset.X, Y: ascent - bdf.offset.Y - bdf.size.Y},
+			Advance: advance,
+		}
+	}
+	return NewGlyphFont([]*ebiten.Image{ebiten.NewImageFromImage(page)}, glyphs, nil, lineHeight)
+}
+
+func loadImage(fileName string) (image.Image, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	img, _, err := image.Decode(file)
+	return img, err
+}
@@ -0,0 +1,188 @@
+package renderer
+
+import (
+	"ReMapper/geometry"
+	"image"
+	"strings"
+	"testing"
+)
+
+func TestParseAngelCodeLine(t *testing.T) {
+	tag, values, err := parseAngelCodeLine(`  page id=1 file="my font_1.png" `)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag != "page" || values["id"] != "1" || values["file"] != "my font_1.png" {
+		t.Errorf("parsed %q %v", tag, values)
+	}
+	tag, values, err = parseAngelCodeLine(`info face="" size=16 bold=0`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag != "info" || values["face"] != "" || values.int("size") != 16 || len(values) != 3 {
+		t.Errorf("parsed %q %v", tag, values)
+	}
+	for _, line := range []string{
+		`page id=0 file="font.png`,
+		`char id=65 x`,
+		`char id=65 bad token=1`,
+	} {
+		if _, _, err := parseAngelCodeLine(line); err == nil {
+			t.Errorf("parseAngelCodeLine(%q) returned no error", line)
+		}
+	}
+}
+
+const textFont = `info face="Test" size=8
+common lineHeight=10 base=8 pages=2
+page id=0 file="font_0.png"
+page id=1 file="font_1.png"
+chars count=2
+char id=65 x=1 y=2 width=5 height=7 xoffset=0 yoffset=1 xadvance=6 page=0
+char id=66 x=8 y=2 width=5 height=7 xoffset=1 yoffset=1 xadvance=7 page=1
+kernings count=1
+kerning first=65 second=66 amount=-1
+`
+
+const xmlFont = `<?xml version="1.0"?>
+<font>
+  <common lineHeight="10" base="8" pages="2"/>
+  <pages>
+    <page id="0" file="font_0.png"/>
+    <page id="1" file="font_1.png"/>
+  </pages>
+  <chars count="2">
+    <char id="65" x="1" y="2" width="5" height="7" xoffset="0" yoffset="1" xadvance="6" page="0"/>
+    <char id="66" x="8" y="2" width="5" height="7" xoffset="1" yoffset="1" xadvance="7" page="1"/>
+  </chars>
+  <kernings count="1">
+    <kerning first="65" second="66" amount="-1"/>
+  </kernings>
+</font>
+`
+
+func TestParseAngelCodeFont(t *testing.T) {
+	for name, data := range map[string]string{"text": textFont, "xml": xmlFont} {
+		description, err := parseAngelCodeFont([]byte(data))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if description.Common.LineHeight != 10 {
+			t.Errorf("%s: line height %d, want 10", name, description.Common.LineHeight)
+		}
+		if len(description.Pages) != 2 || description.Pages[1].ID != 1 || description.Pages[1].File != "font_1.png" {
+			t.Errorf("%s: pages %v", name, description.Pages)
+		}
+		glyphs, kerning := description.glyphs()
+		want := Glyph{Page: 1, Rect: image.Rect(8, 2, 13, 9), Offset: geometry.Point{X: 1, Y: 1}, Advance: 7}
+		if len(glyphs) != 2 || glyphs['B'] != want {
+			t.Errorf("%s: glyph B is %v, want %v", name, glyphs['B'], want)
+		}
+		if amount := kerning[KerningPair{First: 'A', Second: 'B'}]; len(kerning) != 1 || amount != -1 {
+			t.Errorf("%s: kerning %v", name, kerning)
+		}
+	}
+}
+
+func TestParseAngelCodeFontErrors(t *testing.T) {
+	tests := map[string]string{
+		"no page":        "common lineHeight=10\n",
+		"page id gap":    "page id=0 file=\"a.png\"\npage id=2 file=\"b.png\"\n",
+		"duplicate page": "page id=0 file=\"a.png\"\npage id=0 file=\"b.png\"\n",
+		"page no file":   "page id=0\n",
+		"char on no page": "page id=0 file=\"a.png\"\n" +
+			"char id=65 x=0 y=0 width=5 height=7 xadvance=6 page=1\n",
+		"bad line":        "page id=0 file=\"a.png\n",
+		"xml page id gap": `<font><pages><page id="1" file="a.png"/></pages></font>`,
+	}
+	for name, data := range tests {
+		if _, err := parseAngelCodeFont([]byte(data)); err == nil {
+			t.Errorf("%s: returned no error", name)
+		}
+	}
+}
+
+const bdfFont = `STARTFONT 2.1
+FONT -test-fixed-medium-r-normal--8-80-75-75-c-60-iso10646-1
+SIZE 8 75 75
+FONTBOUNDINGBOX 6 8 0 -2
+STARTPROPERTIES 2
+FONT_ASCENT 6
+FONT_DESCENT 2
+ENDPROPERTIES
+CHARS 2
+STARTCHAR A
+ENCODING 65
+SWIDTH 750 0
+DWIDTH 6 0
+BBX 5 6 0 0
+BITMAP
+20
+50
+88
+F8
+88
+88
+ENDCHAR
+STARTCHAR g
+ENCODING 103
+SWIDTH 625 0
+DWIDTH 5 0
+BBX 4 5 1 -2
+BITMAP
+70
+90
+70
+10
+E0
+ENDCHAR
+ENDFONT
+`
+
+func TestReadBDFFont(t *testing.T) {
+	font, err := ReadBDFFont(strings.NewReader(bdfFont))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if font.lineHeight != 8 {
+		t.Errorf("line height %d, want 8", font.lineHeight)
+	}
+	tests := []struct {
+		char    rune
+		size    image.Point
+		offset  geometry.Point
+		advance int
+	}{
+		// sits on the baseline: 6 ascent - 0 offset - 6 height
+		{'A', image.Pt(5, 6), geometry.Point{X: 0, Y: 0}, 6},
+		// reaches 2 pixels below the baseline: 6 ascent - (-2) offset - 5 height
+		{'g', image.Pt(4, 5), geometry.Point{X: 1, Y: 3}, 5},
+	}
+	for _, test := range tests {
+		glyph, ok := font.glyphs[test.char]
+		if !ok {
+			t.Errorf("%c has no glyph", test.char)
+			continue
+		}
+		if glyph.Rect.Size() != test.size || glyph.Offset != test.offset || glyph.Advance != test.advance {
+			t.Errorf("%c: glyph %v, want size %v, offset %v, advance %d", test.char, glyph, test.size, test.offset, test.advance)
+		}
+	}
+	if font.glyphs['A'].Rect.Overlaps(font.glyphs['g'].Rect) {
+		t.Errorf("glyph rects overlap: %v %v", font.glyphs['A'].Rect, font.glyphs['g'].Rect)
+	}
+}
+
+func TestReadBDFFontErrors(t *testing.T) {
+	tests := map[string]string{
+		"no glyphs":       "STARTFONT 2.1\nFONTBOUNDINGBOX 6 8 0 -2\nENDFONT\n",
+		"bad bitmap row":  "STARTCHAR A\nENCODING 65\nBBX 1 1 0 0\nBITMAP\nZZ\nENDCHAR\n",
+		"short BBX":       "STARTCHAR A\nENCODING 65\nBBX 1 1\nENDCHAR\n",
+		"bitmap not char": "BITMAP\n",
+	}
+	for name, data := range tests {
+		if _, err := ReadBDFFont(strings.NewReader(data)); err == nil {
+			t.Errorf("%s: returned no error", name)
+		}
+	}
+}
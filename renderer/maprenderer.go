@@ -66,7 +66,7 @@ func (r *MapRenderer) Draw(tick uint64) {
             y := float64(drawOffsetFromEdgeY) + float64(yStep)*float64(scaledTileSizeY)
             drawInfos := r.mapWindow.GetTextureIndexAt(firstTileX+xStep, firstTileY+yStep, tick)
             for _, drawInfo := range drawInfos {
-                r.gridRenderer.DrawDefaultScaleTile(x, y, drawInfo.Atlas, drawInfo.Icon, drawInfo.Color)
+                r.gridRenderer.DrawDefaultScaleTileWithOrientation(x, y, drawInfo.Atlas, drawInfo.Icon, drawInfo.Color, drawInfo.Orientation)
             }
         }
     }
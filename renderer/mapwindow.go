@@ -47,8 +47,24 @@ func (m *MapWindow) GetGridSize() geometry.Point {
     return m.gridSize
 }
 
+// GetTextureIndexAt returns the layers of a cell, the icons of animated layers are set to the frame shown at tick.
 func (m *MapWindow) GetTextureIndexAt(cellX, cellY int, tick uint64) []CellDrawInfo {
-    return m.lookup(cellX, cellY, tick)
+    drawInfos := m.lookup(cellX, cellY, tick)
+    var animated []CellDrawInfo
+    for index, drawInfo := range drawInfos {
+        if len(drawInfo.Frames) == 0 {
+            continue
+        }
+        if animated == nil {
+            // don't change the slice of the lookup
+            animated = append([]CellDrawInfo(nil), drawInfos...)
+        }
+        animated[index].Icon = drawInfo.IconAt(tick)
+    }
+    if animated != nil {
+        return animated
+    }
+    return drawInfos
 }
 
 func (m *MapWindow) GetScrollOffset() geometry.Point {
@@ -81,6 +97,8 @@ func (m *MapWindow) setScrollOffset(newScrollX, newScrollY int) {
         } else if newScrollX < 0 {
             newScrollX = 0
         }
+    } else {
+        newScrollX = 0
     }
 
     if shouldScrollVertically {
@@ -89,6 +107,8 @@ func (m *MapWindow) setScrollOffset(newScrollX, newScrollY int) {
         } else if newScrollY < 0 {
             newScrollY = 0
         }
+    } else {
+        newScrollY = 0
     }
 
     m.scrollOffset = geometry.Point{X: newScrollX, Y: newScrollY}
@@ -0,0 +1,191 @@
+package renderer
+
+import (
+    "ReMapper/recfile"
+    "bufio"
+    "fmt"
+    "image/color"
+    "io"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+)
+
+// Palette is a list of named colors. Names are not case-sensitive.
+type Palette struct {
+    names  []string
+    colors map[string]color.RGBA
+    base   *Palette // looked up for the names the palette doesn't have, see Over
+}
+
+// builtinColors are the 16 HTML 4 colors, in the order of the classic 16 color palette.
+var builtinColors = []struct {
+    name  string
+    color color.RGBA
+}{
+    {"black", color.RGBA{R: 0, G: 0, B: 0, A: 255}},
+    {"maroon", color.RGBA{R: 128, G: 0, B: 0, A: 255}},
+    {"green", color.RGBA{R: 0, G: 128, B: 0, A: 255}},
+    {"olive", color.RGBA{R: 128, G: 128, B: 0, A: 255}},
+    {"navy", color.RGBA{R: 0, G: 0, B: 128, A: 255}},
+    {"purple", color.RGBA{R: 128, G: 0, B: 128, A: 255}},
+    {"teal", color.RGBA{R: 0, G: 128, B: 128, A: 255}},
+    {"silver", color.RGBA{R: 192, G: 192, B: 192, A: 255}},
+    {"gray", color.RGBA{R: 128, G: 128, B: 128, A: 255}},
+    {"red", color.RGBA{R: 255, G: 0, B: 0, A: 255}},
+    {"lime", color.RGBA{R: 0, G: 255, B: 0, A: 255}},
+    {"yellow", color.RGBA{R: 255, G: 255, B: 0, A: 255}},
+    {"blue", color.RGBA{R: 0, G: 0, B: 255, A: 255}},
+    {"fuchsia", color.RGBA{R: 255, G: 0, B: 255, A: 255}},
+    {"aqua", color.RGBA{R: 0, G: 255, B: 255, A: 255}},
+    {"white", color.RGBA{R: 255, G: 255, B: 255, A: 255}},
+}
+
+// builtinAliases are other common names of the built-in colors.
+var builtinAliases = map[string]string{
+    "grey":    "gray",
+    "magenta": "fuchsia",
+    "cyan":    "aqua",
+}
+
+func NewPalette() *Palette {
+    return &Palette{colors: map[string]color.RGBA{}}
+}
+
+// DefaultPalette returns the built-in colors, TileRenderer uses them for color codes like [:red].
+func DefaultPalette() *Palette {
+    palette := NewPalette()
+    for _, builtin := range builtinColors {
+        palette.Set(builtin.name, builtin.color)
+    }
+    for alias, name := range builtinAliases {
+        palette.colors[alias] = palette.colors[name]
+    }
+    return palette
+}
+
+// Set adds a color or replaces the color of an existing name.
+func (p *Palette) Set(name string, c color.RGBA) {
+    key := strings.ToLower(strings.TrimSpace(name))
+    if _, exists := p.colors[key]; !exists {
+        p.names = append(p.names, key)
+    }
+    p.colors[key] = c
+}
+
+// Over returns a palette that looks names up in p first and in base after that.
+// Names and Colors are the ones of p, so a loaded palette keeps the built-in names working
+// without showing them as its own colors.
+func (p *Palette) Over(base *Palette) *Palette {
+    return &Palette{names: p.names, colors: p.colors, base: base}
+}
+
+// Color returns the color of a name.
+func (p *Palette) Color(name string) (color.RGBA, bool) {
+    c, ok := p.colors[strings.ToLower(strings.TrimSpace(name))]
+    if !ok && p.base != nil {
+        return p.base.Color(name)
+    }
+    return c, ok
+}
+
+// Resolve reads a color name of the palette, or hex and r,g,b like ParseColor.
+func (p *Palette) Resolve(text string) (color.RGBA, bool) {
+    if c, ok := p.Color(text); ok {
+        return c, true
+    }
+    return ParseColor(text)
+}
+
+// ColorFromName can be passed to TileRenderer.SetColorFromName, unknown names are white.
+func (p *Palette) ColorFromName(name string) color.Color {
+    if c, ok := p.Color(name); ok {
+        return c
+    }
+    return color.White
+}
+
+// Names returns the names in the order they were added, without the aliases of the built-in colors.
+func (p *Palette) Names() []string {
+    return p.names
+}
+
+// Colors returns the colors in the order of Names.
+func (p *Palette) Colors() []color.RGBA {
+    colors := make([]color.RGBA, len(p.names))
+    for index, name := range p.names {
+        colors[index] = p.colors[name]
+    }
+    return colors
+}
+
+// LoadPalette reads a GIMP palette if the file name ends with .gpl and a rec palette otherwise.
+func LoadPalette(fileName string) (*Palette, error) {
+    file, err := os.Open(fileName)
+    if err != nil {
+        return nil, err
+    }
+    defer file.Close()
+    var palette *Palette
+    if strings.EqualFold(filepath.Ext(fileName), ".gpl") {
+        palette, err = ReadGPLPalette(file)
+    } else {
+        palette, err = ReadRecPalette(file)
+    }
+    if err != nil {
+        return nil, fmt.Errorf("%s: %w", fileName, err)
+    }
+    return palette, nil
+}
+
+// ReadRecPalette reads one color per record:
+//
+//	name: grass
+//	color: #3c8a2e
+func ReadRecPalette(reader io.Reader) (*Palette, error) {
+    palette := NewPalette()
+    for index, record := range recfile.Read(reader) {
+        name := record.FindFirstFieldValue("name")
+        value := record.FindFirstFieldValue("color")
+        c, isValid := ParseColor(value)
+        if name == "" || !isValid {
+            return nil, fmt.Errorf("record %d needs a name and a color like #ff8000 or 255,128,0", index+1)
+        }
+        palette.Set(name, c)
+    }
+    return palette, nil
+}
+
+// ReadGPLPalette reads a GIMP palette. Colors without a name, or named "Untitled", are called color1, color2, ...
+func ReadGPLPalette(reader io.Reader) (*Palette, error) {
+    palette := NewPalette()
+    scanner := bufio.NewScanner(reader)
+    if !scanner.Scan() || strings.TrimSpace(scanner.Text()) != "GIMP Palette" {
+        return nil, fmt.Errorf("not a GIMP palette, the first line must be 'GIMP Palette'")
+    }
+    for lineNumber := 2; scanner.Scan(); lineNumber++ {
+        line := strings.TrimSpace(scanner.Text())
+        if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "Name:") || strings.HasPrefix(line, "Columns:") {
+            continue
+        }
+        fields := strings.Fields(line)
+        if len(fields) < 3 {
+            return nil, fmt.Errorf("line %d: expected red green blue and a name", lineNumber)
+        }
+        var channels [3]uint8
+        for channel := range channels {
+            value, err := strconv.ParseUint(fields[channel], 10, 8)
+            if err != nil {
+                return nil, fmt.Errorf("line %d: invalid channel '%s'", lineNumber, fields[channel])
+            }
+            channels[channel] = uint8(value)
+        }
+        name := strings.Join(fields[3:], " ")
+        if name == "" || strings.EqualFold(name, "Untitled") {
+            name = fmt.Sprintf("color%d", len(palette.names)+1)
+        }
+        palette.Set(name, color.RGBA{R: channels[0], G: channels[1], B: channels[2], A: 255})
+    }
+    return palette, scanner.Err()
+}
@@ -0,0 +1,42 @@
+package renderer
+
+import (
+    "image/color"
+    "slices"
+    "strings"
+    "testing"
+)
+
+func TestPaletteOver(t *testing.T) {
+    loaded, err := ReadGPLPalette(strings.NewReader("GIMP Palette\nName: test\n60 138 46 grass\n200 20 20 red\n"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    palette := loaded.Over(DefaultPalette())
+    tests := []struct {
+        name string
+        want color.RGBA
+    }{
+        {"grass", color.RGBA{R: 60, G: 138, B: 46, A: 255}},
+        {"red", color.RGBA{R: 200, G: 20, B: 20, A: 255}},  // redefined by the palette
+        {"Navy", color.RGBA{R: 0, G: 0, B: 128, A: 255}},   // built-in
+        {"cyan", color.RGBA{R: 0, G: 255, B: 255, A: 255}}, // built-in alias
+    }
+    for _, test := range tests {
+        if got, ok := palette.Color(test.name); !ok || got != test.want {
+            t.Errorf("Color(%q) = %v, %t, want %v", test.name, got, ok, test.want)
+        }
+        if got := palette.ColorFromName(test.name); got != test.want {
+            t.Errorf("ColorFromName(%q) = %v, want %v", test.name, got, test.want)
+        }
+    }
+    if _, ok := palette.Color("sky"); ok {
+        t.Errorf("Color(\"sky\") found a color that no palette has")
+    }
+    if names := palette.Names(); !slices.Equal(names, []string{"grass", "red"}) {
+        t.Errorf("Names() = %v, want only the loaded names", names)
+    }
+    if _, ok := loaded.Color("navy"); ok {
+        t.Errorf("the loaded palette itself has the built-in names")
+    }
+}
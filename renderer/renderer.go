@@ -8,8 +8,8 @@ import (
 	"golang.org/x/image/font"
 	"image"
 	"image/color"
+	"math"
 	"regexp"
-	"strconv"
 	"strings"
 )
 
@@ -72,6 +72,8 @@ func NewTileRenderer(deviceScaleFunc func() float64, tileScaleFunc func() float6
         tileScale:   tileScaleFunc,
         deviceScale: deviceScaleFunc,
         fontScale:   1,
+        // color codes like [:red] work without SetColorFromName
+        colorFromName: DefaultPalette().ColorFromName,
         globalScaleColor: [4]float32{
             1, 1, 1, 1,
         },
@@ -206,15 +208,26 @@ func (g *TileRenderer) DrawColoredRect(topLeftScreen geometry.Point, size geomet
     g.currentRenderTarget.DrawImage(ExtractSubImageFromAtlas(g.whiteTile, g.defaultAtlas), g.op)
 }
 
+// DrawFilledRect fills a rectangle without needing a white tile in the atlas.
+func (g *TileRenderer) DrawFilledRect(topLeftScreen geometry.Point, size geometry.Point, fillColor color.Color) {
+    scale := float32(g.deviceScale())
+    vector.DrawFilledRect(g.currentRenderTarget, float32(topLeftScreen.X)*scale, float32(topLeftScreen.Y)*scale, float32(size.X)*scale, float32(size.Y)*scale, fillColor, false)
+}
+
+func (g *TileRenderer) DrawRectOutline(topLeftScreen geometry.Point, size geometry.Point, strokeWidth float64, strokeColor color.Color) {
+    scale := float32(g.deviceScale())
+    vector.StrokeRect(g.currentRenderTarget, float32(topLeftScreen.X)*scale, float32(topLeftScreen.Y)*scale, float32(size.X)*scale, float32(size.Y)*scale, float32(strokeWidth)*scale, strokeColor, false)
+}
+
 func (g *TileRenderer) DrawStringOnGrid(gridX int, gridY int, text string, color color.Color) {
-    tileSize := g.font.atlas.GetTileSize()
+    tileSize := g.font.CellSize()
     drawX := float64(gridX) * float64(tileSize.X) * g.deviceScale() * g.fontScale
     drawY := float64(gridY) * float64(tileSize.Y) * g.deviceScale() * g.fontScale
     g.DrawString(int(drawX), int(drawY), text, color)
 }
 
 func (g *TileRenderer) DrawCharOnGrid(gridX int, gridY int, icon rune, color color.Color) {
-    tileSize := g.font.atlas.GetTileSize()
+    tileSize := g.font.CellSize()
     drawX := float64(gridX) * float64(tileSize.X) * g.deviceScale() * g.fontScale
     drawY := float64(gridY) * float64(tileSize.Y) * g.deviceScale() * g.fontScale
     g.DrawDefaultScaleCharOnScreen(drawX, drawY, icon, color)
@@ -228,7 +241,7 @@ func (g *TileRenderer) DrawOnGrid(gridX int, gridY int, icon int32) {
 }
 
 func (g *TileRenderer) DrawOnFontGrid(gridX int, gridY int, icon int32) {
-    tileSize := g.font.atlas.GetTileSize()
+    tileSize := g.font.CellSize()
     drawX := float64(gridX) * float64(tileSize.X) * g.deviceScale() * g.fontScale
     drawY := float64(gridY) * float64(tileSize.Y) * g.deviceScale() * g.fontScale
     g.DrawTileWithDefaultOrientation(drawX, drawY, g.defaultAtlas, icon, geometry.PointF{X: g.fontScale, Y: g.fontScale}, color.White)
@@ -264,6 +277,15 @@ func (g *TileRenderer) SetGlobalScaleColor(color [4]float32) {
     g.globalScaleColor = color
 }
 func (g *TileRenderer) DrawTile(screenX float64, screenY float64, atlas TextureAtlas, index int32, scale geometry.PointF, tintColor color.Color, flipX bool) {
+    g.DrawTileWithOrientation(screenX, screenY, atlas, index, scale, tintColor, TileOrientation{FlipX: flipX})
+}
+
+func (g *TileRenderer) DrawDefaultScaleTileWithOrientation(screenX float64, screenY float64, atlas TextureAtlas, index int32, tintColor color.Color, orientation TileOrientation) {
+    g.DrawTileWithOrientation(screenX, screenY, atlas, index, geometry.PointF{X: g.tileScale(), Y: g.tileScale()}, tintColor, orientation)
+}
+
+// DrawTileWithOrientation draws a tile mirrored and turned around the center of its cell.
+func (g *TileRenderer) DrawTileWithOrientation(screenX float64, screenY float64, atlas TextureAtlas, index int32, scale geometry.PointF, tintColor color.Color, orientation TileOrientation) {
     g.op.ColorScale.Reset()
     //g.op.ColorScale.SetR()
 
@@ -287,13 +309,27 @@ func (g *TileRenderer) DrawTile(screenX float64, screenY float64, atlas TextureA
     g.op.GeoM.Reset()
     tileScale := scale.Mul(g.deviceScale())
     tx := screenX * g.deviceScale()
-    if flipX {
-        g.op.GeoM.Scale(-tileScale.X, tileScale.Y)
-        tx += float64(atlas.tileSizeX) * tileScale.X
+    ty := screenY * g.deviceScale()
+    if orientation.IsDefault() {
+        g.op.GeoM.Scale(tileScale.X, tileScale.Y)
     } else {
+        // mirror and turn around the center of the tile
+        halfWidth, halfHeight := float64(atlas.tileSizeX)/2, float64(atlas.tileSizeY)/2
+        g.op.GeoM.Translate(-halfWidth, -halfHeight)
+        mirrorX, mirrorY := 1.0, 1.0
+        if orientation.FlipX {
+            mirrorX = -1
+        }
+        if orientation.FlipY {
+            mirrorY = -1
+        }
+        g.op.GeoM.Scale(mirrorX, mirrorY)
+        g.op.GeoM.Rotate(float64(orientation.Rotation) * math.Pi / 180)
         g.op.GeoM.Scale(tileScale.X, tileScale.Y)
+        tx += halfWidth * tileScale.X
+        ty += halfHeight * tileScale.Y
     }
-    g.op.GeoM.Translate(tx, screenY*g.deviceScale())
+    g.op.GeoM.Translate(tx, ty)
     g.currentRenderTarget.DrawImage(ExtractSubImageFromAtlas(index, atlas), g.op)
 }
 func (g *TileRenderer) DrawDefaultScaleCharOnScreen(screenX, screenY float64, char rune, textColor color.Color) {
@@ -333,7 +369,7 @@ func (g *TileRenderer) DrawCharOnScreen(screenX, screenY int, char rune, scale f
         println("font not loaded")
         return
     }
-    textureIndex, ok := g.font.fontMap[char]
+    glyphImage, glyph, ok := g.font.glyphOf(char)
     if !ok {
         return
     }
@@ -346,32 +382,45 @@ func (g *TileRenderer) DrawCharOnScreen(screenX, screenY int, char rune, scale f
     g.op.ColorScale.ScaleWithColor(textColor)
     g.op.GeoM.Reset()
     g.op.GeoM.Scale(fontScale, fontScale)
-    g.op.GeoM.Translate(float64(screenX), float64(screenY))
-    g.currentRenderTarget.DrawImage(ExtractSubImageFromAtlas(int32(textureIndex), g.font.atlas), g.op)
+    g.op.GeoM.Translate(float64(screenX)+float64(glyph.Offset.X)*fontScale, float64(screenY)+float64(glyph.Offset.Y)*fontScale)
+    g.currentRenderTarget.DrawImage(glyphImage, g.op)
 }
 
+// DrawString draws a line in the bitmap font, the position is in device pixels.
+// The runes are placed by their advances and the kerning of the font.
 func (g *TileRenderer) DrawString(screenX int, screenY int, text string, color color.Color) {
-    tileSizeX := float64(g.font.atlas.tileSizeX) * g.fontScale * g.deviceScale()
-
-    for i, char := range text {
-        g.DrawDefaultScaleCharOnScreen(float64(screenX)+float64(i)*tileSizeX, float64(screenY), char, color)
+    scale := g.fontScale * g.deviceScale()
+    drawX := float64(screenX)
+    previous := rune(-1)
+    for _, char := range text {
+        drawX += float64(g.font.Kerning(previous, char)) * scale
+        g.DrawDefaultScaleCharOnScreen(drawX, float64(screenY), char, color)
+        drawX += float64(g.font.Advance(char)) * scale
+        previous = char
     }
 }
 
 func (g *TileRenderer) DrawMultiString(screenX int, screenY int, text []string, color color.Color) {
-    tileSizeY := float64(g.font.atlas.tileSizeY) * g.fontScale * g.deviceScale()
+    lineHeight := float64(g.font.LineHeight()) * g.fontScale * g.deviceScale()
 
     for i, line := range text {
-        g.DrawString(screenX, screenY+int(float64(i)*tileSizeY), line, color)
+        g.DrawString(screenX, screenY+int(float64(i)*lineHeight), line, color)
     }
 }
 
+// MeasureBitmapString is MeasureString for the bitmap font: the size of the text in DrawString
+// with the font scale, without the device scale.
+func (g *TileRenderer) MeasureBitmapString(textToMeasure string) (float64, float64) {
+    size := g.font.MeasureString(textToMeasure)
+    return float64(size.X) * g.fontScale, float64(size.Y) * g.fontScale
+}
+
 func (g *TileRenderer) GetFontScale() float64 {
     return g.fontScale
 }
 
 func (g *TileRenderer) GetFontGridSize() geometry.Point {
-    return g.font.atlas.GetTileSize()
+    return g.font.CellSize()
 }
 
 func (g *TileRenderer) parseColorCodedText(draw string) []ColoredTextPart {
@@ -416,11 +465,11 @@ func (g *TileRenderer) toColor(matches [][]string, index int) color.Color {
     }
     colorName := matches[index][1]
     if strings.ContainsRune(colorName, ',') {
-        colorValues := strings.Split(colorName, ",")
-        rVal, _ := strconv.Atoi(colorValues[0])
-        gVal, _ := strconv.Atoi(colorValues[1])
-        bVal, _ := strconv.Atoi(colorValues[2])
-        return color.RGBA{R: uint8(rVal), G: uint8(gVal), B: uint8(bVal), A: 255}
+        parsedColor, _ := ParseColor(colorName)
+        return parsedColor
+    }
+    if g.colorFromName == nil {
+        return DefaultPalette().ColorFromName(colorName)
     }
     return g.colorFromName(colorName)
 }
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"ReMapper/geometry"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// matchFilter matches the filter text against a label, ignoring case.
+// A substring match is preferred, otherwise all characters of the filter have
+// to appear in order (fuzzy match). The returned positions are rune indices of
+// the matched characters in the label.
+func matchFilter(filterText, label string) (positions []int, isSubstring bool, ok bool) {
+	if filterText == "" {
+		return nil, true, true
+	}
+	lowerLabel := []rune(strings.ToLower(label))
+	lowerFilter := []rune(strings.ToLower(filterText))
+
+	if start := indexRunes(lowerLabel, lowerFilter); start >= 0 {
+		for i := range lowerFilter {
+			positions = append(positions, start+i)
+		}
+		return positions, true, true
+	}
+
+	filterIndex := 0
+	for labelIndex, char := range lowerLabel {
+		if filterIndex < len(lowerFilter) && char == lowerFilter[filterIndex] {
+			positions = append(positions, labelIndex)
+			filterIndex++
+		}
+	}
+	if filterIndex < len(lowerFilter) {
+		return nil, false, false
+	}
+	return positions, false, true
+}
+
+func indexRunes(haystack, needle []rune) int {
+	for start := 0; start+len(needle) <= len(haystack); start++ {
+		matches := true
+		for i, char := range needle {
+			if haystack[start+i] != char {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return start
+		}
+	}
+	return -1
+}
+
+// textRun is a part of a label that is either completely highlighted or not.
+type textRun struct {
+	Text        string
+	Highlighted bool
+}
+
+func splitIntoRuns(label string, highlightedPositions []int) []textRun {
+	if len(highlightedPositions) == 0 {
+		return []textRun{{Text: label}}
+	}
+	isHighlighted := make(map[int]bool, len(highlightedPositions))
+	for _, position := range highlightedPositions {
+		isHighlighted[position] = true
+	}
+	var runs []textRun
+	var current strings.Builder
+	currentHighlighted := false
+	runeIndex := 0
+	for _, char := range label {
+		if isHighlighted[runeIndex] != currentHighlighted && current.Len() > 0 {
+			runs = append(runs, textRun{Text: current.String(), Highlighted: currentHighlighted})
+			current.Reset()
+		}
+		currentHighlighted = isHighlighted[runeIndex]
+		current.WriteRune(char)
+		runeIndex++
+	}
+	if current.Len() > 0 {
+		runs = append(runs, textRun{Text: current.String(), Highlighted: currentHighlighted})
+	}
+	return runs
+}
+
+// applyFilter rebuilds the visible part of the list from the search text.
+// Substring matches are listed before fuzzy matches, each group keeps the order of orderedKeys.
+// In the tree view the matches are then sorted into their groups.
+func (e *Engine) applyFilter() {
+	var substringKeys, fuzzyKeys []string
+	var substringMatches, fuzzyMatches [][]int
+	for _, key := range e.orderedKeys {
+		positions, isSubstring, ok := matchFilter(e.searchText, e.displayLabels[key])
+		if !ok {
+			continue
+		}
+		if isSubstring {
+			substringKeys = append(substringKeys, key)
+			substringMatches = append(substringMatches, positions)
+		} else {
+			fuzzyKeys = append(fuzzyKeys, key)
+			fuzzyMatches = append(fuzzyMatches, positions)
+		}
+	}
+	e.visibleKeys = append(substringKeys, fuzzyKeys...)
+	e.visibleMatches = append(substringMatches, fuzzyMatches...)
+	e.sortIntoGroups()
+	e.buildListRows()
+
+	e.selectedListIndex = -1
+	for index, key := range e.visibleKeys {
+		if key == e.selectedKey {
+			e.selectedListIndex = index
+			break
+		}
+	}
+	e.listPane.scroll = geometry.PointF{}
+	e.rebuildGrid()
+	e.updateElementBounds()
+}
+
+func (e *Engine) setSearchText(text string) {
+	if text == e.searchText {
+		return
+	}
+	e.searchText = text
+	e.applyFilter()
+}
+
+func (e *Engine) appendSearchChars(chars []rune) {
+	var printable []rune
+	for _, char := range chars {
+		if unicode.IsPrint(char) {
+			printable = append(printable, char)
+		}
+	}
+	if len(printable) == 0 {
+		return
+	}
+	e.setSearchText(e.searchText + string(printable))
+}
+
+func (e *Engine) deleteLastSearchChar() {
+	if e.searchText == "" {
+		return
+	}
+	_, lastSize := utf8.DecodeLastRuneInString(e.searchText)
+	e.setSearchText(e.searchText[:len(e.searchText)-lastSize])
+}
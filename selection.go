@@ -0,0 +1,235 @@
+package main
+
+import (
+	"ReMapper/geometry"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// clickListIndex selects an entry with the mouse. Ctrl toggles the entry,
+// Shift selects the range from the last clicked entry.
+func (e *Engine) clickListIndex(index int) {
+	isShiftPressed := ebiten.IsKeyPressed(ebiten.KeyShift)
+	switch {
+	case isShiftPressed && e.selectionAnchor != "":
+		e.selectRangeTo(index, isControlPressed())
+	case isControlPressed():
+		e.toggleListIndex(index)
+	default:
+		e.selectListIndex(index)
+	}
+}
+
+func (e *Engine) toggleListIndex(index int) {
+	key := e.visibleKeys[index]
+	e.selectionAnchor = key
+	if e.selectedKeys[key] && len(e.selectedKeys) > 1 {
+		delete(e.selectedKeys, key)
+		if key == e.selectedKey {
+			// make another selected entry the primary one
+			for _, otherIndex := range e.selectedVisibleIndices() {
+				e.setPrimarySelection(otherIndex)
+				break
+			}
+		}
+		return
+	}
+	e.selectedKeys[key] = true
+	e.setPrimarySelection(index)
+}
+
+// selectRangeTo selects all visible entries between the anchor and index.
+// If keepOthers is false, the range replaces the current selection.
+func (e *Engine) selectRangeTo(index int, keepOthers bool) {
+	anchorIndex := e.visibleIndexOf(e.selectionAnchor)
+	if anchorIndex < 0 {
+		e.selectListIndex(index)
+		return
+	}
+	if !keepOthers {
+		e.selectedKeys = make(map[string]bool)
+	}
+	from, to := min(anchorIndex, index), max(anchorIndex, index)
+	for i := from; i <= to; i++ {
+		e.selectedKeys[e.visibleKeys[i]] = true
+	}
+	e.setPrimarySelection(index)
+}
+
+func (e *Engine) selectAllVisible() {
+	if len(e.visibleKeys) == 0 {
+		return
+	}
+	e.selectedKeys = make(map[string]bool, len(e.visibleKeys))
+	for _, key := range e.visibleKeys {
+		e.selectedKeys[key] = true
+	}
+	if e.selectedListIndex < 0 {
+		e.setPrimarySelection(0)
+	}
+	e.selectionAnchor = e.visibleKeys[0]
+}
+
+func (e *Engine) setPrimarySelection(index int) {
+	key := e.visibleKeys[index]
+	e.selectedListIndex = index
+	e.selectedKey = key
+	e.selectedAtlasIndex = e.activeIconOf(key)
+}
+
+// selectKey selects the entry with the given key, also if the filter hides it from the list.
+func (e *Engine) selectKey(key string) {
+	if index := e.visibleIndexOf(key); index >= 0 {
+		e.selectListIndex(index)
+		return
+	}
+	e.selectedKeys = map[string]bool{key: true}
+	e.selectionAnchor = key
+	e.selectedListIndex = -1
+	e.selectedKey = key
+	e.selectedAtlasIndex = e.activeIconOf(key)
+}
+
+func (e *Engine) visibleIndexOf(key string) int {
+	for index, visibleKey := range e.visibleKeys {
+		if visibleKey == key {
+			return index
+		}
+	}
+	return -1
+}
+
+// selectedVisibleIndices returns the indices of the selected entries that pass the filter, in list order.
+func (e *Engine) selectedVisibleIndices() []int {
+	var indices []int
+	for index, key := range e.visibleKeys {
+		if e.selectedKeys[key] {
+			indices = append(indices, index)
+		}
+	}
+	return indices
+}
+
+func (e *Engine) selectedVisibleKeys() []string {
+	var keys []string
+	for _, index := range e.selectedVisibleIndices() {
+		keys = append(keys, e.visibleKeys[index])
+	}
+	return keys
+}
+
+// sequentialCells returns count atlas cells, starting at start and continuing
+// row by row or column by column. Cells after the end of the atlas are left out.
+func sequentialCells(start geometry.Point, count int, cellCount geometry.Point, columnMajor bool) []geometry.Point {
+	var cells []geometry.Point
+	for i := 0; i < count; i++ {
+		var cell geometry.Point
+		if columnMajor {
+			position := start.X*cellCount.Y + start.Y + i
+			cell = geometry.Point{X: position / cellCount.Y, Y: position % cellCount.Y}
+		} else {
+			position := start.Y*cellCount.X + start.X + i
+			cell = geometry.Point{X: position % cellCount.X, Y: position / cellCount.X}
+		}
+		if cell.X >= cellCount.X || cell.Y >= cellCount.Y {
+			break
+		}
+		cells = append(cells, cell)
+	}
+	return cells
+}
+
+// blockCells returns the cells of the rectangle between two corners, row by row or column by column.
+func blockCells(cornerA, cornerB geometry.Point, columnMajor bool) []geometry.Point {
+	block := geometry.NewRect(min(cornerA.X, cornerB.X), min(cornerA.Y, cornerB.Y), max(cornerA.X, cornerB.X)+1, max(cornerA.Y, cornerB.Y)+1)
+	var cells []geometry.Point
+	if columnMajor {
+		for x := block.Min.X; x < block.Max.X; x++ {
+			for y := block.Min.Y; y < block.Max.Y; y++ {
+				cells = append(cells, geometry.Point{X: x, Y: y})
+			}
+		}
+		return cells
+	}
+	for y := block.Min.Y; y < block.Max.Y; y++ {
+		for x := block.Min.X; x < block.Max.X; x++ {
+			cells = append(cells, geometry.Point{X: x, Y: y})
+		}
+	}
+	return cells
+}
+
+// assignCells assigns the cells to the selected entries in list order as one undoable command.
+func (e *Engine) assignCells(cells []geometry.Point) bool {
+	keys := e.selectedVisibleKeys()
+	count := min(len(keys), len(cells))
+	if count == 0 {
+		return false
+	}
+	cellCountX := e.tileAtlas.GetCellCount().X
+	icons := make([]int32, count)
+	for i := 0; i < count; i++ {
+		icons[i] = int32(XYToIndex(cells[i].X, cells[i].Y, cellCountX))
+	}
+	e.assignIcons(keys[:count], icons)
+	return true
+}
+
+// plannedCells are the cells a click at gridPos would assign to the selection.
+func (e *Engine) plannedCells(gridPos geometry.Point) []geometry.Point {
+	if e.font.isChaining {
+		return sequentialCells(gridPos, len(e.fontChainKeys()), e.tileAtlas.GetCellCount(), e.fillColumnMajor)
+	}
+	return sequentialCells(gridPos, len(e.selectedVisibleIndices()), e.tileAtlas.GetCellCount(), e.fillColumnMajor)
+}
+
+// handleAtlasDrag assigns on release: a click assigns consecutive cells,
+// dragging a rectangle assigns the cells of the rectangle.
+func (e *Engine) handleAtlasDrag() bool {
+	if !e.isDraggingAtlas {
+		if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) && e.isOverAtlas(e.mousePosInPixels) {
+			e.isDraggingAtlas = true
+			e.atlasDragStart = e.atlasGridFromScreenPos(e.mousePosInPixels)
+			e.focus = focusAtlas
+			e.atlasCursor = e.atlasDragStart
+			return true
+		}
+		return false
+	}
+	if ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
+		if e.isOverAtlas(e.mousePosInPixels) {
+			e.atlasCursor = e.atlasGridFromScreenPos(e.mousePosInPixels)
+		}
+		return true
+	}
+	e.isDraggingAtlas = false
+	if e.atlasCursor == e.atlasDragStart {
+		return e.assignAtlasCell(e.atlasDragStart)
+	}
+	if e.timeline.isOpen {
+		return e.insertFrames(blockCells(e.atlasDragStart, e.atlasCursor, e.fillColumnMajor))
+	}
+	if e.autotile.isOpen {
+		return e.assignAutotileCells(blockCells(e.atlasDragStart, e.atlasCursor, e.fillColumnMajor))
+	}
+	return e.assignCells(blockCells(e.atlasDragStart, e.atlasCursor, e.fillColumnMajor))
+}
+
+func (e *Engine) drawAtlasSelectionHints() {
+	atlasTileSize := e.tileAtlas.GetTileSize().MulF(e.atlasScale)
+	hintColor := e.theme.Current
+	if e.isDraggingAtlas {
+		topLeft := geometry.Point{X: min(e.atlasDragStart.X, e.atlasCursor.X), Y: min(e.atlasDragStart.Y, e.atlasCursor.Y)}
+		bottomRight := geometry.Point{X: max(e.atlasDragStart.X, e.atlasCursor.X), Y: max(e.atlasDragStart.Y, e.atlasCursor.Y)}
+		rectPos := e.gridToScreen(topLeft)
+		rectEnd := e.gridToScreen(bottomRight).Add(atlasTileSize)
+		e.renderer.DrawRectOutline(rectPos, rectEnd.Sub(rectPos), 2, hintColor)
+		return
+	}
+	if !e.drawAtlasCursor || len(e.selectedKeys) < 2 {
+		return
+	}
+	for _, cell := range e.plannedCells(e.atlasGridFromScreenPos(e.mousePosInPixels)) {
+		e.renderer.DrawRectOutline(e.gridToScreen(cell), atlasTileSize, 1, hintColor)
+	}
+}
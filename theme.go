@@ -0,0 +1,149 @@
+package main
+
+import (
+	"ReMapper/renderer"
+	"fmt"
+	"image/color"
+	"strings"
+)
+
+// Theme holds the colors of the editor itself, including the overlays drawn over the atlas
+// and the map preview. Only the colors of the entries come from the mapping.
+type Theme struct {
+	Name         string
+	Background   color.RGBA // behind the panes and the map preview
+	Panel        color.RGBA // popups, panels and toolbar buttons
+	Field        color.RGBA // the search box, headers and hovered rows
+	Button       color.RGBA
+	ButtonHover  color.RGBA
+	Border       color.RGBA
+	Separator    color.RGBA // splitters and grid lines
+	Text         color.RGBA
+	MutedText    color.RGBA // hints, labels and inactive buttons
+	DisabledText color.RGBA
+	Selection    color.RGBA // the fill of selected rows, cells and frames
+	SelectedText color.RGBA
+	Cursor       color.RGBA // the keyboard cursor, focus outlines, titles and search matches
+	Current      color.RGBA // the primary selected entry and the cells a click assigns
+	Error        color.RGBA
+	Warning      color.RGBA // entries with an icon outside of the atlas
+	Modified     color.RGBA // changed values in the grid and the inspector
+	Add          color.RGBA // actions that add something, like "+ Add field"
+	AddHover     color.RGBA
+	Hover        color.RGBA    // the atlas cell under the mouse
+	CursorFill   color.RGBA    // the atlas cell of the selected entry
+	Missing      color.RGBA    // map cells with a key that is not in the mapping
+	Path         color.RGBA    // the playtest path to the mouse
+	UsageLevels  [4]color.RGBA // cells used by 1, 2, 3 and more entries
+	UnusedFill   color.RGBA
+	UnusedBorder color.RGBA
+	Dim          color.RGBA // everything behind a dialog
+}
+
+var darkTheme = Theme{
+	Name:         "dark",
+	Background:   color.RGBA{R: 20, G: 20, B: 24, A: 255},
+	Panel:        color.RGBA{R: 30, G: 30, B: 36, A: 255},
+	Field:        color.RGBA{R: 40, G: 40, B: 48, A: 255},
+	Button:       color.RGBA{R: 50, G: 50, B: 60, A: 255},
+	ButtonHover:  color.RGBA{R: 85, G: 85, B: 100, A: 255},
+	Border:       color.RGBA{R: 120, G: 120, B: 130, A: 255},
+	Separator:    color.RGBA{R: 60, G: 60, B: 70, A: 255},
+	Text:         color.RGBA{R: 255, G: 255, B: 255, A: 255},
+	MutedText:    color.RGBA{R: 160, G: 160, B: 170, A: 255},
+	DisabledText: color.RGBA{R: 110, G: 110, B: 120, A: 255},
+	Selection:    color.RGBA{R: 70, G: 40, B: 45, A: 255},
+	SelectedText: color.RGBA{R: 255, G: 170, B: 160, A: 255},
+	Cursor:       color.RGBA{R: 255, G: 210, B: 60, A: 255},
+	Current:      color.RGBA{R: 255, G: 76, B: 67, A: 255},
+	Error:        color.RGBA{R: 255, G: 76, B: 67, A: 255},
+	Warning:      color.RGBA{R: 255, G: 150, B: 50, A: 255},
+	Modified:     color.RGBA{R: 50, G: 45, B: 30, A: 255},
+	Add:          color.RGBA{R: 130, G: 200, B: 130, A: 255},
+	AddHover:     color.RGBA{R: 180, G: 255, B: 180, A: 255},
+	Hover:        color.RGBA{R: 30, G: 200, B: 30, A: 75},
+	CursorFill:   color.RGBA{R: 30, G: 25, B: 200, A: 75},
+	Missing:      color.RGBA{R: 120, G: 20, B: 20, A: 120},
+	Path:         color.RGBA{R: 255, G: 210, B: 60, A: 255},
+	UsageLevels: [4]color.RGBA{
+		{R: 40, G: 200, B: 60, A: 80},
+		{R: 230, G: 200, B: 40, A: 110},
+		{R: 240, G: 130, B: 30, A: 130},
+		{R: 240, G: 40, B: 40, A: 150},
+	},
+	UnusedFill:   color.RGBA{R: 20, G: 0, B: 40, A: 170},
+	UnusedBorder: color.RGBA{R: 170, G: 90, B: 255, A: 255},
+	Dim:          color.RGBA{A: 150},
+}
+
+var lightTheme = Theme{
+	Name:         "light",
+	Background:   color.RGBA{R: 232, G: 232, B: 236, A: 255},
+	Panel:        color.RGBA{R: 248, G: 248, B: 250, A: 255},
+	Field:        color.RGBA{R: 222, G: 222, B: 230, A: 255},
+	Button:       color.RGBA{R: 212, G: 212, B: 220, A: 255},
+	ButtonHover:  color.RGBA{R: 190, G: 190, B: 202, A: 255},
+	Border:       color.RGBA{R: 140, G: 140, B: 150, A: 255},
+	Separator:    color.RGBA{R: 196, G: 196, B: 204, A: 255},
+	Text:         color.RGBA{R: 24, G: 24, B: 28, A: 255},
+	MutedText:    color.RGBA{R: 90, G: 90, B: 100, A: 255},
+	DisabledText: color.RGBA{R: 160, G: 160, B: 168, A: 255},
+	Selection:    color.RGBA{R: 250, G: 214, B: 200, A: 255},
+	SelectedText: color.RGBA{R: 150, G: 40, B: 30, A: 255},
+	Cursor:       color.RGBA{R: 200, G: 120, B: 0, A: 255},
+	Current:      color.RGBA{R: 210, G: 40, B: 30, A: 255},
+	Error:        color.RGBA{R: 200, G: 30, B: 30, A: 255},
+	Warning:      color.RGBA{R: 190, G: 90, B: 0, A: 255},
+	Modified:     color.RGBA{R: 250, G: 238, B: 196, A: 255},
+	Add:          color.RGBA{R: 30, G: 120, B: 40, A: 255},
+	AddHover:     color.RGBA{R: 20, G: 160, B: 40, A: 255},
+	Hover:        color.RGBA{R: 20, G: 120, B: 20, A: 70},
+	CursorFill:   color.RGBA{R: 20, G: 30, B: 150, A: 70},
+	Missing:      color.RGBA{R: 150, G: 20, B: 20, A: 100},
+	Path:         color.RGBA{R: 200, G: 120, B: 0, A: 255},
+	UsageLevels: [4]color.RGBA{
+		{R: 20, G: 110, B: 30, A: 80},
+		{R: 150, G: 120, B: 0, A: 110},
+		{R: 130, G: 70, B: 0, A: 130},
+		{R: 150, G: 20, B: 20, A: 150},
+	},
+	UnusedFill:   color.RGBA{R: 60, G: 40, B: 100, A: 110},
+	UnusedBorder: color.RGBA{R: 110, G: 40, B: 190, A: 255},
+	Dim:          color.RGBA{R: 90, G: 90, B: 100, A: 120},
+}
+
+var themes = []Theme{darkTheme, lightTheme}
+
+// themeByName returns one of the presets.
+func themeByName(name string) (Theme, error) {
+	var names []string
+	for _, theme := range themes {
+		if strings.EqualFold(theme.Name, name) {
+			return theme, nil
+		}
+		names = append(names, theme.Name)
+	}
+	return Theme{}, fmt.Errorf("unknown theme '%s', expected %s", name, strings.Join(names, " or "))
+}
+
+// withAlpha makes a theme color translucent, for panels that are drawn over the atlas.
+func withAlpha(c color.RGBA, alpha uint8) color.NRGBA {
+	return color.NRGBA{R: c.R, G: c.G, B: c.B, A: alpha}
+}
+
+// SetTheme replaces the colors of the editor, see themeByName for the presets.
+func (e *Engine) SetTheme(theme Theme) {
+	e.theme = theme
+}
+
+// SetPalette replaces the colors of the color picker and adds their names to the color fields
+// and the color codes of the renderer. The built-in names stay valid unless the palette redefines them.
+func (e *Engine) SetPalette(palette *renderer.Palette) {
+	e.palette = palette.Over(renderer.DefaultPalette())
+	e.renderer.SetColorFromName(e.palette.ColorFromName)
+}
+
+// parseColor reads a color field: a name of the palette, hex or r,g,b.
+func (e *Engine) parseColor(text string) (color.RGBA, bool) {
+	return e.palette.Resolve(text)
+}
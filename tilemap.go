@@ -0,0 +1,239 @@
+package main
+
+import (
+	"ReMapper/geometry"
+	"ReMapper/recfile"
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// emptyMapCell marks a cell without an entry in map files.
+const emptyMapCell = "-"
+
+// tileMap is a map of mapping keys that shows the mapping in context.
+// The layers are drawn in order, an empty key is an empty cell.
+type tileMap struct {
+	fileName string
+	size     geometry.Point
+	layers   []tileMapLayer
+}
+
+type tileMapLayer struct {
+	name  string
+	cells []string // row by row
+}
+
+func (m *tileMap) contains(pos geometry.Point) bool {
+	return pos.X >= 0 && pos.Y >= 0 && pos.X < m.size.X && pos.Y < m.size.Y
+}
+
+func (m *tileMap) cellIndex(pos geometry.Point) int {
+	return pos.Y*m.size.X + pos.X
+}
+
+func (m *tileMap) keyAt(layer int, pos geometry.Point) string {
+	if !m.contains(pos) {
+		return ""
+	}
+	return m.layers[layer].cells[m.cellIndex(pos)]
+}
+
+// topKeyAt returns the key of the topmost layer that is not empty at the position.
+func (m *tileMap) topKeyAt(pos geometry.Point) string {
+	for layer := len(m.layers) - 1; layer >= 0; layer-- {
+		if key := m.keyAt(layer, pos); key != "" {
+			return key
+		}
+	}
+	return ""
+}
+
+func (m *tileMap) setKey(layer int, pos geometry.Point, key string) {
+	if m.contains(pos) {
+		m.layers[layer].cells[m.cellIndex(pos)] = key
+	}
+}
+
+// tileMapState is a copy of the size and the cells of a map, the undo history keeps these.
+type tileMapState struct {
+	size   geometry.Point
+	layers []tileMapLayer
+}
+
+func cloneLayers(layers []tileMapLayer) []tileMapLayer {
+	clone := make([]tileMapLayer, len(layers))
+	for index, layer := range layers {
+		clone[index] = tileMapLayer{name: layer.name, cells: slices.Clone(layer.cells)}
+	}
+	return clone
+}
+
+func (m *tileMap) state() tileMapState {
+	return tileMapState{size: m.size, layers: cloneLayers(m.layers)}
+}
+
+func (m *tileMap) setState(state tileMapState) {
+	m.size = state.size
+	m.layers = cloneLayers(state.layers)
+}
+
+func (s tileMapState) equals(other tileMapState) bool {
+	return s.size == other.size && slices.EqualFunc(s.layers, other.layers, func(a, b tileMapLayer) bool {
+		return a.name == b.name && slices.Equal(a.cells, b.cells)
+	})
+}
+
+// resized returns the state of the map with a new size. Cells are kept at their position,
+// the ones outside of the new size are cut off.
+func (m *tileMap) resized(size geometry.Point) tileMapState {
+	state := tileMapState{size: size}
+	for _, layer := range m.layers {
+		cells := make([]string, size.X*size.Y)
+		for y := 0; y < min(size.Y, m.size.Y); y++ {
+			for x := 0; x < min(size.X, m.size.X); x++ {
+				cells[y*size.X+x] = layer.cells[m.cellIndex(geometry.Point{X: x, Y: y})]
+			}
+		}
+		state.layers = append(state.layers, tileMapLayer{name: layer.name, cells: cells})
+	}
+	return state
+}
+
+func newEmptyTileMap(size geometry.Point, layerName string) *tileMap {
+	return &tileMap{size: size, layers: []tileMapLayer{{name: layerName, cells: make([]string, size.X*size.Y)}}}
+}
+
+func isRecMapFile(fileName string) bool {
+	return strings.EqualFold(filepath.Ext(fileName), ".rec")
+}
+
+// loadTileMap reads a rec map if the file name ends with .rec and a text grid otherwise.
+func loadTileMap(fileName string) (*tileMap, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var tiles *tileMap
+	if isRecMapFile(fileName) {
+		tiles, err = readRecMap(file)
+	} else {
+		tiles, err = readTextMap(file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fileName, err)
+	}
+	tiles.fileName = fileName
+	return tiles, nil
+}
+
+// readTextMap reads a single layer with one row per line and the keys separated by spaces.
+// Empty lines and lines starting with # are skipped.
+func readTextMap(reader io.Reader) (*tileMap, error) {
+	var rows [][]string
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rows = append(rows, strings.Fields(line))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return newTileMap([]string{"ground"}, [][][]string{rows})
+}
+
+// readRecMap reads one record per layer, with the name of the layer and one row field per row:
+//
+//	layer: ground
+//	row: wall wall wall
+//	row: wall floor wall
+func readRecMap(reader io.Reader) (*tileMap, error) {
+	var names []string
+	var layers [][][]string
+	for index, record := range recfile.Read(reader) {
+		name := record.FindFirstFieldValue("layer")
+		if name == "" {
+			name = fmt.Sprintf("layer %d", index+1)
+		}
+		var rows [][]string
+		for _, field := range record {
+			if field.Name == "row" {
+				rows = append(rows, strings.Fields(field.Value))
+			}
+		}
+		names = append(names, name)
+		layers = append(layers, rows)
+	}
+	return newTileMap(names, layers)
+}
+
+// newTileMap makes a map as large as the longest row and the layer with the most rows.
+func newTileMap(names []string, layers [][][]string) (*tileMap, error) {
+	var size geometry.Point
+	for _, rows := range layers {
+		size.Y = max(size.Y, len(rows))
+		for _, row := range rows {
+			size.X = max(size.X, len(row))
+		}
+	}
+	if size.X == 0 || size.Y == 0 {
+		return nil, fmt.Errorf("the map has no cells")
+	}
+	tiles := &tileMap{size: size}
+	for index, rows := range layers {
+		layer := tileMapLayer{name: names[index], cells: make([]string, size.X*size.Y)}
+		for y, row := range rows {
+			for x, key := range row {
+				if key != emptyMapCell {
+					layer.cells[y*size.X+x] = key
+				}
+			}
+		}
+		tiles.layers = append(tiles.layers, layer)
+	}
+	return tiles, nil
+}
+
+// rows returns the keys of a layer row by row, empty cells are written as emptyMapCell.
+func (m *tileMap) rows(layer int) []string {
+	var rows []string
+	for y := 0; y < m.size.Y; y++ {
+		keys := make([]string, m.size.X)
+		for x := range keys {
+			keys[x] = m.keyAt(layer, geometry.Point{X: x, Y: y})
+			if keys[x] == "" {
+				keys[x] = emptyMapCell
+			}
+		}
+		rows = append(rows, strings.Join(keys, " "))
+	}
+	return rows
+}
+
+// writeTileMap writes a rec map if the file name ends with .rec and a text grid otherwise.
+// The cells hold the keys of the mapping, so changed icons don't touch the map.
+func writeTileMap(fileName string, m *tileMap) error {
+	if isRecMapFile(fileName) {
+		var records []recfile.Record
+		for layer := range m.layers {
+			record := recfile.Record{{Name: "layer", Value: m.layers[layer].name}}
+			for _, row := range m.rows(layer) {
+				record = append(record, recfile.Field{Name: "row", Value: row})
+			}
+			records = append(records, record)
+		}
+		return writeRecFile(fileName, records)
+	}
+	if len(m.layers) > 1 {
+		return fmt.Errorf("a text map has only one layer, save the map as .rec")
+	}
+	return os.WriteFile(fileName, []byte(strings.Join(m.rows(0), "\n")+"\n"), 0644)
+}
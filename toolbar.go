@@ -0,0 +1,102 @@
+package main
+
+import (
+	"ReMapper/geometry"
+	"ReMapper/renderer"
+	"fmt"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+type toolbarButton struct {
+	label    string
+	isActive bool
+	action   func()
+	bounds   geometry.Rect
+}
+
+// toolbarButtons returns the buttons in the top left corner of the atlas pane: the layer switch
+// and the orientation of the selected entries. Toggles follow the primary selected entry.
+func (e *Engine) toolbarButtons() []toolbarButton {
+	var groups [][]toolbarButton
+	var layerButtons []toolbarButton
+	for layer := mappingLayer(0); layer < layerCount; layer++ {
+		layer := layer
+		layerButtons = append(layerButtons, toolbarButton{label: layer.String(), isActive: e.activeLayer == layer, action: func() { e.switchLayer(layer) }})
+	}
+	groups = append(groups, layerButtons)
+	if _, isMapped := e.iconMapping[e.selectedKey]; isMapped {
+		current := e.orientationOf(e.keyRecords[e.selectedKey])
+		groups = append(groups, []toolbarButton{
+			{label: "Flip X", isActive: current.FlipX, action: func() {
+				e.changeOrientation("flip x", func(orientation renderer.TileOrientation) renderer.TileOrientation {
+					orientation.FlipX = !current.FlipX
+					return orientation
+				})
+			}},
+			{label: "Flip Y", isActive: current.FlipY, action: func() {
+				e.changeOrientation("flip y", func(orientation renderer.TileOrientation) renderer.TileOrientation {
+					orientation.FlipY = !current.FlipY
+					return orientation
+				})
+			}},
+			{label: fmt.Sprintf("Rotate %d", current.Rotation), isActive: current.Rotation != 0, action: func() {
+				e.changeOrientation("rotate", func(orientation renderer.TileOrientation) renderer.TileOrientation {
+					orientation.Rotation = (current.Rotation + 90) % 360
+					return orientation
+				})
+			}},
+		})
+	}
+
+	return e.layoutButtonGroups(groups, e.atlasPane.viewport().Min.Add(geometry.Point{X: int(e.padding), Y: int(e.padding)}))
+}
+
+// layoutButtonGroups places the buttons in a row that starts at origin, with a gap between the groups.
+func (e *Engine) layoutButtonGroups(groups [][]toolbarButton, origin geometry.Point) []toolbarButton {
+	_, textHeight := e.renderer.MeasureString("Ag")
+	var buttons []toolbarButton
+	for _, group := range groups {
+		for _, button := range group {
+			labelWidth, _ := e.renderer.MeasureString(button.label)
+			size := geometry.Point{X: int(labelWidth + e.padding*2), Y: int(textHeight + e.padding)}
+			button.bounds = geometry.Rect{Min: origin, Max: origin.Add(size)}
+			buttons = append(buttons, button)
+			origin.X += size.X
+		}
+		origin.X += int(e.padding)
+	}
+	return buttons
+}
+
+func (e *Engine) handleToolbarClick() bool {
+	if !inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		return false
+	}
+	for _, button := range e.toolbarButtons() {
+		if button.bounds.Contains(e.mousePosInPixels) {
+			button.action()
+			return true
+		}
+	}
+	return false
+}
+
+func (e *Engine) drawToolbar() {
+	e.drawButtons(e.toolbarButtons())
+}
+
+func (e *Engine) drawButtons(buttons []toolbarButton) {
+	_, textHeight := e.renderer.MeasureString("Ag")
+	for _, button := range buttons {
+		fillColor := withAlpha(e.theme.Panel, 230)
+		textColor := e.theme.MutedText
+		if button.isActive {
+			fillColor = withAlpha(e.theme.Selection, 240)
+			textColor = e.theme.Cursor
+		}
+		e.renderer.DrawFilledRect(button.bounds.Min, button.bounds.Size(), fillColor)
+		e.renderer.DrawRectOutline(button.bounds.Min, button.bounds.Size(), 1, e.theme.Border)
+		e.renderer.DrawTTFOnScreen(float64(button.bounds.Min.X)+e.padding, float64(button.bounds.Min.Y)+(float64(button.bounds.Size().Y)+textHeight)/2-2, button.label, textColor)
+	}
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"ReMapper/recfile"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// groupMode decides how the list is grouped into a tree.
+type groupMode int
+
+const (
+	groupNone groupMode = iota
+	groupByPrefix
+	groupByField
+	groupModeCount
+)
+
+func (m groupMode) String() string {
+	switch m {
+	case groupByPrefix:
+		return "prefix"
+	case groupByField:
+		return "field"
+	}
+	return "none"
+}
+
+func parseGroupMode(text string) (groupMode, bool) {
+	for mode := groupNone; mode < groupModeCount; mode++ {
+		if mode.String() == text {
+			return mode, true
+		}
+	}
+	return groupNone, false
+}
+
+// entryGroup is a node of the tree. entryIndices are the indices of all visible
+// entries in the group and its subgroups.
+type entryGroup struct {
+	id           string
+	label        string
+	depth        int
+	entryIndices []int
+}
+
+// listRow is a line of the list, either a group header or an entry.
+type listRow struct {
+	entryIndex int
+	group      *entryGroup
+	depth      int
+}
+
+const treeIndent = 20
+
+// groupPath returns the groups an entry belongs to, from the outermost to the innermost.
+// Entries that belong to no group return nil.
+func (e *Engine) groupPath(key string) []string {
+	record := e.keyRecords[key]
+	switch e.groupMode {
+	case groupByPrefix:
+		if e.config.GroupSeparator == "" {
+			return nil
+		}
+		parts := strings.Split(key, e.config.GroupSeparator)
+		return parts[:len(parts)-1]
+	case groupByField:
+		if value := record.FindFirstFieldValue(e.config.GroupField); value != "" {
+			return []string{value}
+		}
+	}
+	return nil
+}
+
+func groupID(mode groupMode, path []string) string {
+	return mode.String() + ":" + strings.Join(path, "/")
+}
+
+// sortIntoGroups orders the visible entries by their group path. The order inside a group is kept.
+func (e *Engine) sortIntoGroups() {
+	e.visiblePaths = make([][]string, len(e.visibleKeys))
+	for index, key := range e.visibleKeys {
+		e.visiblePaths[index] = e.groupPath(key)
+	}
+	if e.groupMode == groupNone {
+		return
+	}
+	order := make([]int, len(e.visibleKeys))
+	for index := range order {
+		order[index] = index
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		return slices.Compare(e.visiblePaths[a], e.visiblePaths[b])
+	})
+	sortedKeys := make([]string, len(order))
+	sortedMatches := make([][]int, len(order))
+	sortedPaths := make([][]string, len(order))
+	for newIndex, oldIndex := range order {
+		sortedKeys[newIndex] = e.visibleKeys[oldIndex]
+		sortedMatches[newIndex] = e.visibleMatches[oldIndex]
+		sortedPaths[newIndex] = e.visiblePaths[oldIndex]
+	}
+	e.visibleKeys, e.visibleMatches, e.visiblePaths = sortedKeys, sortedMatches, sortedPaths
+}
+
+// isCollapsed ignores the collapsed state while filtering, so all matches are shown.
+func (e *Engine) isCollapsed(group *entryGroup) bool {
+	return e.searchText == "" && e.collapsedGroups[group.id]
+}
+
+func (e *Engine) isAnyCollapsed(groups []*entryGroup) bool {
+	for _, group := range groups {
+		if e.isCollapsed(group) {
+			return true
+		}
+	}
+	return false
+}
+
+// buildListRows turns the visible entries into list rows with group headers.
+// Entries in collapsed groups get no row, their rowOfEntry is -1.
+func (e *Engine) buildListRows() {
+	e.listRows = e.listRows[:0]
+	e.rowOfEntry = make([]int, len(e.visibleKeys))
+	var openGroups []*entryGroup
+	var previousPath []string
+	for index := range e.visibleKeys {
+		path := e.visiblePaths[index]
+		common := 0
+		for common < len(path) && common < len(previousPath) && path[common] == previousPath[common] {
+			common++
+		}
+		openGroups = openGroups[:common]
+		for depth := common; depth < len(path); depth++ {
+			group := &entryGroup{id: groupID(e.groupMode, path[:depth+1]), label: path[depth], depth: depth}
+			if !e.isAnyCollapsed(openGroups) {
+				e.listRows = append(e.listRows, listRow{entryIndex: -1, group: group, depth: depth})
+			}
+			openGroups = append(openGroups, group)
+		}
+		for _, group := range openGroups {
+			group.entryIndices = append(group.entryIndices, index)
+		}
+		if e.isAnyCollapsed(openGroups) {
+			e.rowOfEntry[index] = -1
+		} else {
+			e.rowOfEntry[index] = len(e.listRows)
+			e.listRows = append(e.listRows, listRow{entryIndex: index, depth: len(path)})
+		}
+		previousPath = path
+	}
+}
+
+func (e *Engine) toggleGroup(group *entryGroup) {
+	if e.collapsedGroups[group.id] {
+		delete(e.collapsedGroups, group.id)
+	} else {
+		e.collapsedGroups[group.id] = true
+	}
+	e.buildListRows()
+	e.updateElementBounds()
+	e.saveViewState()
+}
+
+// expandGroupsOf opens all collapsed groups that hide the entry at index.
+func (e *Engine) expandGroupsOf(index int) {
+	path := e.visiblePaths[index]
+	for depth := range path {
+		delete(e.collapsedGroups, groupID(e.groupMode, path[:depth+1]))
+	}
+	e.buildListRows()
+	e.updateElementBounds()
+	e.saveViewState()
+}
+
+// selectGroup selects all entries of a group, so they can be assigned in one go.
+func (e *Engine) selectGroup(group *entryGroup, keepOthers bool) {
+	if len(group.entryIndices) == 0 {
+		return
+	}
+	if !keepOthers {
+		e.selectedKeys = make(map[string]bool, len(group.entryIndices))
+	}
+	for _, index := range group.entryIndices {
+		e.selectedKeys[e.visibleKeys[index]] = true
+	}
+	firstIndex := group.entryIndices[0]
+	e.selectionAnchor = e.visibleKeys[firstIndex]
+	e.setPrimarySelection(firstIndex)
+}
+
+// clickGroupRow collapses or expands the group when its marker is clicked, otherwise it selects the group.
+func (e *Engine) clickGroupRow(row listRow, textPosX float64) {
+	markerWidth, _ := e.renderer.MeasureString("[+] ")
+	if float64(e.mousePosInPixels.X) < textPosX+markerWidth {
+		e.toggleGroup(row.group)
+		return
+	}
+	e.selectGroup(row.group, isControlPressed())
+}
+
+func (e *Engine) cycleGroupMode() {
+	e.groupMode = (e.groupMode + 1) % groupModeCount
+	e.applyFilter()
+	e.scrollToSelection()
+	e.saveViewState()
+}
+
+// nearestShownEntry finds the next entry in direction that is not hidden in a collapsed group.
+func (e *Engine) nearestShownEntry(index, direction int) int {
+	if direction == 0 {
+		direction = 1
+	}
+	for _, step := range []int{direction, -direction} {
+		for i := index; i >= 0 && i < len(e.visibleKeys); i += step {
+			if e.rowOfEntry[i] >= 0 {
+				return i
+			}
+		}
+	}
+	return index
+}
+
+func (e *Engine) drawGroupRow(row listRow, drawInfo ElementInfo) {
+	marker := "[-]"
+	if e.isCollapsed(row.group) {
+		marker = "[+]"
+	}
+	label := fmt.Sprintf("%s %s (%d)", marker, row.group.label, len(row.group.entryIndices))
+	labelColor := e.theme.Cursor
+	allSelected := true
+	for _, index := range row.group.entryIndices {
+		allSelected = allSelected && e.selectedKeys[e.visibleKeys[index]]
+	}
+	if allSelected && len(e.selectedKeys) > 1 {
+		labelColor = e.theme.SelectedText
+	}
+	e.renderer.DrawTTFOnScreen(drawInfo.TextPosition.X, drawInfo.TextPosition.Y, label, labelColor)
+}
+
+// viewStateFileName is the file that remembers the grouping and the collapsed groups of a mapping file.
+func (e *Engine) viewStateFileName() string {
+	return e.mappingFileName + ".view"
+}
+
+func (e *Engine) loadViewState() {
+	e.collapsedGroups = make(map[string]bool)
+	file, err := os.Open(e.viewStateFileName())
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			println(err.Error())
+		}
+		return
+	}
+	records := recfile.Read(file)
+	file.Close()
+	if len(records) == 0 {
+		return
+	}
+	for _, field := range records[0] {
+		switch field.Name {
+		case "group_mode":
+			if mode, isValid := parseGroupMode(field.Value); isValid {
+				e.groupMode = mode
+			}
+		case "collapsed":
+			e.collapsedGroups[field.Value] = true
+		}
+	}
+}
+
+func (e *Engine) saveViewState() {
+	state := recfile.Record{{Name: "group_mode", Value: e.groupMode.String()}}
+	var collapsedIDs []string
+	for id := range e.collapsedGroups {
+		collapsedIDs = append(collapsedIDs, id)
+	}
+	slices.Sort(collapsedIDs)
+	for _, id := range collapsedIDs {
+		state = append(state, recfile.Field{Name: "collapsed", Value: id})
+	}
+	if err := writeRecFile(e.viewStateFileName(), []recfile.Record{state}); err != nil {
+		println(err.Error())
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"ReMapper/recfile"
+	"errors"
+	"fmt"
+	"github.com/hajimehoshi/ebiten/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+func cloneRecords(records []recfile.Record) []recfile.Record {
+	clone := make([]recfile.Record, len(records))
+	for i, record := range records {
+		clone[i] = slices.Clone(record)
+	}
+	return clone
+}
+
+func recordsEqual(a, b []recfile.Record) bool {
+	return slices.EqualFunc(a, b, func(recA, recB recfile.Record) bool {
+		return slices.Equal(recA, recB)
+	})
+}
+
+// currentRecords returns a copy of the loaded records with all edits applied,
+// this is what a save would write.
+func (e *Engine) currentRecords() []recfile.Record {
+	records := cloneRecords(e.originalRecords)
+	applyIconMapping(records, e.config, e.iconMapping)
+	return records
+}
+
+func (e *Engine) isDirty() bool {
+	return e.dirty
+}
+
+// markChanged schedules a comparison against the saved records, it is done once per frame.
+func (e *Engine) markChanged() {
+	e.dirtyCheckNeeded = true
+}
+
+func (e *Engine) updateDirtyState() {
+	if e.dirtyCheckNeeded {
+		e.dirtyCheckNeeded = false
+		e.dirty = !recordsEqual(e.currentRecords(), e.savedRecords)
+	}
+	windowTitle := e.GetTitle()
+	if windowTitle != e.shownWindowTitle {
+		e.shownWindowTitle = windowTitle
+		ebiten.SetWindowTitle(windowTitle)
+	}
+}
+
+// handleWindowClose asks before the window is closed. Another open dialog is cancelled
+// for the quit confirmation, a quit confirmation that is already open stays.
+func (e *Engine) handleWindowClose() {
+	if e.activeDialog != nil {
+		if e.activeDialog.confirmsQuit {
+			return
+		}
+		e.cancelDialog()
+	}
+	e.requestQuit()
+}
+
+// requestQuit quits right away if there is nothing to save, otherwise it asks first.
+func (e *Engine) requestQuit() {
+	if e.preview.editor.isDirty {
+		e.askToSaveMap(e.requestQuit)
+		e.activeDialog.confirmsQuit = true
+		return
+	}
+	if !e.isDirty() {
+		e.shouldQuit = true
+		return
+	}
+	e.openDialog(&dialog{
+		Title: "Unsaved changes",
+		Lines: []string{fmt.Sprintf("Save the changes to %s before quitting?", filepath.Base(e.mappingFileName))},
+		Buttons: []dialogButton{
+			{Label: "Save (S)", Key: ebiten.KeyS, Action: func() {
+				if e.saveAndReport() {
+					e.shouldQuit = true
+				}
+			}},
+			{Label: "Discard (D)", Key: ebiten.KeyD, Action: func() {
+				e.removeRecoveryFile()
+				e.shouldQuit = true
+			}},
+			{Label: "Cancel (Esc)", Key: ebiten.KeyEscape, Action: func() {}},
+		},
+		confirmsQuit: true,
+	})
+}
+
+// saveAndReport saves the mapping and shows an error dialog if that failed.
+func (e *Engine) saveAndReport() bool {
+	if err := e.saveChanges(e.mappingFileName); err != nil {
+		e.openDialog(&dialog{
+			Title:   "Could not save",
+			Lines:   []string{err.Error()},
+			Buttons: []dialogButton{{Label: "OK (Enter)", Key: ebiten.KeyEnter, Action: func() {}}},
+		})
+		return false
+	}
+	e.saveTicks = 30
+	return true
+}
+
+func (e *Engine) recoveryFileName() string {
+	return e.mappingFileName + ".recovery"
+}
+
+// EnableAutosave writes the unsaved state to the recovery file in the given interval.
+func (e *Engine) EnableAutosave(interval time.Duration) {
+	e.autosaveInterval = interval
+	e.lastAutosave = time.Now()
+}
+
+func (e *Engine) updateAutosave() {
+	if e.autosaveInterval <= 0 || time.Since(e.lastAutosave) < e.autosaveInterval {
+		return
+	}
+	e.lastAutosave = time.Now()
+	if !e.isDirty() || e.history.changeCount == e.autosavedChange {
+		return
+	}
+	if err := writeMappingFile(e.recoveryFileName(), e.currentRecords(), e.recSections); err != nil {
+		println(err.Error())
+		return
+	}
+	e.autosavedChange = e.history.changeCount
+}
+
+func (e *Engine) removeRecoveryFile() {
+	if err := os.Remove(e.recoveryFileName()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		println(err.Error())
+	}
+	e.autosavedChange = -1
+}
+
+// OfferRecovery asks whether to restore the recovery file of an earlier session, if there is one.
+func (e *Engine) OfferRecovery() {
+	recoveryInfo, err := os.Stat(e.recoveryFileName())
+	if err != nil {
+		return
+	}
+	e.openDialog(&dialog{
+		Title: "Recover unsaved changes",
+		Lines: []string{
+			fmt.Sprintf("Found autosaved changes from %s.", recoveryInfo.ModTime().Format("2006-01-02 15:04:05")),
+			"Restore them? They are not saved until you save.",
+		},
+		Buttons: []dialogButton{
+			{Label: "Restore (R)", Key: ebiten.KeyR, Action: e.restoreRecoveryFile},
+			{Label: "Discard (D)", Key: ebiten.KeyD, Action: e.removeRecoveryFile},
+			{Label: "Later (Esc)", Key: ebiten.KeyEscape, Action: func() {}},
+		},
+	})
+}
+
+func (e *Engine) restoreRecoveryFile() {
+	records, mapping, err := buildCurrentMapping(e.recoveryFileName(), e.config)
+	if err != nil {
+		println(err.Error())
+		return
+	}
+	e.execute(&replaceDocumentCommand{
+		description: "restore autosaved changes",
+		oldRecords:  e.originalRecords,
+		oldMapping:  e.iconMapping,
+		newRecords:  records,
+		newMapping:  mapping,
+	})
+}
+
+// replaceDocumentCommand swaps all records and icons at once. The selected
+// entry is restored after undo and set to newSelection after do, if given.
+type replaceDocumentCommand struct {
+	description            string
+	oldRecords, newRecords []recfile.Record
+	oldMapping, newMapping map[string]int32
+	oldSelection           string
+	newSelection           string
+}
+
+func (c *replaceDocumentCommand) Do(e *Engine) {
+	e.setDocument(c.newRecords, c.newMapping)
+	if c.newSelection != "" {
+		e.jumpToKey(c.newSelection)
+	}
+}
+
+func (c *replaceDocumentCommand) Undo(e *Engine) {
+	e.setDocument(c.oldRecords, c.oldMapping)
+	if c.oldSelection != "" {
+		e.jumpToKey(c.oldSelection)
+	}
+}
+
+func (c *replaceDocumentCommand) Description() string {
+	return c.description
+}